@@ -4,8 +4,8 @@ import (
 	"anonpao/utils"
 )
 
-// returns the bytes of 'iv || index' for the given block_number
-func get_block_iv_8(iv []byte, block_num byte) []byte {
+// returns the bytes of 'iv || index' for the given block_number, whose counter is block_num + 2
+func get_block_iv_32(iv []byte, block_num uint32) []byte {
 	block_iv := make([]byte, 16)
 
 	for i := 0; i < 12; i++ {
@@ -13,43 +13,24 @@ func get_block_iv_8(iv []byte, block_num byte) []byte {
 	}
 
 	for i := 12; i < 16; i++ {
-		block_iv[i] = byte(uint32(block_num+byte(2)) >> (8 * (15 - i)))
+		block_iv[i] = byte((block_num + 2) >> (8 * (15 - i)))
 	}
 
 	return block_iv
 }
 
-// same as above but the block_number is given as uint_8
+// same as above but the block_number is given as an int
 func get_block_iv(iv []byte, block_num int) []byte {
-	return get_block_iv_8(iv, byte(block_num))
+	return get_block_iv_32(iv, uint32(block_num))
 }
 
-func AES_GCM_encrypt(key, iv, plaintext []byte, starting_block byte) []byte {
-	len_in_bytes := len(plaintext)
-	num_blocks := (len_in_bytes / 16)
-	if len_in_bytes%16 != 0 {
-		num_blocks += 1
-	}
-
-	expandedKey := expandKey(key)
-
-	// This part contains the operations required for GCM
-
-	block_iv := make([]byte, 16)
-	output := make([]byte, 0)
-
-	for i := 0; i < num_blocks; i++ {
-		block_iv = get_block_iv_8(iv, byte(i)+starting_block)
-
-		iv_cipher := encrypt_expanded(expandedKey, block_iv)
-
-		output = utils.Concat(output, iv_cipher)
-
-	}
-	return utils.XOR_arrays_prefix(plaintext, output, len(plaintext))
+func AES_GCM_encrypt(key, iv, plaintext []byte, starting_block uint32) []byte {
+	output := make([]byte, len(plaintext))
+	xorKeystream(cachedRoundKeys(key), iv, output, plaintext, starting_block)
+	return output
 }
 
-func AES_GCM_decrypt(key, iv, ciphertext []byte, starting_block byte) []byte {
+func AES_GCM_decrypt(key, iv, ciphertext []byte, starting_block uint32) []byte {
 	return AES_GCM_encrypt(key, iv, ciphertext, starting_block)
 }
 
@@ -58,18 +39,16 @@ func AES_GCM_decrypt(key, iv, ciphertext []byte, starting_block byte) []byte {
 // and at an offset of length offset within that starting block.
 // This is used at one point in the TLS Key Schedule Shortcut method
 
-func AES_GCM_decrypt_128bytes_middle(key []byte, iv []byte, ciphertext []byte, starting_block byte, offset byte) []byte {
-	zero_plaintext := make([]byte, 144)
-
-	pad := AES_GCM_decrypt(key, iv, zero_plaintext, starting_block)
-
-	pad_offset := make([]byte, 128)
-
-	for i := 0; i < 128; i++ {
-		pad_offset[i] = pad[byte(i)+offset]
+func AES_GCM_decrypt_128bytes_middle(key []byte, iv []byte, ciphertext []byte, starting_block uint32, offset byte) []byte {
+	if len(ciphertext) < 128 {
+		panic("Arrays to XOR aren't long enough")
 	}
 
-	return utils.Xor_arrays_prefix(ciphertext, pad_offset, 128)
+	// the pad of the 9 blocks the 128 bytes may span
+	var pad [144]byte
+	xorKeystream(cachedRoundKeys(key), iv, pad[:], pad[:], starting_block)
+
+	return utils.Xor_arrays_prefix(ciphertext, pad[offset:], 128)
 }
 
 // The following functions are from the aes example file from xJsnark
@@ -79,9 +58,19 @@ var SBOX = []uint8{0x63, 0x7c, 0x77, 0x7b, 0xf2, 0x6b, 0x6f, 0xc5, 0x30, 0x01, 0
 
 var RCON = []uint8{0x8d, 0x01, 0x02, 0x04, 0x08, 0x10, 0x20, 0x40, 0x80, 0x1b, 0x36}
 var nb = 4
-var nk = 4
 
+// key_words returns nk, the number of 32-bit words of a key of 16, 24 or 32 bytes. The reference AES takes the
+// three sizes, the T-table path of GCM and CCM only 16 bytes, see cachedRoundKeys.
+func key_words(key []byte) int {
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		panic("aesgcm: AES keys are 16, 24 or 32 bytes")
+	}
+	return len(key) / 4
+}
+
+// expandKey expands an AES-128, AES-192 or AES-256 key into the round keys of its 10, 12 or 14 rounds
 func expandKey(key []byte) []byte {
+	nk := key_words(key)
 	nr := nk + 6
 	expandedKey := make([]byte, nb*(nr+1)*4)
 	w := make([][]byte, nb*(nr+1))
@@ -100,7 +89,10 @@ func expandKey(key []byte) []byte {
 		if i%nk == 0 {
 			tmp = rotWord(tmp)
 			tmp = subWord(tmp)
-			tmp[0] = tmp[0] ^ byte(RCON[i/4])
+			tmp[0] = tmp[0] ^ byte(RCON[i/nk])
+		} else if nk > 6 && i%nk == 4 {
+			// AES-256 substitutes the middle word of each 8 too
+			tmp = subWord(tmp)
 		}
 		for v := 0; v < 4; v++ {
 			w[i][v] = w[i-nk][v] ^ tmp[v]
@@ -230,7 +222,7 @@ func encrypt_expanded(expandedKey []byte, plaintext []byte) []byte {
 		}
 	}
 	state = addRoundkey(expandedKey, state, 0, 3)
-	nr := 6 + nk
+	nr := len(expandedKey)/16 - 1
 	for round := 1; round < nr; round++ {
 		state = subState(state)
 		state = shiftRows(state)
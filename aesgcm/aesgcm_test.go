@@ -0,0 +1,256 @@
+package aesgcm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// The AES-128 examples of FIPS-197, appendices B and C.1
+func TestFIPS197(t *testing.T) {
+	for _, v := range []struct{ key, plaintext, ciphertext string }{
+		{"2b7e151628aed2a6abf7158809cf4f3c", "3243f6a8885a308d313198a2e0370734", "3925841d02dc09fbdc118597196a0b32"},
+		{"000102030405060708090a0b0c0d0e0f", "00112233445566778899aabbccddeeff", "69c4e0d86a7b0430d8cdb78070b4c55a"},
+	} {
+		if got := aes_encrypt(unhex(v.key), unhex(v.plaintext)); hex.EncodeToString(got) != v.ciphertext {
+			t.Errorf("key %s: got %x, want %s", v.key, got, v.ciphertext)
+		}
+	}
+}
+
+// The AES-128 test cases with 96-bit IVs of the GCM specification, as used to validate SP 800-38D
+// implementations: the package computes the ciphertext, not the tag
+func TestGCMVectors(t *testing.T) {
+	for _, v := range []struct{ key, iv, plaintext, ciphertext string }{
+		{"00000000000000000000000000000000", "000000000000000000000000", "00000000000000000000000000000000",
+			"0388dace60b6a392f328c2b971b2fe78"},
+		{"feffe9928665731c6d6a8f9467308308", "cafebabefacedbaddecaf888",
+			"d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255",
+			"42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091473f5985"},
+		{"feffe9928665731c6d6a8f9467308308", "cafebabefacedbaddecaf888",
+			"d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a721c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b39",
+			"42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091"},
+	} {
+		key, iv, plaintext := unhex(v.key), unhex(v.iv), unhex(v.plaintext)
+		if got := AES_GCM_encrypt(key, iv, plaintext, 0); hex.EncodeToString(got) != v.ciphertext {
+			t.Errorf("key %s, %d bytes: got %x, want %s", v.key, len(plaintext), got, v.ciphertext)
+		}
+		if got := AES_GCM_decrypt(key, iv, unhex(v.ciphertext), 0); !bytes.Equal(got, plaintext) {
+			t.Errorf("key %s, %d bytes: decrypted %x", v.key, len(plaintext), got)
+		}
+	}
+}
+
+// keystream returns n bytes of the GCM keystream from block number starting_block of the record,
+// with crypto/cipher: the first block of a record is encrypted with the counter 2, after the one of the tag
+func keystream(t *testing.T, key, iv []byte, starting_block uint32, n int) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := make([]byte, 16)
+	copy(counter, iv)
+	binary.BigEndian.PutUint32(counter[12:], starting_block+2)
+	stream := make([]byte, n)
+	cipher.NewCTR(block, counter).XORKeyStream(stream, stream)
+	return stream
+}
+
+func xor(a, b []byte) []byte {
+	c := make([]byte, len(a))
+	for i := range a {
+		c[i] = a[i] ^ b[i]
+	}
+	return c
+}
+
+// sized returns b truncated or padded with zeros to n bytes
+func sized(b []byte, n int) []byte {
+	c := make([]byte, n)
+	copy(c, b)
+	return c
+}
+
+func FuzzAESGCM(f *testing.F) {
+	f.Add(unhex("feffe9928665731c6d6a8f9467308308"), unhex("cafebabefacedbaddecaf888"), []byte("hello world"), uint32(0))
+	f.Add(make([]byte, 16), make([]byte, 12), make([]byte, 100), uint32(7))
+	// past the 256 blocks of a byte counter
+	f.Add(make([]byte, 16), make([]byte, 12), make([]byte, 100), uint32(250))
+	f.Fuzz(func(t *testing.T, key, iv, plaintext []byte, starting_block uint32) {
+		key, iv = sized(key, 16), sized(iv, 12)
+		// the package counts blocks with 32 bits, from 2, and crypto/cipher would carry into the iv
+		blocks := (len(plaintext) + 15) / 16
+		if uint64(starting_block)+uint64(blocks)+2 > 1<<32 {
+			return
+		}
+		got := AES_GCM_encrypt(key, iv, plaintext, starting_block)
+		if want := xor(plaintext, keystream(t, key, iv, starting_block, len(plaintext))); !bytes.Equal(got, want) {
+			t.Fatalf("from block %d: got %x, want %x", starting_block, got, want)
+		}
+		if starting_block == 0 {
+			block, _ := aes.NewCipher(key)
+			gcm, _ := cipher.NewGCM(block)
+			if sealed := gcm.Seal(nil, iv, plaintext, nil); !bytes.Equal(got, sealed[:len(plaintext)]) {
+				t.Fatalf("got %x, crypto/cipher seals %x", got, sealed)
+			}
+		}
+		if pt := AES_GCM_decrypt(key, iv, got, starting_block); !bytes.Equal(pt, plaintext) {
+			t.Fatalf("decrypted %x, want %x", pt, plaintext)
+		}
+	})
+}
+
+func FuzzAESGCMDecrypt128BytesMiddle(f *testing.F) {
+	f.Add(make([]byte, 16), make([]byte, 12), make([]byte, 128), uint32(3), byte(5))
+	f.Add(make([]byte, 16), make([]byte, 12), make([]byte, 128), uint32(250), byte(7))
+	// the last block the tail of tls can start in, whose 9 blocks end with the counter 2^32 - 1
+	f.Add(make([]byte, 16), make([]byte, 12), make([]byte, 128), uint32(math.MaxUint32-10), byte(16))
+	f.Fuzz(func(t *testing.T, key, iv, plaintext []byte, starting_block uint32, offset byte) {
+		key, iv, plaintext = sized(key, 16), sized(iv, 12), sized(plaintext, 128)
+		// the 128 bytes from offset in block starting_block, within the 9 blocks decrypted
+		offset %= 17
+		if uint64(starting_block)+9+2 > 1<<32 {
+			return
+		}
+		stream := keystream(t, key, iv, starting_block, 144)
+		ciphertext := xor(plaintext, stream[offset:])
+		if got := AES_GCM_decrypt_128bytes_middle(key, iv, ciphertext, starting_block, offset); !bytes.Equal(got, plaintext) {
+			t.Fatalf("block %d offset %d: got %x, want %x", starting_block, offset, got, plaintext)
+		}
+	})
+}
+
+// referenceEncrypt is AES_GCM_encrypt with the reference AES, as it was before the T-tables
+func referenceEncrypt(key, iv, plaintext []byte, starting_block uint32) []byte {
+	expandedKey := expandKey(key)
+	output := make([]byte, 0)
+	for i := 0; i < (len(plaintext)+15)/16; i++ {
+		output = append(output, encrypt_expanded(expandedKey, get_block_iv_32(iv, uint32(i)+starting_block))...)
+	}
+	return xor(plaintext, output)
+}
+
+func FuzzTTables(f *testing.F) {
+	f.Add(unhex("2b7e151628aed2a6abf7158809cf4f3c"), unhex("3243f6a8885a308d313198a2e0370734"), make([]byte, 12), []byte("hi"), uint32(0))
+	// the counter carries into its second byte, and wraps around
+	f.Add(make([]byte, 16), make([]byte, 16), make([]byte, 12), make([]byte, 100), uint32(250))
+	f.Add(make([]byte, 16), make([]byte, 16), make([]byte, 12), make([]byte, 100), uint32(math.MaxUint32-4))
+	f.Fuzz(func(t *testing.T, key, block, iv, plaintext []byte, starting_block uint32) {
+		key, block, iv = sized(key, 16), sized(block, 16), sized(iv, 12)
+
+		got := make([]byte, 16)
+		expandRoundKeys(key).encryptBlock(got, block)
+		if want := aes_encrypt(key, block); !bytes.Equal(got, want) {
+			t.Fatalf("block: got %x, want %x", got, want)
+		}
+		if got, want := AES_GCM_encrypt(key, iv, plaintext, starting_block), referenceEncrypt(key, iv, plaintext, starting_block); !bytes.Equal(got, want) {
+			t.Fatalf("from block %d: got %x, want %x", starting_block, got, want)
+		}
+	})
+}
+
+func TestNoAllocationPerBlock(t *testing.T) {
+	key, iv := counting(16), counting(12)
+	plaintext, output := make([]byte, 1024), make([]byte, 1024)
+	rk := cachedRoundKeys(key)
+	if n := testing.AllocsPerRun(100, func() { xorKeystream(rk, iv, output, plaintext, 0) }); n != 0 {
+		t.Fatalf("%v allocations for 64 blocks", n)
+	}
+	// the expanded key is cached, only the output is allocated
+	if n := testing.AllocsPerRun(100, func() { AES_GCM_encrypt(key, iv, plaintext, 0) }); n != 1 {
+		t.Fatalf("%v allocations for AES_GCM_encrypt", n)
+	}
+}
+
+func counting(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+func BenchmarkBlock(b *testing.B) {
+	key, block := counting(16), counting(16)
+	b.Run("reference", func(b *testing.B) {
+		expandedKey := expandKey(key)
+		for i := 0; i < b.N; i++ {
+			encrypt_expanded(expandedKey, block)
+		}
+	})
+	b.Run("ttables", func(b *testing.B) {
+		rk := expandRoundKeys(key)
+		for i := 0; i < b.N; i++ {
+			rk.encryptBlock(block, block)
+		}
+	})
+}
+
+// a 1 KiB record, and the tail of the server extensions of the HS shortcut
+func BenchmarkAESGCM(b *testing.B) {
+	key, iv, record := counting(16), counting(12), make([]byte, 1024)
+	b.Run("reference", func(b *testing.B) {
+		b.SetBytes(int64(len(record)))
+		for i := 0; i < b.N; i++ {
+			referenceEncrypt(key, iv, record, 0)
+		}
+	})
+	b.Run("ttables", func(b *testing.B) {
+		b.SetBytes(int64(len(record)))
+		for i := 0; i < b.N; i++ {
+			AES_GCM_encrypt(key, iv, record, 0)
+		}
+	})
+	b.Run("128bytes_middle/reference", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pad := referenceEncrypt(key, iv, make([]byte, 144), 5)
+			xor(record[:128], pad[3:])
+		}
+	})
+	b.Run("128bytes_middle/ttables", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			AES_GCM_decrypt_128bytes_middle(key, iv, record, 5, 3)
+		}
+	})
+}
+
+// mustPanic fails the test unless f panics
+func mustPanic(t *testing.T, name string, f func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s: no panic", name)
+		}
+	}()
+	f()
+}
+
+// The keystream functions only run AES-128, and refuse longer keys rather than use their first 16 bytes
+func TestKeyLength(t *testing.T) {
+	iv, data := counting(12), make([]byte, 128)
+	for _, n := range []int{0, 15, 17, 24, 32} {
+		key := counting(n)
+		mustPanic(t, fmt.Sprintf("AES_GCM_encrypt with a %d-byte key", n), func() { AES_GCM_encrypt(key, iv, data, 0) })
+		mustPanic(t, fmt.Sprintf("AES_GCM_decrypt with a %d-byte key", n), func() { AES_GCM_decrypt(key, iv, data, 0) })
+		mustPanic(t, fmt.Sprintf("AES_GCM_decrypt_128bytes_middle with a %d-byte key", n), func() {
+			AES_GCM_decrypt_128bytes_middle(key, iv, data, 0, 3)
+		})
+		mustPanic(t, fmt.Sprintf("AES_CCM_seal with a %d-byte key", n), func() { AES_CCM_seal(key, iv, data, nil, 16) })
+		mustPanic(t, fmt.Sprintf("AES_CCM_decrypt_128bytes_middle with a %d-byte key", n), func() {
+			AES_CCM_decrypt_128bytes_middle(key, iv, data, 0, 3)
+		})
+	}
+}
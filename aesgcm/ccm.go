@@ -0,0 +1,194 @@
+package aesgcm
+
+import (
+	"crypto/subtle"
+	"errors"
+)
+
+// AES-CCM (NIST SP 800-38C, RFC 3610) for TLS_AES_128_CCM_SHA256 and TLS_AES_128_CCM_8_SHA256, whose tags are
+// 16 and 8 bytes. The nonce N of n bytes, 12 in TLS, leaves L = 15 - n bytes to count the blocks: the counter
+// block i is the byte L - 1, N and i on L bytes. As with GCM, the counter block 0 masks the tag and block i of
+// the record is encrypted with the counter i + 1. The tag is a CBC-MAC of the length of the message, the
+// additional data and the plaintext, under the same key.
+
+// ccm_counter_bytes returns L, the number of bytes of the counter of a nonce
+func ccm_counter_bytes(nonce []byte) int {
+	if len(nonce) < 7 || len(nonce) > 13 {
+		panic("aesgcm: CCM nonces are 7 to 13 bytes")
+	}
+	return 15 - len(nonce)
+}
+
+func check_ccm_tag_length(tag_length int) {
+	if tag_length < 4 || tag_length > 16 || tag_length%2 != 0 {
+		panic("aesgcm: CCM tags are 4, 6, 8, 10, 12, 14 or 16 bytes")
+	}
+}
+
+// ccm_counter writes the counter block i of the nonce into block
+func ccm_counter(block *[16]byte, nonce []byte, i uint64) {
+	L := ccm_counter_bytes(nonce)
+	block[0] = byte(L - 1)
+	copy(block[1:], nonce)
+	for k := 0; k < L; k++ {
+		block[15-k] = byte(i >> (8 * k))
+	}
+}
+
+// xor_ccm_keystream xors src with the keystream from byte offset of the counter block counter into dst, which
+// must be as long as src. It panics if the counter would not fit in its L bytes.
+func xor_ccm_keystream(rk *roundKeys, nonce []byte, dst, src []byte, counter uint64, offset int) {
+	L := ccm_counter_bytes(nonce)
+	if blocks := uint64(offset+len(src)+15) / 16; L < 8 && counter+blocks > 1<<(8*L) {
+		panic("aesgcm: the CCM counter wraps around")
+	}
+	var block, pad [16]byte
+	for len(src) > 0 {
+		ccm_counter(&block, nonce, counter)
+		rk.encryptBlock(pad[:], block[:])
+		n := len(src)
+		if n > 16-offset {
+			n = 16 - offset
+		}
+		for j := 0; j < n; j++ {
+			dst[j] = src[j] ^ pad[offset+j]
+		}
+		dst, src = dst[n:], src[n:]
+		offset = 0
+		counter++
+	}
+}
+
+// AES_CCM_encrypt encrypts plaintext from block number starting_block of a record, block 0 being encrypted
+// with the counter 1. It only applies the keystream, AES_CCM_seal also computes the tag.
+func AES_CCM_encrypt(key, nonce, plaintext []byte, starting_block uint64) []byte {
+	return AES_CCM_decrypt_middle(key, nonce, plaintext, starting_block, 0)
+}
+
+func AES_CCM_decrypt(key, nonce, ciphertext []byte, starting_block uint64) []byte {
+	return AES_CCM_decrypt_middle(key, nonce, ciphertext, starting_block, 0)
+}
+
+// AES_CCM_decrypt_middle decrypts ciphertext with the keystream from byte offset, below 16, of block number
+// starting_block of the record
+func AES_CCM_decrypt_middle(key, nonce, ciphertext []byte, starting_block uint64, offset byte) []byte {
+	if offset >= 16 {
+		panic("aesgcm: the offset is past the block")
+	}
+	output := make([]byte, len(ciphertext))
+	xor_ccm_keystream(cachedRoundKeys(key), nonce, output, ciphertext, starting_block+1, int(offset))
+	return output
+}
+
+// AES_CCM_decrypt_128bytes_middle is AES_GCM_decrypt_128bytes_middle for CCM: it decrypts the first 128 bytes
+// of ciphertext from byte offset of block number starting_block, the window of the tail of the server
+// extensions in the HS shortcut
+func AES_CCM_decrypt_128bytes_middle(key, nonce, ciphertext []byte, starting_block uint64, offset byte) []byte {
+	if len(ciphertext) < 128 {
+		panic("Arrays to XOR aren't long enough")
+	}
+	return AES_CCM_decrypt_middle(key, nonce, ciphertext[:128], starting_block, offset)
+}
+
+// ccm_mac returns the CBC-MAC of the blocks B_0 (the flags, the nonce and the length of plaintext), the length
+// of aad then aad, and plaintext, aad and plaintext being padded with zeros to whole blocks
+func ccm_mac(rk *roundKeys, nonce, aad, plaintext []byte, tag_length int) [16]byte {
+	L := ccm_counter_bytes(nonce)
+	if L < 8 && uint64(len(plaintext)) >= 1<<(8*L) {
+		panic("aesgcm: the CCM plaintext is too long for the nonce")
+	}
+	var y [16]byte
+	y[0] = byte(tag_length-2) / 2 << 3
+	y[0] |= byte(L - 1)
+	if len(aad) > 0 {
+		y[0] |= 1 << 6
+	}
+	copy(y[1:], nonce)
+	for k := 0; k < L; k++ {
+		y[15-k] = byte(uint64(len(plaintext)) >> (8 * k))
+	}
+	rk.encryptBlock(y[:], y[:])
+
+	// absorb xors data, padded with zeros, into the chain block by block
+	absorb := func(data []byte) {
+		for len(data) > 0 {
+			n := copy_xor(y[:], data)
+			data = data[n:]
+			rk.encryptBlock(y[:], y[:])
+		}
+	}
+	if len(aad) > 0 {
+		// the length of aad on 2 bytes below 2^16 - 2^8, else 0xfffe and 4 bytes, or 0xffff and 8 bytes
+		var length []byte
+		a := uint64(len(aad))
+		switch {
+		case a < 1<<16-1<<8:
+			length = []byte{byte(a >> 8), byte(a)}
+		case a < 1<<32:
+			length = []byte{0xff, 0xfe, byte(a >> 24), byte(a >> 16), byte(a >> 8), byte(a)}
+		default:
+			length = []byte{0xff, 0xff, byte(a >> 56), byte(a >> 48), byte(a >> 40), byte(a >> 32), byte(a >> 24), byte(a >> 16), byte(a >> 8), byte(a)}
+		}
+		absorb(append(length, aad...))
+	}
+	absorb(plaintext)
+	return y
+}
+
+// copy_xor xors the first bytes of data, at most 16, into block and returns their number
+func copy_xor(block []byte, data []byte) int {
+	n := len(data)
+	if n > 16 {
+		n = 16
+	}
+	for j := 0; j < n; j++ {
+		block[j] ^= data[j]
+	}
+	return n
+}
+
+// AES_CCM_tag returns the tag of tag_length bytes of a record of plaintext and aad: its CBC-MAC, masked
+// with the keystream of the counter 0
+func AES_CCM_tag(key, nonce, aad, plaintext []byte, tag_length int) []byte {
+	check_ccm_tag_length(tag_length)
+	rk := cachedRoundKeys(key)
+	mac := ccm_mac(rk, nonce, aad, plaintext, tag_length)
+	tag := make([]byte, tag_length)
+	xor_ccm_keystream(rk, nonce, tag, mac[:tag_length], 0, 0)
+	return tag
+}
+
+// AES_CCM_seal returns the ciphertext of plaintext followed by its tag of tag_length bytes,
+// 16 for TLS_AES_128_CCM_SHA256 and 8 for TLS_AES_128_CCM_8_SHA256
+func AES_CCM_seal(key, nonce, plaintext, aad []byte, tag_length int) []byte {
+	tag := AES_CCM_tag(key, nonce, aad, plaintext, tag_length)
+	return append(AES_CCM_encrypt(key, nonce, plaintext, 0), tag...)
+}
+
+var ErrCCMOpen = errors.New("aesgcm: the CCM tag does not match")
+
+// AES_CCM_open decrypts sealed, the ciphertext followed by a tag of tag_length bytes, and returns the
+// plaintext if the tag authenticates it and aad
+func AES_CCM_open(key, nonce, sealed, aad []byte, tag_length int) ([]byte, error) {
+	check_ccm_tag_length(tag_length)
+	if len(sealed) < tag_length {
+		return nil, ErrCCMOpen
+	}
+	ciphertext, tag := sealed[:len(sealed)-tag_length], sealed[len(sealed)-tag_length:]
+	plaintext := AES_CCM_decrypt(key, nonce, ciphertext, 0)
+	if subtle.ConstantTimeCompare(AES_CCM_tag(key, nonce, aad, plaintext, tag_length), tag) != 1 {
+		return nil, ErrCCMOpen
+	}
+	return plaintext, nil
+}
+
+// TLS_nonce returns the nonce of the record of sequence number seq, for GCM and CCM alike: the 12-byte iv
+// xored with seq, big-endian and padded on the left with zeros (RFC 8446 section 5.3)
+func TLS_nonce(iv []byte, seq uint64) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, iv[:12])
+	for i := 0; i < 8; i++ {
+		nonce[11-i] ^= byte(seq >> (8 * i))
+	}
+	return nonce
+}
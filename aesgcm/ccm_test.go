@@ -0,0 +1,135 @@
+package aesgcm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+// The examples of NIST SP 800-38C, appendix C, and packet vector #1 of RFC 3610, with their 7 to 13-byte
+// nonces and 4 to 8-byte tags: the ciphertext is followed by the tag
+func TestCCMVectors(t *testing.T) {
+	for i, v := range []struct {
+		key, nonce, aad, plaintext, sealed string
+		tag_length                         int
+	}{
+		{"404142434445464748494a4b4c4d4e4f", "10111213141516", "0001020304050607", "20212223", "7162015b4dac255d", 4},
+		{"404142434445464748494a4b4c4d4e4f", "1011121314151617", "000102030405060708090a0b0c0d0e0f",
+			"202122232425262728292a2b2c2d2e2f", "d2a1f0e051ea5f62081a7792073d593d1fc64fbfaccd", 6},
+		{"404142434445464748494a4b4c4d4e4f", "101112131415161718191a1b", "000102030405060708090a0b0c0d0e0f10111213",
+			"202122232425262728292a2b2c2d2e2f3031323334353637", "e3b201a9f5b71a7a9b1ceaeccd97e70b6176aad9a4428aa5484392fbc1b09951", 8},
+		{"c0c1c2c3c4c5c6c7c8c9cacbcccdcecf", "00000003020100a0a1a2a3a4a5", "0001020304050607",
+			"08090a0b0c0d0e0f101112131415161718191a1b1c1d1e", "588c979a61c663d2f066d0c2c0f989806d5f6b61dac38417e8d12cfdf926e0", 8},
+	} {
+		key, nonce, aad, plaintext := unhex(v.key), unhex(v.nonce), unhex(v.aad), unhex(v.plaintext)
+		sealed := AES_CCM_seal(key, nonce, plaintext, aad, v.tag_length)
+		if hex.EncodeToString(sealed) != v.sealed {
+			t.Errorf("example %d: got %x, want %s", i+1, sealed, v.sealed)
+		}
+		if got, err := AES_CCM_open(key, nonce, unhex(v.sealed), aad, v.tag_length); err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("example %d: opened %x, %v", i+1, got, err)
+		}
+	}
+}
+
+// referenceCCM seals with crypto/cipher: the CBC-MAC is the last block of the CBC encryption of the blocks
+// with a zero IV, and the encryption CTR from the counter block 0, the first block masking the tag
+func referenceCCM(t *testing.T, key, nonce, plaintext, aad []byte, tag_length int) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	L := 15 - len(nonce)
+	b := make([]byte, 16)
+	b[0] = byte(L-1) | byte((tag_length-2)/2)<<3
+	if len(aad) > 0 {
+		b[0] |= 0x40
+	}
+	copy(b[1:], nonce)
+	for k := 0; k < L; k++ {
+		b[15-k] = byte(len(plaintext) >> (8 * k))
+	}
+	if len(aad) > 0 {
+		// the tests only use additional data below 2^16 - 2^8 bytes
+		b = append(b, sized(append([]byte{byte(len(aad) >> 8), byte(len(aad))}, aad...), (len(aad)+2+15)/16*16)...)
+	}
+	b = append(b, sized(plaintext, (len(plaintext)+15)/16*16)...)
+	cipher.NewCBCEncrypter(block, make([]byte, 16)).CryptBlocks(b, b)
+	mac := b[len(b)-16:]
+
+	counter := make([]byte, 16)
+	counter[0] = byte(L - 1)
+	copy(counter[1:], nonce)
+	stream := make([]byte, 16+len(plaintext))
+	cipher.NewCTR(block, counter).XORKeyStream(stream, stream)
+	return append(xor(plaintext, stream[16:]), xor(mac[:tag_length], stream[:tag_length])...)
+}
+
+func FuzzAESCCM(f *testing.F) {
+	f.Add(unhex("404142434445464748494a4b4c4d4e4f"), unhex("101112131415161718191a1b"), unhex("000102030405060708090a0b0c0d0e0f10111213"),
+		unhex("202122232425262728292a2b2c2d2e2f3031323334353637"), true)
+	f.Add(make([]byte, 16), make([]byte, 12), []byte{}, []byte{}, false)
+	f.Add(make([]byte, 16), make([]byte, 12), []byte{23, 3, 3, 0, 100}, make([]byte, 92), false)
+	f.Fuzz(func(t *testing.T, key, iv, aad, plaintext []byte, ccm8 bool) {
+		key, nonce := sized(key, 16), sized(iv, 12)
+		if len(aad) >= 1<<16-1<<8 {
+			return
+		}
+		tag_length := 16
+		if ccm8 {
+			tag_length = 8
+		}
+		sealed := AES_CCM_seal(key, nonce, plaintext, aad, tag_length)
+		if want := referenceCCM(t, key, nonce, plaintext, aad, tag_length); !bytes.Equal(sealed, want) {
+			t.Fatalf("got %x, crypto/cipher gives %x", sealed, want)
+		}
+		if got, err := AES_CCM_open(key, nonce, sealed, aad, tag_length); err != nil || !bytes.Equal(got, plaintext) {
+			t.Fatalf("opened %x, %v", got, err)
+		}
+		sealed[len(sealed)/2] ^= 1
+		if _, err := AES_CCM_open(key, nonce, sealed, aad, tag_length); err == nil {
+			t.Fatal("a corrupted record is opened")
+		}
+	})
+}
+
+func FuzzAESCCMDecrypt128BytesMiddle(f *testing.F) {
+	f.Add(make([]byte, 16), make([]byte, 12), make([]byte, 128), uint32(3), byte(5))
+	// past the 256 blocks aesgcm counts for GCM
+	f.Add(make([]byte, 16), make([]byte, 12), make([]byte, 128), uint32(300), byte(15))
+	f.Fuzz(func(t *testing.T, key, iv, plaintext []byte, starting_block uint32, offset byte) {
+		key, nonce, plaintext := sized(key, 16), sized(iv, 12), sized(plaintext, 128)
+		offset %= 16
+		// the 9 blocks from starting_block, with the counters starting_block + 1 to starting_block + 9 on 3 bytes
+		if starting_block+10 > 1<<24 {
+			return
+		}
+		stream := referenceCCM(t, key, nonce, make([]byte, 16*(int(starting_block)+9)), nil, 16)[16*starting_block:]
+		ciphertext := xor(plaintext, stream[offset:])
+		if got := AES_CCM_decrypt_128bytes_middle(key, nonce, ciphertext, uint64(starting_block), offset); !bytes.Equal(got, plaintext) {
+			t.Fatalf("block %d offset %d: got %x, want %x", starting_block, offset, got, plaintext)
+		}
+	})
+}
+
+func TestCCMCounterWrapsAround(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic past the last counter")
+		}
+	}()
+	// a 13-byte nonce leaves 2 bytes of counter, the block 2^16 - 2 has the last one
+	AES_CCM_encrypt(make([]byte, 16), make([]byte, 13), make([]byte, 17), 1<<16-2)
+}
+
+func TestTLSNonce(t *testing.T) {
+	iv := unhex("5d313eb2671276ee13000b30")
+	if got := TLS_nonce(iv, 0); !bytes.Equal(got, iv) {
+		t.Errorf("record 0: got %x, want the iv", got)
+	}
+	if got, want := TLS_nonce(iv, 0x0102030405060708), "5d313eb2661075ea16060c38"; hex.EncodeToString(got) != want {
+		t.Errorf("got %x, want %s", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package aesgcm
+
+// The inverse cipher of FIPS-197 section 5.3 on the reference AES: the steps of encrypt_expanded undone in
+// reverse order, with the round keys of expandKey taken from the last round to the first. GCM and CCM only
+// need the forward cipher, this is for the modes and test vectors which decrypt blocks, such as ECB and CBC.
+
+// INV_SBOX is the inverse of SBOX
+var INV_SBOX = invert_sbox()
+
+func invert_sbox() []uint8 {
+	inv := make([]uint8, 256)
+	for x, s := range SBOX {
+		inv[s] = uint8(x)
+	}
+	return inv
+}
+
+func invSubState(state [][]byte) [][]byte {
+	newState := make([][]byte, len(state))
+	for i := 0; i < len(state); i++ {
+		newState[i] = make([]byte, len(state[i]))
+		for j := 0; j < len(state[i]); j++ {
+			newState[i][j] = INV_SBOX[state[i][j]]
+		}
+	}
+	return newState
+}
+
+// invShiftRows rotates row i right by i bytes, where shiftRows rotates it left
+func invShiftRows(state [][]byte) [][]byte {
+	newState := make([][]byte, len(state))
+	for i := 0; i < len(state); i++ {
+		newState[i] = make([]byte, len(state[i]))
+		for j := 0; j < len(state[i]); j++ {
+			newState[i][(j+i)%len(state[i])] = state[i][j]
+		}
+	}
+	return newState
+}
+
+// invMixColumns multiplies each column by the inverse of the matrix of mixColumns, with rows 0e 0b 0d 09
+// and their rotations
+func invMixColumns(state [][]byte) [][]byte {
+	a := make([]byte, 4)
+	newState := make([][]byte, len(state))
+	for i := 0; i < len(state); i++ {
+		newState[i] = make([]byte, len(state[i]))
+	}
+
+	for c := 0; c < 4; c++ {
+		for i := 0; i < 4; i++ {
+			a[i] = state[i][c]
+		}
+		newState[0][c] = gal_mul_const(a[0], 14) ^ gal_mul_const(a[1], 11) ^ gal_mul_const(a[2], 13) ^ gal_mul_const(a[3], 9)
+		newState[1][c] = gal_mul_const(a[0], 9) ^ gal_mul_const(a[1], 14) ^ gal_mul_const(a[2], 11) ^ gal_mul_const(a[3], 13)
+		newState[2][c] = gal_mul_const(a[0], 13) ^ gal_mul_const(a[1], 9) ^ gal_mul_const(a[2], 14) ^ gal_mul_const(a[3], 11)
+		newState[3][c] = gal_mul_const(a[0], 11) ^ gal_mul_const(a[1], 13) ^ gal_mul_const(a[2], 9) ^ gal_mul_const(a[3], 14)
+	}
+	return newState
+}
+
+func aes_decrypt(key []byte, ciphertext []byte) []byte {
+	expandedKey := expandKey(key)
+	return decrypt_expanded(expandedKey, ciphertext)
+}
+
+func decrypt_expanded(expandedKey []byte, ciphertext []byte) []byte {
+	plaintext := make([]byte, len(ciphertext))
+	state := make([][]byte, 4)
+	for i := 0; i < 4; i++ {
+		state[i] = []byte{0, 0, 0, 0}
+	}
+
+	idx := 0
+	for j := 0; j < 4; j++ {
+		for k := 0; k < 4; k++ {
+			state[k][j] = ciphertext[idx]
+			idx++
+		}
+	}
+	nr := len(expandedKey)/16 - 1
+	state = addRoundkey(expandedKey, state, nr*4*4, (nr+1)*4*4-1)
+	for round := nr - 1; round > 0; round-- {
+		state = invShiftRows(state)
+		state = invSubState(state)
+		state = addRoundkey(expandedKey, state, round*4*4, (round+1)*4*4-1)
+		state = invMixColumns(state)
+	}
+	state = invShiftRows(state)
+	state = invSubState(state)
+	state = addRoundkey(expandedKey, state, 0, 3)
+
+	idx = 0
+	for j := 0; j < 4; j++ {
+		for k := 0; k < 4; k++ {
+			plaintext[idx] = state[k][j]
+			idx++
+		}
+	}
+	return plaintext
+}
+
+func check_block(block []byte) {
+	if len(block) != 16 {
+		panic("aesgcm: AES blocks are 16 bytes")
+	}
+}
+
+// AES_encrypt_block encrypts a 16-byte block under a key of 16, 24 or 32 bytes
+func AES_encrypt_block(key, block []byte) []byte {
+	check_block(block)
+	return aes_encrypt(key, block)
+}
+
+// AES_decrypt_block decrypts a 16-byte block under a key of 16, 24 or 32 bytes
+func AES_decrypt_block(key, block []byte) []byte {
+	check_block(block)
+	return aes_decrypt(key, block)
+}
@@ -0,0 +1,105 @@
+package aesgcm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+// The last round key of the key expansion examples of FIPS-197, appendix A
+func TestFIPS197KeyExpansion(t *testing.T) {
+	for _, v := range []struct{ key, last string }{
+		{"2b7e151628aed2a6abf7158809cf4f3c", "b6630ca6"},
+		{"8e73b0f7da0e6452c810f32b809079e562f8ead2522c6b7b", "01002202"},
+		{"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", "706c631e"},
+	} {
+		expandedKey := expandKey(unhex(v.key))
+		if got := hex.EncodeToString(expandedKey[len(expandedKey)-4:]); got != v.last {
+			t.Errorf("key %s: last word %s, want %s", v.key, got, v.last)
+		}
+	}
+}
+
+// The examples of FIPS-197, appendices B and C.1 to C.3, both ways
+func TestFIPS197Inverse(t *testing.T) {
+	for _, v := range []struct{ key, plaintext, ciphertext string }{
+		{"2b7e151628aed2a6abf7158809cf4f3c", "3243f6a8885a308d313198a2e0370734", "3925841d02dc09fbdc118597196a0b32"},
+		{"000102030405060708090a0b0c0d0e0f", "00112233445566778899aabbccddeeff", "69c4e0d86a7b0430d8cdb78070b4c55a"},
+		{"000102030405060708090a0b0c0d0e0f1011121314151617", "00112233445566778899aabbccddeeff", "dda97ca4864cdfe06eaf70a0ec0d7191"},
+		{"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", "00112233445566778899aabbccddeeff",
+			"8ea2b7ca516745bfeafc49904b496089"},
+	} {
+		key := unhex(v.key)
+		if got := AES_encrypt_block(key, unhex(v.plaintext)); hex.EncodeToString(got) != v.ciphertext {
+			t.Errorf("key %s: encrypted %x, want %s", v.key, got, v.ciphertext)
+		}
+		if got := AES_decrypt_block(key, unhex(v.ciphertext)); hex.EncodeToString(got) != v.plaintext {
+			t.Errorf("key %s: decrypted %x, want %s", v.key, got, v.plaintext)
+		}
+	}
+}
+
+func TestInverseSBOX(t *testing.T) {
+	for x := 0; x < 256; x++ {
+		if INV_SBOX[SBOX[x]] != byte(x) {
+			t.Fatalf("INV_SBOX[SBOX[%#02x]] = %#02x", x, INV_SBOX[SBOX[x]])
+		}
+	}
+}
+
+func FuzzInverseCipher(f *testing.F) {
+	f.Add(counting(16), counting(16))
+	f.Add(counting(24), make([]byte, 16))
+	f.Add(counting(32), bytes.Repeat([]byte{0xff}, 16))
+	f.Fuzz(func(t *testing.T, key, block []byte) {
+		// the key size follows the length of the fuzzed key
+		key = sized(key, []int{16, 24, 32}[len(key)%3])
+		block = sized(block, 16)
+		c, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]byte, 16)
+		c.Encrypt(want, block)
+		if got := AES_encrypt_block(key, block); !bytes.Equal(got, want) {
+			t.Fatalf("%d-byte key: encrypted %x, crypto/aes gives %x", len(key), got, want)
+		}
+		c.Decrypt(want, block)
+		if got := AES_decrypt_block(key, block); !bytes.Equal(got, want) {
+			t.Fatalf("%d-byte key: decrypted %x, crypto/aes gives %x", len(key), got, want)
+		}
+	})
+}
+
+// panics reports whether f panics
+func panics(f func()) (panicked bool) {
+	defer func() { panicked = recover() != nil }()
+	f()
+	return false
+}
+
+// The reference AES takes keys of 16, 24 and 32 bytes and the T-table path only 16: both refuse the other
+// lengths, and agree on the keys they both take
+func TestKeyPaths(t *testing.T) {
+	block := counting(16)
+	for n := 0; n <= 40; n++ {
+		key := counting(n)
+		var reference, ttables []byte
+		referenceFails := panics(func() { reference = aes_encrypt(key, block) })
+		ttablesFail := panics(func() {
+			ttables = make([]byte, 16)
+			cachedRoundKeys(key).encryptBlock(ttables, block)
+		})
+		if want := n != 16 && n != 24 && n != 32; referenceFails != want {
+			t.Errorf("%d-byte key: the reference AES panics: %v", n, referenceFails)
+		}
+		if want := n != 16; ttablesFail != want {
+			t.Errorf("%d-byte key: the T-table path panics: %v", n, ttablesFail)
+		}
+		if !ttablesFail && !bytes.Equal(reference, ttables) {
+			t.Errorf("%d-byte key: the reference AES gives %x, the T-tables %x", n, reference, ttables)
+		}
+	}
+	mustPanic(t, "AES_decrypt_block with a 20-byte key", func() { AES_decrypt_block(counting(20), block) })
+}
@@ -0,0 +1,126 @@
+package aesgcm
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// The functions of aesgcm.go above are the reference AES: a [][]byte state, allocated anew at every step of
+// every round. The keystream of the GCM functions is instead computed with the usual 32-bit T-tables,
+// which merge SubBytes, ShiftRows and MixColumns in four table lookups per column, on expanded keys
+// cached by key: a block costs no allocation. The tests check both against each other and FIPS-197.
+
+// te0[x] is the column MixColumns gives for SBOX[x] in the first row, te1, te2 and te3 are its rotations
+var te0, te1, te2, te3 [256]uint32
+
+func init() {
+	for x := 0; x < 256; x++ {
+		s := uint32(SBOX[x])
+		s2, s3 := uint32(gal_mul_const(SBOX[x], 2)), uint32(gal_mul_const(SBOX[x], 3))
+		w := s2<<24 | s<<16 | s<<8 | s3
+		te0[x] = w
+		te1[x] = w>>8 | w<<24
+		te2[x] = w>>16 | w<<16
+		te3[x] = w>>24 | w<<8
+	}
+}
+
+// roundKeys is an AES-128 key expanded into the 44 words of its round keys
+type roundKeys [4 * 11]uint32
+
+func expandRoundKeys(key []byte) *roundKeys {
+	const nk = 4
+	var rk roundKeys
+	for i := 0; i < nk; i++ {
+		rk[i] = binary.BigEndian.Uint32(key[4*i:])
+	}
+	for i := nk; i < len(rk); i++ {
+		t := rk[i-1]
+		if i%nk == 0 {
+			t = t<<8 | t>>24
+			t = uint32(SBOX[t>>24])<<24 | uint32(SBOX[t>>16&0xff])<<16 | uint32(SBOX[t>>8&0xff])<<8 | uint32(SBOX[t&0xff])
+			t ^= uint32(RCON[i/nk]) << 24
+		}
+		rk[i] = rk[i-nk] ^ t
+	}
+	return &rk
+}
+
+// encryptBlock encrypts the 16 bytes of src into dst, which may overlap
+func (rk *roundKeys) encryptBlock(dst, src []byte) {
+	_ = src[15]
+	s0 := binary.BigEndian.Uint32(src[0:]) ^ rk[0]
+	s1 := binary.BigEndian.Uint32(src[4:]) ^ rk[1]
+	s2 := binary.BigEndian.Uint32(src[8:]) ^ rk[2]
+	s3 := binary.BigEndian.Uint32(src[12:]) ^ rk[3]
+
+	k := 4
+	for round := 1; round < 10; round++ {
+		t0 := te0[s0>>24] ^ te1[s1>>16&0xff] ^ te2[s2>>8&0xff] ^ te3[s3&0xff] ^ rk[k]
+		t1 := te0[s1>>24] ^ te1[s2>>16&0xff] ^ te2[s3>>8&0xff] ^ te3[s0&0xff] ^ rk[k+1]
+		t2 := te0[s2>>24] ^ te1[s3>>16&0xff] ^ te2[s0>>8&0xff] ^ te3[s1&0xff] ^ rk[k+2]
+		t3 := te0[s3>>24] ^ te1[s0>>16&0xff] ^ te2[s1>>8&0xff] ^ te3[s2&0xff] ^ rk[k+3]
+		s0, s1, s2, s3 = t0, t1, t2, t3
+		k += 4
+	}
+
+	// the last round has no MixColumns
+	sub := func(a, b, c, d uint32) uint32 {
+		return uint32(SBOX[a>>24])<<24 | uint32(SBOX[b>>16&0xff])<<16 | uint32(SBOX[c>>8&0xff])<<8 | uint32(SBOX[d&0xff])
+	}
+	_ = dst[15]
+	binary.BigEndian.PutUint32(dst[0:], sub(s0, s1, s2, s3)^rk[k])
+	binary.BigEndian.PutUint32(dst[4:], sub(s1, s2, s3, s0)^rk[k+1])
+	binary.BigEndian.PutUint32(dst[8:], sub(s2, s3, s0, s1)^rk[k+2])
+	binary.BigEndian.PutUint32(dst[12:], sub(s3, s0, s1, s2)^rk[k+3])
+}
+
+// keyCacheSize bounds the number of expanded keys kept, the cache being emptied when it is full
+const keyCacheSize = 1024
+
+var keyCache = struct {
+	sync.Mutex
+	keys map[[16]byte]*roundKeys
+}{keys: make(map[[16]byte]*roundKeys)}
+
+// cachedRoundKeys returns the round keys of key, expanding it on first use. The T-table path is AES-128 only:
+// it panics on keys of any other length rather than run on a part of them.
+func cachedRoundKeys(key []byte) *roundKeys {
+	if len(key) != 16 {
+		panic("aesgcm: GCM and CCM take AES-128 keys of 16 bytes")
+	}
+	var k [16]byte
+	copy(k[:], key)
+
+	keyCache.Lock()
+	defer keyCache.Unlock()
+	rk, ok := keyCache.keys[k]
+	if !ok {
+		if len(keyCache.keys) >= keyCacheSize {
+			keyCache.keys = make(map[[16]byte]*roundKeys)
+		}
+		rk = expandRoundKeys(k[:])
+		keyCache.keys[k] = rk
+	}
+	return rk
+}
+
+// xorKeystream xors src with the keystream of the GCM blocks from starting_block into dst, which must be
+// as long as src. As get_block_iv_32, the counter is starting_block + 2 + i, in the last 32 bits of the counter
+// block, big-endian.
+func xorKeystream(rk *roundKeys, iv []byte, dst, src []byte, starting_block uint32) {
+	var counter, pad [16]byte
+	copy(counter[:], iv[:12])
+	for i := 0; len(src) > 0; i++ {
+		binary.BigEndian.PutUint32(counter[12:], uint32(i)+starting_block+2)
+		rk.encryptBlock(pad[:], counter[:])
+		n := len(src)
+		if n > 16 {
+			n = 16
+		}
+		for j := 0; j < n; j++ {
+			dst[j] = src[j] ^ pad[j]
+		}
+		dst, src = dst[n:], src[n:]
+	}
+}
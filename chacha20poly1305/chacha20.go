@@ -0,0 +1,130 @@
+// Package chacha20poly1305 implements the ChaCha20 and Poly1305 of RFC 8439 for TLS_CHACHA20_POLY1305_SHA256,
+// with the functions of aesgcm: the keystream from any block of a record and any offset within that block,
+// which the TLS Key Schedule Shortcut method decrypts the tail of the server extensions with, the tag of a
+// record and the nonce of its sequence number.
+package chacha20poly1305
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	KeySize   = 32
+	NonceSize = 12
+	TagSize   = 16
+	// BlockSize is the number of bytes of keystream of a block, which aesgcm gets from 4 AES blocks
+	BlockSize = 64
+)
+
+// initial_state returns the state of RFC 8439 section 2.3: the constants, the key, the block counter and the nonce
+func initial_state(key, nonce []byte, counter uint32) (s [16]uint32) {
+	s[0], s[1], s[2], s[3] = 0x61707865, 0x3320646e, 0x79622d32, 0x6b206574
+	for i := 0; i < 8; i++ {
+		s[4+i] = binary.LittleEndian.Uint32(key[4*i:])
+	}
+	s[12] = counter
+	for i := 0; i < 3; i++ {
+		s[13+i] = binary.LittleEndian.Uint32(nonce[4*i:])
+	}
+	return s
+}
+
+func quarter_round(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+	a += b
+	d = bits.RotateLeft32(d^a, 16)
+	c += d
+	b = bits.RotateLeft32(b^c, 12)
+	a += b
+	d = bits.RotateLeft32(d^a, 8)
+	c += d
+	b = bits.RotateLeft32(b^c, 7)
+	return a, b, c, d
+}
+
+// chacha20_block writes the 64 bytes of keystream of the state s into out: 20 rounds, alternately on the
+// columns and the diagonals of s as a 4x4 matrix, then s added word by word
+func chacha20_block(s *[16]uint32, out []byte) {
+	x := *s
+	for round := 0; round < 10; round++ {
+		x[0], x[4], x[8], x[12] = quarter_round(x[0], x[4], x[8], x[12])
+		x[1], x[5], x[9], x[13] = quarter_round(x[1], x[5], x[9], x[13])
+		x[2], x[6], x[10], x[14] = quarter_round(x[2], x[6], x[10], x[14])
+		x[3], x[7], x[11], x[15] = quarter_round(x[3], x[7], x[11], x[15])
+		x[0], x[5], x[10], x[15] = quarter_round(x[0], x[5], x[10], x[15])
+		x[1], x[6], x[11], x[12] = quarter_round(x[1], x[6], x[11], x[12])
+		x[2], x[7], x[8], x[13] = quarter_round(x[2], x[7], x[8], x[13])
+		x[3], x[4], x[9], x[14] = quarter_round(x[3], x[4], x[9], x[14])
+	}
+	_ = out[63]
+	for i := range x {
+		binary.LittleEndian.PutUint32(out[4*i:], x[i]+s[i])
+	}
+}
+
+// xor_keystream xors src with the keystream from byte offset of the block of the given counter into dst,
+// which must be as long as src. It panics if the 32-bit counter would wrap around.
+func xor_keystream(key, nonce []byte, dst, src []byte, counter uint32, offset int) {
+	if blocks := (offset + len(src) + BlockSize - 1) / BlockSize; uint64(counter)+uint64(blocks) > 1<<32 {
+		panic("chacha20poly1305: the block counter wraps around")
+	}
+	s := initial_state(key, nonce, counter)
+	var pad [BlockSize]byte
+	for len(src) > 0 {
+		chacha20_block(&s, pad[:])
+		n := len(src)
+		if n > BlockSize-offset {
+			n = BlockSize - offset
+		}
+		for j := 0; j < n; j++ {
+			dst[j] = src[j] ^ pad[offset+j]
+		}
+		dst, src = dst[n:], src[n:]
+		offset = 0
+		s[12]++
+	}
+}
+
+// ChaCha20_encrypt encrypts plaintext from block number starting_block of a record. As in aesgcm, block 0 is
+// the first block of the record: the block of counter 0 gives the Poly1305 key, so block i has the counter i + 1.
+func ChaCha20_encrypt(key, nonce, plaintext []byte, starting_block uint32) []byte {
+	return ChaCha20_decrypt_middle(key, nonce, plaintext, starting_block, 0)
+}
+
+func ChaCha20_decrypt(key, nonce, ciphertext []byte, starting_block uint32) []byte {
+	return ChaCha20_decrypt_middle(key, nonce, ciphertext, starting_block, 0)
+}
+
+// ChaCha20_decrypt_middle decrypts ciphertext with the keystream from byte offset, below 64, of block number
+// starting_block of the record
+func ChaCha20_decrypt_middle(key, nonce, ciphertext []byte, starting_block uint32, offset byte) []byte {
+	if offset >= BlockSize {
+		panic("chacha20poly1305: the offset is past the block")
+	}
+	if starting_block == 1<<32-1 {
+		panic("chacha20poly1305: the block counter wraps around")
+	}
+	output := make([]byte, len(ciphertext))
+	xor_keystream(key, nonce, output, ciphertext, starting_block+1, int(offset))
+	return output
+}
+
+// ChaCha20_decrypt_128bytes_middle is aesgcm.AES_GCM_decrypt_128bytes_middle for ChaCha20: it decrypts the
+// first 128 bytes of ciphertext from byte offset of block number starting_block, which spans 3 blocks
+func ChaCha20_decrypt_128bytes_middle(key, nonce, ciphertext []byte, starting_block uint32, offset byte) []byte {
+	if len(ciphertext) < 128 {
+		panic("Arrays to XOR aren't long enough")
+	}
+	return ChaCha20_decrypt_middle(key, nonce, ciphertext[:128], starting_block, offset)
+}
+
+// TLS_nonce returns the nonce of the record of sequence number seq: the 12-byte iv xored with seq,
+// big-endian and padded on the left with zeros (RFC 8446 section 5.3)
+func TLS_nonce(iv []byte, seq uint64) []byte {
+	nonce := make([]byte, NonceSize)
+	copy(nonce, iv[:NonceSize])
+	for i := 0; i < 8; i++ {
+		nonce[NonceSize-1-i] ^= byte(seq >> (8 * i))
+	}
+	return nonce
+}
@@ -0,0 +1,218 @@
+package chacha20poly1305
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/poly1305"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func counting(from, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(from + i)
+	}
+	return b
+}
+
+// sized returns b truncated or padded with zeros to n bytes
+func sized(b []byte, n int) []byte {
+	c := make([]byte, n)
+	copy(c, b)
+	return c
+}
+
+const sunscreen = "Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it."
+
+// The test vectors of RFC 8439, sections 2.3.2 to 2.8.2
+func TestRFC8439(t *testing.T) {
+	key := counting(0, 32)
+	s := initial_state(key, unhex("000000090000004a00000000"), 1)
+	block := make([]byte, 64)
+	chacha20_block(&s, block)
+	if want := "10f1e7e4d13b5915500fdd1fa32071c4c7d1f4c733c068030422aa9ac3d46c4ed2826446079faa0914c2d705d98b02a2b5129cd1de164eb9cbd083e8a2503c4e"; hex.EncodeToString(block) != want {
+		t.Errorf("block function: got %x, want %s", block, want)
+	}
+
+	// the counter 1 is the first block of a record
+	ct := ChaCha20_encrypt(key, unhex("000000000000004a00000000"), []byte(sunscreen), 0)
+	if want := "6e2e359a2568f98041ba0728dd0d6981e97e7aec1d4360c20a27afccfd9fae0bf91b65c5524733ab8f593dabcd62b3571639d624e65152ab8f530c359f0861d807ca0dbf500d6a6156a38e088a22b65e52bc514d16ccf806818ce91ab77937365af90bbf74a35be6b40b8eedf2785e42874d"; hex.EncodeToString(ct) != want {
+		t.Errorf("encryption: got %x, want %s", ct, want)
+	}
+
+	tag := Poly1305(unhex("85d6be7857556d337f4452fe42d506a80103808afb0db2fd4abff6af4149f51b"), []byte("Cryptographic Forum Research Group"))
+	if want := "a8061dc1305136c6c22b8baf0c0127a9"; hex.EncodeToString(tag) != want {
+		t.Errorf("Poly1305: got %x, want %s", tag, want)
+	}
+
+	otk := Poly1305_key_gen(counting(0x80, 32), unhex("000000000001020304050607"))
+	if want := "8ad5a08b905f81cc815040274ab29471a833b637e3fd0da508dbb8e2fdd1a646"; hex.EncodeToString(otk) != want {
+		t.Errorf("Poly1305 key: got %x, want %s", otk, want)
+	}
+
+	key, nonce, aad := counting(0x80, 32), unhex("070000004041424344454647"), unhex("50515253c0c1c2c3c4c5c6c7")
+	ct = ChaCha20_encrypt(key, nonce, []byte(sunscreen), 0)
+	if want := "d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d63dbea45e8ca9671282fafb69da92728b1a71de0a9e060b2905d6a5b67ecd3b3692ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc3ff4def08e4b7a9de576d26586cec64b6116"; hex.EncodeToString(ct) != want {
+		t.Errorf("AEAD ciphertext: got %x, want %s", ct, want)
+	}
+	tag = Compute_tag(key, nonce, aad, ct)
+	if want := "1ae10b594f09e26a7e902ecbd0600691"; hex.EncodeToString(tag) != want {
+		t.Errorf("AEAD tag: got %x, want %s", tag, want)
+	}
+	if !Verify_tag(key, nonce, aad, ct, tag) {
+		t.Error("the tag is rejected")
+	}
+	tag[0] ^= 1
+	if Verify_tag(key, nonce, aad, ct, tag) {
+		t.Error("a wrong tag is accepted")
+	}
+}
+
+// Poly1305 vectors of RFC 8439 appendix A.3 whose accumulator exceeds p or wraps around
+func TestPoly1305Reduction(t *testing.T) {
+	for i, v := range []struct{ key, msg, tag string }{
+		{"02" + zeros(31), "ffffffffffffffffffffffffffffffff", "03000000000000000000000000000000"},
+		{"02" + zeros(15) + "ffffffffffffffffffffffffffffffff", "02" + zeros(15), "03000000000000000000000000000000"},
+		{"01" + zeros(31), "ffffffffffffffffffffffffffffffff" + "f0ffffffffffffffffffffffffffffff" + "11" + zeros(15),
+			"05000000000000000000000000000000"},
+		{"01" + zeros(31), "ffffffffffffffffffffffffffffffff" + "fbfefefefefefefefefefefefefefefe" + "01010101010101010101010101010101",
+			zeros(16)},
+		{"02" + zeros(31), "fdffffffffffffffffffffffffffffff", "faffffffffffffffffffffffffffffff"},
+	} {
+		if got := Poly1305(unhex(v.key), unhex(v.msg)); hex.EncodeToString(got) != v.tag {
+			t.Errorf("vector %d: got %x, want %s", i, got, v.tag)
+		}
+	}
+}
+
+func zeros(n int) string {
+	return hex.EncodeToString(make([]byte, n))
+}
+
+func TestTLSNonce(t *testing.T) {
+	iv := unhex("5d313eb2671276ee13000b30")
+	if got := TLS_nonce(iv, 0); !bytes.Equal(got, iv) {
+		t.Errorf("record 0: got %x, want the iv", got)
+	}
+	if got, want := TLS_nonce(iv, 0x0102030405060708), "5d313eb2661075ea16060c38"; hex.EncodeToString(got) != want {
+		t.Errorf("got %x, want %s", got, want)
+	}
+}
+
+// keystream returns n bytes of the keystream from block number starting_block of the record, with x/crypto
+func keystream(t *testing.T, key, nonce []byte, starting_block uint32, n int) []byte {
+	c, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetCounter(starting_block + 1)
+	stream := make([]byte, n)
+	c.XORKeyStream(stream, stream)
+	return stream
+}
+
+func xor(a, b []byte) []byte {
+	c := make([]byte, len(a))
+	for i := range a {
+		c[i] = a[i] ^ b[i]
+	}
+	return c
+}
+
+func FuzzChaCha20Poly1305(f *testing.F) {
+	f.Add(counting(0x80, 32), unhex("070000004041424344454647"), unhex("50515253c0c1c2c3c4c5c6c7"), []byte(sunscreen))
+	f.Add(make([]byte, 32), make([]byte, 12), []byte{23, 3, 3, 0, 17}, []byte{})
+	f.Add(make([]byte, 32), make([]byte, 12), []byte{}, make([]byte, 64))
+	f.Fuzz(func(t *testing.T, key, nonce, aad, plaintext []byte) {
+		key, nonce = sized(key, 32), sized(nonce, 12)
+		aead, err := chacha20poly1305.New(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed := aead.Seal(nil, nonce, plaintext, aad)
+		ct, tag := sealed[:len(plaintext)], sealed[len(plaintext):]
+
+		if got := ChaCha20_encrypt(key, nonce, plaintext, 0); !bytes.Equal(got, ct) {
+			t.Fatalf("got %x, x/crypto seals %x", got, ct)
+		}
+		if got := ChaCha20_decrypt(key, nonce, ct, 0); !bytes.Equal(got, plaintext) {
+			t.Fatalf("decrypted %x, want %x", got, plaintext)
+		}
+		if got := Compute_tag(key, nonce, aad, ct); !bytes.Equal(got, tag) {
+			t.Fatalf("tag %x, x/crypto gives %x", got, tag)
+		}
+		if !Verify_tag(key, nonce, aad, ct, tag) {
+			t.Fatal("the tag of x/crypto is rejected")
+		}
+		if len(ct) > 0 {
+			ct[len(ct)/2] ^= 1
+			if Verify_tag(key, nonce, aad, ct, tag) {
+				t.Fatal("the tag of a corrupted ciphertext is accepted")
+			}
+		}
+	})
+}
+
+func FuzzPoly1305(f *testing.F) {
+	f.Add(unhex("85d6be7857556d337f4452fe42d506a80103808afb0db2fd4abff6af4149f51b"), []byte("Cryptographic Forum Research Group"))
+	f.Add(bytes.Repeat([]byte{0xff}, 32), bytes.Repeat([]byte{0xff}, 100))
+	f.Fuzz(func(t *testing.T, key, msg []byte) {
+		var k [32]byte
+		copy(k[:], key)
+		var want [16]byte
+		poly1305.Sum(&want, msg, &k)
+		if got := Poly1305(k[:], msg); !bytes.Equal(got, want[:]) {
+			t.Fatalf("got %x, x/crypto gives %x", got, want)
+		}
+	})
+}
+
+func FuzzChaCha20DecryptMiddle(f *testing.F) {
+	f.Add(make([]byte, 32), make([]byte, 12), make([]byte, 128), uint32(3), byte(5))
+	// the last blocks before the counter wraps around
+	f.Add(make([]byte, 32), make([]byte, 12), make([]byte, 128), uint32(1<<32-4), byte(63))
+	f.Fuzz(func(t *testing.T, key, nonce, plaintext []byte, starting_block uint32, offset byte) {
+		key, nonce, plaintext = sized(key, 32), sized(nonce, 12), sized(plaintext, 128)
+		offset %= BlockSize
+		if uint64(starting_block)+1+3 > 1<<32 {
+			return
+		}
+		stream := keystream(t, key, nonce, starting_block, 3*BlockSize)
+		ciphertext := xor(plaintext, stream[offset:])
+		if got := ChaCha20_decrypt_128bytes_middle(key, nonce, ciphertext, starting_block, offset); !bytes.Equal(got, plaintext) {
+			t.Fatalf("block %d offset %d: got %x, want %x", starting_block, offset, got, plaintext)
+		}
+		if got := ChaCha20_encrypt(key, nonce, plaintext[:64], starting_block); !bytes.Equal(got, xor(plaintext[:64], stream)) {
+			t.Fatalf("from block %d: got %x", starting_block, got)
+		}
+	})
+}
+
+func TestCounterWrapsAround(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic past the last block")
+		}
+	}()
+	// the last block has the counter 2^32 - 1, the second one would wrap around
+	ChaCha20_encrypt(make([]byte, 32), make([]byte, 12), make([]byte, BlockSize+1), 1<<32-2)
+}
+
+func BenchmarkChaCha20(b *testing.B) {
+	key, nonce, record := counting(0, 32), counting(0, 12), make([]byte, 1024)
+	b.SetBytes(int64(len(record)))
+	for i := 0; i < b.N; i++ {
+		ChaCha20_encrypt(key, nonce, record, 0)
+	}
+}
@@ -0,0 +1,7 @@
+module anonpao/chacha20poly1305
+
+go 1.19
+
+require golang.org/x/crypto v0.12.0
+
+require golang.org/x/sys v0.11.0 // indirect
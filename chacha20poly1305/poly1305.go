@@ -0,0 +1,139 @@
+package chacha20poly1305
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"math/bits"
+)
+
+// poly1305_state accumulates h = (h + block) * r modulo p = 2^130 - 5, h on three 64-bit limbs whose last holds
+// a few bits: the product is only partially reduced, h being below 2p until the final reduction
+type poly1305_state struct {
+	h0, h1, h2 uint64
+	r0, r1     uint64
+	s0, s1     uint64
+}
+
+func new_poly1305_state(key []byte) *poly1305_state {
+	return &poly1305_state{
+		// r is clamped: the top 4 bits of its bytes 3, 7, 11 and 15, and the bottom 2 of 4, 8 and 12 are cleared
+		r0: binary.LittleEndian.Uint64(key[0:]) & 0x0ffffffc0fffffff,
+		r1: binary.LittleEndian.Uint64(key[8:]) & 0x0ffffffc0ffffffc,
+		s0: binary.LittleEndian.Uint64(key[16:]),
+		s1: binary.LittleEndian.Uint64(key[24:]),
+	}
+}
+
+// block adds the 16 bytes of m to h, with hibit as bit 128, and multiplies h by r
+func (p *poly1305_state) block(m []byte, hibit uint64) {
+	var c uint64
+	p.h0, c = bits.Add64(p.h0, binary.LittleEndian.Uint64(m[0:]), 0)
+	p.h1, c = bits.Add64(p.h1, binary.LittleEndian.Uint64(m[8:]), c)
+	p.h2 += c + hibit
+
+	// h2 is below 8 and r0, r1 below 2^60: the products by h2 fit in 64 bits
+	h0r0hi, h0r0lo := bits.Mul64(p.h0, p.r0)
+	h1r0hi, h1r0lo := bits.Mul64(p.h1, p.r0)
+	h0r1hi, h0r1lo := bits.Mul64(p.h0, p.r1)
+	h1r1hi, h1r1lo := bits.Mul64(p.h1, p.r1)
+	h2r0, h2r1 := p.h2*p.r0, p.h2*p.r1
+
+	m1lo, c := bits.Add64(h1r0lo, h0r1lo, 0)
+	m1hi := h1r0hi + h0r1hi + c
+	m2lo, c := bits.Add64(h2r0, h1r1lo, 0)
+	m2hi := h1r1hi + c
+
+	t0 := h0r0lo
+	t1, c := bits.Add64(m1lo, h0r0hi, 0)
+	t2, c := bits.Add64(m2lo, m1hi, c)
+	t3 := h2r1 + m2hi + c
+
+	// t = t3:t2:t1:t0, and 2^130 = 5 modulo p: the bits from 130 up are added back 4 times, then once
+	p.h0, p.h1, p.h2 = t0, t1, t2&3
+	cc0, cc1 := t2&^3, t3
+	p.h0, c = bits.Add64(p.h0, cc0, 0)
+	p.h1, c = bits.Add64(p.h1, cc1, c)
+	p.h2 += c
+	cc0, cc1 = cc0>>2|cc1<<62, cc1>>2
+	p.h0, c = bits.Add64(p.h0, cc0, 0)
+	p.h1, c = bits.Add64(p.h1, cc1, c)
+	p.h2 += c
+}
+
+// write processes msg, the last partial block being padded with a one then zeros
+func (p *poly1305_state) write(msg []byte) {
+	for len(msg) >= 16 {
+		p.block(msg[:16], 1)
+		msg = msg[16:]
+	}
+	if len(msg) > 0 {
+		var last [16]byte
+		copy(last[:], msg)
+		last[len(msg)] = 1
+		p.block(last[:], 0)
+	}
+}
+
+// write_padded processes msg padded with zeros to a multiple of 16 bytes
+func (p *poly1305_state) write_padded(msg []byte) {
+	n := len(msg) - len(msg)%16
+	p.write(msg[:n])
+	if n < len(msg) {
+		var last [16]byte
+		copy(last[:], msg[n:])
+		p.block(last[:], 1)
+	}
+}
+
+// sum returns (h mod p) + s modulo 2^128
+func (p *poly1305_state) sum() []byte {
+	// h - p, kept if it does not borrow
+	g0, b := bits.Sub64(p.h0, 0xfffffffffffffffb, 0)
+	g1, b := bits.Sub64(p.h1, 0xffffffffffffffff, b)
+	_, b = bits.Sub64(p.h2, 3, b)
+	mask := b - 1
+	h0 := p.h0&^mask | g0&mask
+	h1 := p.h1&^mask | g1&mask
+
+	var c uint64
+	h0, c = bits.Add64(h0, p.s0, 0)
+	h1, _ = bits.Add64(h1, p.s1, c)
+	tag := make([]byte, TagSize)
+	binary.LittleEndian.PutUint64(tag[0:], h0)
+	binary.LittleEndian.PutUint64(tag[8:], h1)
+	return tag
+}
+
+// Poly1305 returns the tag of msg under the one-time 32-byte key r || s (RFC 8439 section 2.5)
+func Poly1305(key, msg []byte) []byte {
+	p := new_poly1305_state(key)
+	p.write(msg)
+	return p.sum()
+}
+
+// Poly1305_key_gen returns the one-time Poly1305 key of a record: the first 32 bytes of the keystream
+// of counter 0 (RFC 8439 section 2.6)
+func Poly1305_key_gen(key, nonce []byte) []byte {
+	s := initial_state(key, nonce, 0)
+	var block [BlockSize]byte
+	chacha20_block(&s, block[:])
+	return block[:32]
+}
+
+// Compute_tag returns the tag of a record (RFC 8439 section 2.8): the Poly1305 of aad and ciphertext,
+// each padded with zeros to a multiple of 16 bytes, then of their lengths as 64-bit little-endian
+func Compute_tag(key, nonce, aad, ciphertext []byte) []byte {
+	p := new_poly1305_state(Poly1305_key_gen(key, nonce))
+	p.write_padded(aad)
+	p.write_padded(ciphertext)
+	var lengths [16]byte
+	binary.LittleEndian.PutUint64(lengths[0:], uint64(len(aad)))
+	binary.LittleEndian.PutUint64(lengths[8:], uint64(len(ciphertext)))
+	p.write(lengths[:])
+	return p.sum()
+}
+
+// Verify_tag reports, in constant time, whether tag is the tag of the record of ciphertext and aad
+func Verify_tag(key, nonce, aad, ciphertext, tag []byte) bool {
+	return subtle.ConstantTimeCompare(Compute_tag(key, nonce, aad, ciphertext), tag) == 1
+}
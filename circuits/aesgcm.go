@@ -0,0 +1,37 @@
+package circuits
+
+import (
+	"anonpao/gadgets"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// AESGCMCircuit proves that a TLS 1.3 record of 32 bytes of application data is the AES-128-GCM encryption
+// of a plaintext under a secret key, the header of the record being the additional data
+type AESGCMCircuit struct {
+	Key        [16]frontend.Variable `gnark:"key"`
+	Plaintext  [32]frontend.Variable `gnark:"plaintext"`
+	Nonce      [12]frontend.Variable `gnark:",public"`
+	Header     [5]frontend.Variable  `gnark:",public"`
+	Ciphertext [32]frontend.Variable `gnark:",public"`
+	Tag        [16]frontend.Variable `gnark:",public"`
+}
+
+func (circuit *AESGCMCircuit) Define(api frontend.API) error {
+	ct, tag := gadgets.AESGCMSeal(api, circuit.Key[:], circuit.Nonce[:], circuit.Plaintext[:], circuit.Header[:])
+	for i := range circuit.Ciphertext {
+		api.AssertIsEqual(circuit.Ciphertext[i], ct[i])
+	}
+	for i := range circuit.Tag {
+		api.AssertIsEqual(circuit.Tag[i], tag[i])
+	}
+	return nil
+}
+
+func init() {
+	Register(Definition{
+		Name:        "aes128-gcm",
+		Description: "AES-128-GCM(key, Nonce, plaintext, Header) == Ciphertext || Tag, with a secret key and 32-byte plaintext",
+		New:         func() frontend.Circuit { return new(AESGCMCircuit) },
+	})
+}
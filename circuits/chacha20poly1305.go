@@ -0,0 +1,37 @@
+package circuits
+
+import (
+	"anonpao/gadgets"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// ChaCha20Poly1305Circuit is AESGCMCircuit for TLS_CHACHA20_POLY1305_SHA256: a TLS 1.3 record of 32 bytes of
+// application data encrypted with ChaCha20-Poly1305 under a secret key, the header being the additional data
+type ChaCha20Poly1305Circuit struct {
+	Key        [32]frontend.Variable `gnark:"key"`
+	Plaintext  [32]frontend.Variable `gnark:"plaintext"`
+	Nonce      [12]frontend.Variable `gnark:",public"`
+	Header     [5]frontend.Variable  `gnark:",public"`
+	Ciphertext [32]frontend.Variable `gnark:",public"`
+	Tag        [16]frontend.Variable `gnark:",public"`
+}
+
+func (circuit *ChaCha20Poly1305Circuit) Define(api frontend.API) error {
+	ct, tag := gadgets.ChaCha20Poly1305Seal(api, circuit.Key[:], circuit.Nonce[:], circuit.Plaintext[:], circuit.Header[:])
+	for i := range circuit.Ciphertext {
+		api.AssertIsEqual(circuit.Ciphertext[i], ct[i])
+	}
+	for i := range circuit.Tag {
+		api.AssertIsEqual(circuit.Tag[i], tag[i])
+	}
+	return nil
+}
+
+func init() {
+	Register(Definition{
+		Name:        "chacha20-poly1305",
+		Description: "ChaCha20-Poly1305(key, Nonce, plaintext, Header) == Ciphertext || Tag, with a secret key and 32-byte plaintext",
+		New:         func() frontend.Circuit { return new(ChaCha20Poly1305Circuit) },
+	})
+}
@@ -0,0 +1,109 @@
+package circuits
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"anonpao/gadgets"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// The HS-shortcut statement (see fwall) has hundreds of bytes of public inputs: the application ciphertext,
+// padded to HSCiphertextSize, the transcript hash H2 and the tail of the server extensions, padded to HSTailSize.
+// HSCommitmentCircuit takes them as secret inputs and only exposes a commitment to them, so that the verifier
+// processes one or two public inputs whatever the length of the DoH request; circuits proving the key schedule
+// on these bytes commit to them the same way. The verifier computes the commitment with HSCommitment.
+//
+// The committed bytes are the padded ciphertext, H2 and the padded tail followed by the lengths of the
+// ciphertext and the tail on 2 bytes each, big endian: without them, a ciphertext ending in a zero byte would
+// have the commitment of the one without it. The circuit checks that the padding is zeros.
+
+const (
+	HSCiphertextSize = 500 // HTTPFirewall.HTTP_REQUEST_MAX_LENGTH
+	HSTailSize       = 128
+)
+
+// HSCommitmentCircuit proves the knowledge of the ciphertext, H2 and server extensions tail committed to
+type HSCommitmentCircuit struct {
+	Ciphertext  [HSCiphertextSize]frontend.Variable `gnark:"ct"`
+	H2          [32]frontend.Variable               `gnark:"h2"`
+	ServExtTail [HSTailSize]frontend.Variable       `gnark:"servext_tail"`
+	// CiphertextLen and ServExtTailLen are the lengths of the ciphertext and the tail before padding
+	CiphertextLen  frontend.Variable   `gnark:"ct_len"`
+	ServExtTailLen frontend.Variable   `gnark:"servext_tail_len"`
+	Commitment     []frontend.Variable `gnark:",public"`
+
+	scheme gadgets.Commitment
+}
+
+func newHSCommitmentCircuit(scheme gadgets.Commitment) *HSCommitmentCircuit {
+	return &HSCommitmentCircuit{Commitment: make([]frontend.Variable, scheme.Size()), scheme: scheme}
+}
+
+func (circuit *HSCommitmentCircuit) Define(api frontend.API) error {
+	assertHSCommitment(api, circuit.scheme, circuit.Ciphertext[:], circuit.H2[:], circuit.ServExtTail[:],
+		circuit.CiphertextLen, circuit.ServExtTailLen, circuit.Commitment)
+	return nil
+}
+
+// assertHSCommitment constrains commitment to be the commitment of the padded ciphertext, H2 and tail and their lengths
+func assertHSCommitment(api frontend.API, scheme gadgets.Commitment, ciphertext, h2, tail []frontend.Variable,
+	ciphertextLen, tailLen frontend.Variable, commitment []frontend.Variable) {
+	assertPadded(api, ciphertext, ciphertextLen)
+	assertPadded(api, tail, tailLen)
+	b := append(append(append([]frontend.Variable{}, ciphertext...), h2...), tail...)
+	b = append(append(b, lengthBytes(api, ciphertextLen)...), lengthBytes(api, tailLen)...)
+	c := gadgets.Commit(api, scheme, b)
+	for i := range commitment {
+		api.AssertIsEqual(commitment[i], c[i])
+	}
+}
+
+// assertPadded constrains n to be at most len(b), and the bytes of b from n on to be zeros
+func assertPadded(api frontend.API, b []frontend.Variable, n frontend.Variable) {
+	api.AssertIsLessOrEqual(n, len(b))
+	// past is 1 from byte n on
+	var past frontend.Variable = 0
+	for i := range b {
+		past = api.Add(past, api.IsZero(api.Sub(n, i)))
+		api.AssertIsEqual(api.Mul(past, b[i]), 0)
+	}
+}
+
+// lengthBytes returns the 2 bytes of a length, big endian
+func lengthBytes(api frontend.API, n frontend.Variable) []frontend.Variable {
+	bits := api.ToBinary(n, 16)
+	return []frontend.Variable{api.FromBinary(bits[8:]...), api.FromBinary(bits[:8]...)}
+}
+
+// HSCommitment returns the commitment of the HS-shortcut public inputs, padding the ciphertext and the tail
+// with zeros and appending their lengths as the circuit does
+func HSCommitment(scheme gadgets.Commitment, ciphertext, h2, servExtTail []byte) ([]*big.Int, error) {
+	if len(ciphertext) > HSCiphertextSize || len(h2) != 32 || len(servExtTail) > HSTailSize {
+		return nil, fmt.Errorf("got %d bytes of ciphertext, %d of H2 and %d of tail, expected at most %d, 32 and at most %d",
+			len(ciphertext), len(h2), len(servExtTail), HSCiphertextSize, HSTailSize)
+	}
+	b := make([]byte, HSCiphertextSize+32+HSTailSize+4)
+	copy(b, ciphertext)
+	copy(b[HSCiphertextSize:], h2)
+	copy(b[HSCiphertextSize+32:], servExtTail)
+	lengths := b[HSCiphertextSize+32+HSTailSize:]
+	binary.BigEndian.PutUint16(lengths, uint16(len(ciphertext)))
+	binary.BigEndian.PutUint16(lengths[2:], uint16(len(servExtTail)))
+	return gadgets.CommitBytes(scheme, b), nil
+}
+
+func init() {
+	Register(Definition{
+		Name:        "hs-commitment-sha256",
+		Description: "SHA-256 commitment to the HS-shortcut public inputs (ct, h2, servext_tail and their lengths) == Commitment",
+		New:         func() frontend.Circuit { return newHSCommitmentCircuit(gadgets.SHA256Commitment) },
+	})
+	Register(Definition{
+		Name:        "hs-commitment-poseidon",
+		Description: "Poseidon commitment to the HS-shortcut public inputs (ct, h2, servext_tail and their lengths) == Commitment, bn254 only",
+		New:         func() frontend.Circuit { return newHSCommitmentCircuit(gadgets.PoseidonCommitment) },
+	})
+}
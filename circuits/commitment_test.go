@@ -0,0 +1,78 @@
+package circuits
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"anonpao/gadgets"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// hsAssignment returns the assignment of the commitment circuit to the given bytes, padded with zeros,
+// and their actual lengths
+func hsAssignment(scheme gadgets.Commitment, ciphertext, h2, tail []byte, ctLen, tailLen int, commitment []*big.Int) *HSCommitmentCircuit {
+	c := newHSCommitmentCircuit(scheme)
+	for i := range c.Ciphertext {
+		c.Ciphertext[i] = 0
+		if i < len(ciphertext) {
+			c.Ciphertext[i] = ciphertext[i]
+		}
+	}
+	for i := range c.H2 {
+		c.H2[i] = h2[i]
+	}
+	for i := range c.ServExtTail {
+		c.ServExtTail[i] = 0
+		if i < len(tail) {
+			c.ServExtTail[i] = tail[i]
+		}
+	}
+	c.CiphertextLen, c.ServExtTailLen = ctLen, tailLen
+	for i := range c.Commitment {
+		c.Commitment[i] = commitment[i]
+	}
+	return c
+}
+
+func TestHSCommitment(t *testing.T) {
+	ciphertext := append(bytes.Repeat([]byte{0xab}, 498), 'x')
+	h2, tail := bytes.Repeat([]byte{2}, 32), bytes.Repeat([]byte{3}, 100)
+	commitment, err := HSCommitment(gadgets.PoseidonCommitment, ciphertext, h2, tail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the 499-byte ciphertext and the one with a zero appended differ only by their length
+	padded, err := HSCommitment(gadgets.PoseidonCommitment, append(ciphertext, 0), h2, tail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if padded[0].Cmp(commitment[0]) == 0 {
+		t.Fatal("a trailing zero byte does not change the commitment")
+	}
+
+	circuit := newHSCommitmentCircuit(gadgets.PoseidonCommitment)
+	valid := hsAssignment(gadgets.PoseidonCommitment, ciphertext, h2, tail, len(ciphertext), len(tail), commitment)
+	if err := test.IsSolved(circuit, valid, ecc.BN254.ScalarField()); err != nil {
+		t.Fatalf("the commitment of HSCommitment is not proved: %v", err)
+	}
+	valid = hsAssignment(gadgets.PoseidonCommitment, ciphertext, h2, tail, len(ciphertext)+1, len(tail), padded)
+	if err := test.IsSolved(circuit, valid, ecc.BN254.ScalarField()); err != nil {
+		t.Fatalf("the commitment of the ciphertext with a zero appended is not proved: %v", err)
+	}
+	for name, invalid := range map[string]frontend.Circuit{
+		"the length of the ciphertext with a zero appended": hsAssignment(gadgets.PoseidonCommitment, ciphertext, h2, tail,
+			len(ciphertext)+1, len(tail), commitment),
+		"a byte of data in the padding": hsAssignment(gadgets.PoseidonCommitment, ciphertext, h2, tail, len(ciphertext)-1, len(tail), commitment),
+		"a ciphertext longer than the maximum": hsAssignment(gadgets.PoseidonCommitment, ciphertext, h2, tail,
+			HSCiphertextSize+1, len(tail), commitment),
+	} {
+		if test.IsSolved(circuit, invalid, ecc.BN254.ScalarField()) == nil {
+			t.Errorf("%s is accepted", name)
+		}
+	}
+}
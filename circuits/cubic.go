@@ -0,0 +1,30 @@
+package circuits
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+// CubicCircuit defines a simple circuit
+// x**3 + x + 5 == y
+type CubicCircuit struct {
+	// struct tags on a variable is optional
+	// default uses variable name and secret visibility.
+	X frontend.Variable `gnark:"x"`
+	Y frontend.Variable `gnark:",public"`
+}
+
+// Define declares the circuit constraints
+// x**3 + x + 5 == y
+func (circuit *CubicCircuit) Define(api frontend.API) error {
+	x3 := api.Mul(circuit.X, circuit.X, circuit.X)
+	api.AssertIsEqual(circuit.Y, api.Add(x3, circuit.X, 5))
+	return nil
+}
+
+func init() {
+	Register(Definition{
+		Name:        "cubic",
+		Description: "x**3 + x + 5 == y, with x secret and y public",
+		New:         func() frontend.Circuit { return new(CubicCircuit) },
+	})
+}
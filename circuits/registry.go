@@ -0,0 +1,63 @@
+package circuits
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// This package holds the single definition of every circuit the zk command knows about.
+// Setup, prove and verify all look circuits up by name here, so the three stages can never
+// disagree on the constraints they compile.
+
+// Definition describes a registered circuit
+type Definition struct {
+	// Name is the identifier used on the command line and as the default artifact prefix
+	Name string
+
+	// Description is a one line summary printed by the zk command
+	Description string
+
+	// New returns a fresh, unassigned instance of the circuit.
+	// It is used both to compile the constraint system and as the target of a witness assignment,
+	// so circuits with slices must return them already sized.
+	New func() frontend.Circuit
+}
+
+var registry = make(map[string]Definition)
+
+// Register makes a circuit available by name.
+// It is meant to be called from the init function of the file that defines the circuit,
+// and panics if the name is empty or already taken.
+func Register(def Definition) {
+	if def.Name == "" {
+		panic("circuits: Register called with an empty name")
+	}
+	if def.New == nil {
+		panic("circuits: Register called with a nil constructor for " + def.Name)
+	}
+	if _, dup := registry[def.Name]; dup {
+		panic("circuits: Register called twice for " + def.Name)
+	}
+	registry[def.Name] = def
+}
+
+// Lookup returns the circuit registered under the given name
+func Lookup(name string) (Definition, error) {
+	def, ok := registry[name]
+	if !ok {
+		return Definition{}, fmt.Errorf("unknown circuit %q (registered: %v)", name, Names())
+	}
+	return def, nil
+}
+
+// Names returns the names of all registered circuits in sorted order
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,35 @@
+package circuits
+
+import (
+	"strings"
+	"testing"
+)
+
+// Lookup returns every circuit of the zk command under its name, and rejects other names listing them
+func TestLookup(t *testing.T) {
+	names := []string{
+		"cubic", "sha256", "hmac-sha256", "aes128-gcm", "chacha20-poly1305",
+		"hs-commitment-sha256", "hs-commitment-poseidon", "tls-key-schedule", "doh-firewall",
+	}
+	for _, name := range names {
+		def, err := Lookup(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if def.Name != name || def.Description == "" || def.New() == nil {
+			t.Errorf("%s: registered as %+v", name, def)
+		}
+	}
+	if len(Names()) != len(names) {
+		t.Errorf("registered %v, want %v", Names(), names)
+	}
+
+	for _, name := range []string{"", "doh", "SHA256", "tls-key-schedule "} {
+		if _, err := Lookup(name); err == nil {
+			t.Errorf("%q is found", name)
+		} else if !strings.Contains(err.Error(), "doh-firewall") {
+			t.Errorf("%q: the error %q does not list the registered circuits", name, err)
+		}
+	}
+}
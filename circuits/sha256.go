@@ -0,0 +1,49 @@
+package circuits
+
+import (
+	"anonpao/gadgets"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// SHA256Circuit proves the knowledge of a 64-byte preimage of a SHA-256 digest
+type SHA256Circuit struct {
+	Message [64]frontend.Variable `gnark:"msg"`
+	Digest  [32]frontend.Variable `gnark:",public"`
+}
+
+func (circuit *SHA256Circuit) Define(api frontend.API) error {
+	digest := gadgets.SHA256(api, circuit.Message[:])
+	for i := range circuit.Digest {
+		api.AssertIsEqual(circuit.Digest[i], digest[i])
+	}
+	return nil
+}
+
+// HMACCircuit proves the knowledge of the 32-byte key of an HMAC-SHA256 tag, as used by the TLS 1.3 key schedule
+type HMACCircuit struct {
+	Key     [32]frontend.Variable `gnark:"key"`
+	Message [32]frontend.Variable `gnark:",public"`
+	MAC     [32]frontend.Variable `gnark:",public"`
+}
+
+func (circuit *HMACCircuit) Define(api frontend.API) error {
+	mac := gadgets.HMACSHA256(api, circuit.Key[:], circuit.Message[:])
+	for i := range circuit.MAC {
+		api.AssertIsEqual(circuit.MAC[i], mac[i])
+	}
+	return nil
+}
+
+func init() {
+	Register(Definition{
+		Name:        "sha256",
+		Description: "SHA-256(msg) == Digest, with a secret 64-byte msg",
+		New:         func() frontend.Circuit { return new(SHA256Circuit) },
+	})
+	Register(Definition{
+		Name:        "hmac-sha256",
+		Description: "HMAC-SHA256(key, Message) == MAC, with a secret 32-byte key",
+		New:         func() frontend.Circuit { return new(HMACCircuit) },
+	})
+}
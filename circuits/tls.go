@@ -0,0 +1,525 @@
+package circuits
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"anonpao/aesgcm"
+	"anonpao/gadgets"
+	"anonpao/hkdf"
+	"anonpao/sha2"
+	"anonpao/tls"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// The TLS key schedule circuits prove the HS shortcut of tls.Get1RTT_HS_new on a TLS 1.3 connection using
+// TLS_AES_128_GCM_SHA256, as a middlebox observes it (see Statement). The handshake secret HS gives the server
+// handshake key, which decrypts the tail of the transcript, up to the server Finished; the Finished must be the
+// HMAC of the transcript hash H7, computed from a secret checkpoint of SHA-256 (see tls.TR7_checkpoint) and
+// the tail. HS then gives the client application key, which decrypts the request.
+// The ciphertexts and H2 are committed to as in HSCommitmentCircuit, so that the verifier only processes the
+// commitment and the sequence number of the request record, whatever the length of the request.
+//
+// gnark fixes the lengths of the inputs, and the key schedule hashes and decrypts the transcript at offsets that
+// depend on the lengths of the handshake messages: a circuit is compiled for one TLSShape, that of the connections
+// of a client with a given resolver, and the connections of any other shape are rejected when assigning it.
+
+// Statement is the public part of a TLS 1.3 connection that a request is proved about:
+// the traffic a middlebox observes and the policy it enforces
+type Statement struct {
+	// ClientHello and ServerHello are the handshake messages, without their record headers
+	ClientHello, ServerHello []byte
+	// ServerHandshake holds the encrypted records of the server handshake, from EncryptedExtensions
+	// to the server Finished, with their record headers
+	ServerHandshake [][]byte
+	// Ciphertext is the client application data record the proof is about, with its record header,
+	// and Sequence the number of application data records the client sent before it
+	Ciphertext []byte
+	Sequence   uint64
+	// PolicyRoot commits to the policy the traffic must comply with
+	PolicyRoot []byte
+}
+
+// TLSShape is the layout of the connections a key schedule circuit is compiled for
+type TLSShape struct {
+	// ClientHello and ServerHello are the lengths of the handshake messages, with their 4-byte headers
+	ClientHello, ServerHello int
+	// ServerRecords are the lengths of the encrypted records of the server handshake without their 5-byte
+	// headers: their handshake messages, the content type and the 16-byte tag
+	ServerRecords []int
+}
+
+// CloudflareShape is the shape of the DoH session of fwall/test_doh.txt with cloudflare-dns.com, whose server
+// sends its 2656 bytes of handshake messages in one record
+var CloudflareShape = TLSShape{ClientHello: 292, ServerHello: 155, ServerRecords: []int{2656 + 1 + tagSize}}
+
+const (
+	tagSize          = 16
+	recordHeaderSize = 5
+	// finishedSize is the length of the Finished message: its header and the HMAC
+	finishedSize = 4 + 32
+	// maxRequestRecord is the length of the largest request record, whose encrypted request and content type
+	// are committed to as the ciphertext of HSCommitmentCircuit
+	maxRequestRecord = recordHeaderSize + HSCiphertextSize + tagSize
+)
+
+// ShapeOf returns the shape of the connection of st
+func ShapeOf(st Statement) TLSShape {
+	s := TLSShape{ClientHello: len(st.ClientHello), ServerHello: len(st.ServerHello)}
+	for _, record := range st.ServerHandshake {
+		s.ServerRecords = append(s.ServerRecords, len(record)-recordHeaderSize)
+	}
+	return s
+}
+
+func (s TLSShape) String() string {
+	return fmt.Sprintf("ClientHello of %d bytes, ServerHello of %d and server handshake records of %v",
+		s.ClientHello, s.ServerHello, s.ServerRecords)
+}
+
+func (s TLSShape) equal(other TLSShape) bool {
+	if s.ClientHello != other.ClientHello || s.ServerHello != other.ServerHello || len(s.ServerRecords) != len(other.ServerRecords) {
+		return false
+	}
+	for i := range s.ServerRecords {
+		if s.ServerRecords[i] != other.ServerRecords[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// tlsLayout places the tail of the transcript in the records of the server handshake
+type tlsLayout struct {
+	// tr7 and tr3 are the lengths of the transcript without and with the server Finished, and checkpoint
+	// the length of the whole blocks of tr7, after which SHA-256 resumes
+	tr7, tr3, checkpoint uint64
+	// segments hold the bytes of the transcript from checkpoint to tr3, record by record
+	segments []tlsSegment
+}
+
+// tlsSegment is length bytes of the plaintext of the server handshake record of sequence number record, from offset
+type tlsSegment struct {
+	record, offset, length int
+}
+
+// tailLength is the number of bytes from the checkpoint to the end of the transcript
+func (l tlsLayout) tailLength() int {
+	return int(l.tr3 - l.checkpoint)
+}
+
+func (s TLSShape) layout() (tlsLayout, error) {
+	if s.ClientHello < 4 || s.ServerHello < 4 || len(s.ServerRecords) == 0 {
+		return tlsLayout{}, fmt.Errorf("%s: the handshake is missing messages", s)
+	}
+	var l tlsLayout
+	l.tr3 = uint64(s.ClientHello + s.ServerHello)
+	for _, n := range s.ServerRecords {
+		if n <= 1+tagSize || n > 1<<14+256 {
+			return tlsLayout{}, fmt.Errorf("%s: a TLS 1.3 record holds 1 to 2^14 + 256 bytes and more than its content type and tag", s)
+		}
+		l.tr3 += uint64(n - 1 - tagSize)
+	}
+	if s.ServerRecords[len(s.ServerRecords)-1]-1-tagSize < finishedSize {
+		return tlsLayout{}, fmt.Errorf("%s: the last record is too short to hold the server Finished", s)
+	}
+	l.tr7 = l.tr3 - finishedSize
+	l.checkpoint = l.tr7 - l.tr7%sha2.BlockSize
+	if l.checkpoint < uint64(s.ClientHello+s.ServerHello) {
+		return tlsLayout{}, fmt.Errorf("%s: the server handshake is too short to resume SHA-256 after it", s)
+	}
+
+	start := uint64(s.ClientHello + s.ServerHello)
+	for r, n := range s.ServerRecords {
+		end := start + uint64(n-1-tagSize)
+		if end > l.checkpoint {
+			from := start
+			if from < l.checkpoint {
+				from = l.checkpoint
+			}
+			l.segments = append(l.segments, tlsSegment{r, int(from - start), int(end - from)})
+		}
+		start = end
+	}
+	return l, nil
+}
+
+// TLSKeyScheduleCircuit proves that the client of a connection of its shape knows its handshake secret,
+// and so the key that decrypts its request record
+type TLSKeyScheduleCircuit struct {
+	// Ciphertext is the encrypted request and content type of the request record, H2 the hash of the ClientHello
+	// and ServerHello, and ServExtTail the ciphertext of the tail of the transcript, without the content types and
+	// tags of the records; their commitment is public, see HSCommitmentCircuit
+	Ciphertext     [HSCiphertextSize]frontend.Variable `gnark:"ct"`
+	H2             [32]frontend.Variable               `gnark:"h2"`
+	ServExtTail    [HSTailSize]frontend.Variable       `gnark:"servext_tail"`
+	CiphertextLen  frontend.Variable                   `gnark:"ct_len"`
+	ServExtTailLen frontend.Variable                   `gnark:"servext_tail_len"`
+	Commitment     []frontend.Variable                 `gnark:",public"`
+	// Sequence is the sequence number of the request record
+	Sequence frontend.Variable `gnark:",public"`
+
+	// HS is the handshake secret, and Checkpoint the SHA-256 state after the whole blocks of TR7, big endian
+	HS         [32]frontend.Variable `gnark:"hs"`
+	Checkpoint [32]frontend.Variable `gnark:"checkpoint"`
+
+	shape TLSShape
+}
+
+// NewTLSKeyScheduleCircuit returns the key schedule circuit of the connections of the given shape
+func NewTLSKeyScheduleCircuit(shape TLSShape) *TLSKeyScheduleCircuit {
+	return &TLSKeyScheduleCircuit{Commitment: make([]frontend.Variable, gadgets.SHA256Commitment.Size()), shape: shape}
+}
+
+func (circuit *TLSKeyScheduleCircuit) Define(api frontend.API) error {
+	_, err := circuit.request(api)
+	return err
+}
+
+// request constrains the key schedule and returns the decryption of the ciphertext, the request and its content
+// type followed by the decryption of the padding
+func (circuit *TLSKeyScheduleCircuit) request(api frontend.API) ([]frontend.Variable, error) {
+	l, err := circuit.shape.layout()
+	if err != nil {
+		return nil, err
+	}
+	assertHSCommitment(api, gadgets.SHA256Commitment, circuit.Ciphertext[:], circuit.H2[:], circuit.ServExtTail[:],
+		circuit.CiphertextLen, circuit.ServExtTailLen, circuit.Commitment)
+	api.AssertIsEqual(circuit.ServExtTailLen, l.tailLength())
+
+	// the server handshake key decrypts the tail of the transcript, record by record
+	shts := gadgets.HKDFExpandLabel(api, circuit.HS[:], "s hs traffic", circuit.H2[:], 32)
+	serverAES := gadgets.NewAES128(api, gadgets.HKDFExpandLabel(api, shts, "key", nil, 16))
+	serverIV := gadgets.HKDFExpandLabel(api, shts, "iv", nil, 12)
+	var tail []frontend.Variable
+	ct := circuit.ServExtTail[:]
+	for _, s := range l.segments {
+		nonce := gadgets.TLSNonce(api, serverIV, s.record)
+		tail = append(tail, serverAES.XORKeyStream(nonce, uint32(s.offset/16), s.offset%16, ct[:s.length])...)
+		ct = ct[s.length:]
+	}
+
+	// the transcript ends with the server Finished, the HMAC of H7 under the finished key
+	digests := gadgets.SHA256Prefixes(api, circuit.Checkpoint[:], l.checkpoint, tail, []uint64{l.tr7, l.tr3})
+	finished := tail[len(tail)-finishedSize:]
+	for i, b := range []byte{20, 0, 0, 32} {
+		api.AssertIsEqual(finished[i], b)
+	}
+	mac := gadgets.HMACSHA256(api, gadgets.HKDFExpandLabel(api, shts, "finished", nil, 32), digests[0])
+	for i := range mac {
+		api.AssertIsEqual(finished[4+i], mac[i])
+	}
+
+	// the client application key, from the master secret and H3, decrypts the request
+	empty := sha256.Sum256(nil)
+	derived := gadgets.HKDFExpandLabel(api, circuit.HS[:], "derived", constants(empty[:]), 32)
+	ms := gadgets.HMACSHA256(api, derived, constants(make([]byte, 32)))
+	cats := gadgets.HKDFExpandLabel(api, ms, "c ap traffic", digests[1], 32)
+	clientAES := gadgets.NewAES128(api, gadgets.HKDFExpandLabel(api, cats, "key", nil, 16))
+	nonce := gadgets.TLSNonce(api, gadgets.HKDFExpandLabel(api, cats, "iv", nil, 12), circuit.Sequence)
+	return clientAES.XORKeyStream(nonce, 0, 0, circuit.Ciphertext[:]), nil
+}
+
+// constants returns the bytes b as constant variables
+func constants(b []byte) []frontend.Variable {
+	vs := make([]frontend.Variable, len(b))
+	for i := range b {
+		vs[i] = b[i]
+	}
+	return vs
+}
+
+// Assign sets the inputs of the circuit for the request of st, given the handshake secret of its connection
+func (circuit *TLSKeyScheduleCircuit) Assign(st Statement, hs []byte) error {
+	l, err := circuit.assignInputs(st)
+	if err != nil {
+		return err
+	}
+	if len(hs) != 32 {
+		return fmt.Errorf("handshake secret of %d bytes, expected 32", len(hs))
+	}
+
+	// the checkpoint hashes the whole blocks of the transcript, whose server handshake only HS decrypts
+	h2 := sha256.Sum256(append(append([]byte{}, st.ClientHello...), st.ServerHello...))
+	shts := hkdf.HKDF_expand_derive_secret(hs, "s hs traffic", h2[:])
+	gcm, err := newGCM(hkdf.HKDF_expand_derive_tk(shts, 16))
+	if err != nil {
+		return err
+	}
+	iv := hkdf.HKDF_expand_derive_iv(shts, 12)
+	d := sha2.New()
+	d.Write(st.ClientHello)
+	d.Write(st.ServerHello)
+	written := uint64(len(st.ClientHello) + len(st.ServerHello))
+	for r, record := range st.ServerHandshake {
+		plaintext, err := gcm.Open(nil, aesgcm.TLS_nonce(iv, uint64(r)), record[recordHeaderSize:], record[:recordHeaderSize])
+		if err != nil || plaintext[len(plaintext)-1] != 22 {
+			return fmt.Errorf("server handshake record %d: the handshake secret does not decrypt it to handshake messages", r)
+		}
+		plaintext = plaintext[:len(plaintext)-1]
+		if n := l.checkpoint - written; n < uint64(len(plaintext)) {
+			plaintext = plaintext[:n]
+		}
+		d.Write(plaintext)
+		written += uint64(len(plaintext))
+	}
+	checkpoint, err := d.Checkpoint()
+	if err != nil {
+		return err
+	}
+	for i, h := range checkpoint.H {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], h)
+		for k := range b {
+			circuit.Checkpoint[4*i+k] = b[k]
+		}
+	}
+	for i := range circuit.HS {
+		circuit.HS[i] = hs[i]
+	}
+	return nil
+}
+
+// AssignPublic sets the public inputs of the circuit for the request of st, as a verifier that only observed
+// the traffic does; the secret ones are left unset
+func (circuit *TLSKeyScheduleCircuit) AssignPublic(st Statement) error {
+	_, err := circuit.assignInputs(st)
+	return err
+}
+
+// assignInputs checks that st is a connection of the shape of the circuit, and sets the ciphertexts and H2,
+// their commitment and the sequence number of the request
+func (circuit *TLSKeyScheduleCircuit) assignInputs(st Statement) (tlsLayout, error) {
+	l, err := circuit.shape.layout()
+	if err != nil {
+		return l, err
+	}
+	if shape := ShapeOf(st); !shape.equal(circuit.shape) {
+		return l, fmt.Errorf("connection with a %s, the circuit is compiled for a %s", shape, circuit.shape)
+	}
+	chsh := append(append([]byte{}, st.ClientHello...), st.ServerHello...)
+	if suite, err := tls.Negotiated_suite(chsh); err != nil {
+		return l, err
+	} else if suite != tls.TLS_AES_128_GCM_SHA256 {
+		return l, fmt.Errorf("cipher suite %#04x, the circuit only decrypts TLS_AES_128_GCM_SHA256", uint16(suite))
+	}
+	for r, record := range st.ServerHandshake {
+		if err := checkRecord(record); err != nil {
+			return l, fmt.Errorf("server handshake record %d: %w", r, err)
+		}
+	}
+	if err := checkRecord(st.Ciphertext); err != nil {
+		return l, fmt.Errorf("request record: %w", err)
+	}
+	if len(st.Ciphertext) > maxRequestRecord {
+		return l, fmt.Errorf("request record of %d bytes, the circuit decrypts at most %d", len(st.Ciphertext), maxRequestRecord)
+	}
+
+	ct := st.Ciphertext[recordHeaderSize : len(st.Ciphertext)-tagSize]
+	h2 := sha256.Sum256(chsh)
+	var tail []byte
+	for _, s := range l.segments {
+		from := recordHeaderSize + s.offset
+		tail = append(tail, st.ServerHandshake[s.record][from:from+s.length]...)
+	}
+	commitment, err := HSCommitment(gadgets.SHA256Commitment, ct, h2[:], tail)
+	if err != nil {
+		return l, err
+	}
+
+	for i := range circuit.Ciphertext {
+		circuit.Ciphertext[i] = 0
+		if i < len(ct) {
+			circuit.Ciphertext[i] = ct[i]
+		}
+	}
+	for i := range circuit.H2 {
+		circuit.H2[i] = h2[i]
+	}
+	for i := range circuit.ServExtTail {
+		circuit.ServExtTail[i] = 0
+		if i < len(tail) {
+			circuit.ServExtTail[i] = tail[i]
+		}
+	}
+	circuit.CiphertextLen, circuit.ServExtTailLen = len(ct), len(tail)
+	for i := range circuit.Commitment {
+		circuit.Commitment[i] = commitment[i]
+	}
+	circuit.Sequence = st.Sequence
+	return l, nil
+}
+
+// checkRecord checks the header of an encrypted TLS 1.3 record
+func checkRecord(record []byte) error {
+	if len(record) <= recordHeaderSize+tagSize || record[0] != 23 ||
+		int(binary.BigEndian.Uint16(record[3:recordHeaderSize])) != len(record)-recordHeaderSize {
+		return errors.New("not an encrypted TLS record")
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// DoHPrefixSize bounds the length of the prefix of the requests a DoH firewall policy allows
+const DoHPrefixSize = 64
+
+// DoHFirewallCircuit proves the key schedule of a request, and that the request complies with the policy:
+// it is a single DoH GET request, whose headers end at the end of the record, and starts with the prefix
+// committed to by Policy, e.g. "GET /dns-query?dns=" so that the resolver only serves DNS queries
+type DoHFirewallCircuit struct {
+	KeySchedule TLSKeyScheduleCircuit `gnark:"key_schedule"`
+	// Prefix is the beginning of the requests the policy allows, PrefixLen bytes padded with zeros
+	Prefix    [DoHPrefixSize]frontend.Variable `gnark:"prefix"`
+	PrefixLen frontend.Variable                `gnark:"prefix_len"`
+	// Policy is the policy root of the statement, see DoHPolicyRoot
+	Policy []frontend.Variable `gnark:",public"`
+}
+
+// NewDoHFirewallCircuit returns the DoH firewall circuit of the connections of the given shape
+func NewDoHFirewallCircuit(shape TLSShape) *DoHFirewallCircuit {
+	return &DoHFirewallCircuit{
+		KeySchedule: *NewTLSKeyScheduleCircuit(shape),
+		Policy:      make([]frontend.Variable, gadgets.SHA256Commitment.Size()),
+	}
+}
+
+func (circuit *DoHFirewallCircuit) Define(api frontend.API) error {
+	request, err := circuit.KeySchedule.request(api)
+	if err != nil {
+		return err
+	}
+
+	assertPadded(api, circuit.Prefix[:], circuit.PrefixLen)
+	policy := gadgets.Commit(api, gadgets.SHA256Commitment, append(circuit.Prefix[:], lengthBytes(api, circuit.PrefixLen)...))
+	for i := range circuit.Policy {
+		api.AssertIsEqual(circuit.Policy[i], policy[i])
+	}
+
+	// the request of n bytes is followed by the content type of application data, without padding
+	n := api.Sub(circuit.KeySchedule.CiphertextLen, 1)
+	at := make([]frontend.Variable, len(request))
+	for i := range request {
+		at[i] = api.IsZero(api.Sub(n, i))
+	}
+	var contentType frontend.Variable = 0
+	for i := range request {
+		contentType = api.Add(contentType, api.Mul(at[i], request[i]))
+	}
+	api.AssertIsEqual(contentType, 23)
+	api.AssertIsLessOrEqual(circuit.PrefixLen, n)
+
+	// it starts with the prefix
+	var past frontend.Variable = 0
+	for i := range circuit.Prefix {
+		past = api.Add(past, api.IsZero(api.Sub(circuit.PrefixLen, i)))
+		api.AssertIsEqual(api.Mul(api.Sub(1, past), api.Sub(request[i], circuit.Prefix[i])), 0)
+	}
+
+	// and its only blank line is its last 4 bytes, the end of its headers
+	var blankLines, last frontend.Variable = 0, 0
+	past = api.Add(at[0], at[1], at[2])
+	for i := 3; i < len(request); i++ {
+		past = api.Add(past, at[i])
+		blank := isBlankLine(api, request[i-3:i+1])
+		blankLines = api.Add(blankLines, api.Mul(api.Sub(1, past), blank))
+		if i+1 < len(request) {
+			last = api.Add(last, api.Mul(at[i+1], blank))
+		}
+	}
+	api.AssertIsEqual(blankLines, 1)
+	api.AssertIsEqual(last, 1)
+	return nil
+}
+
+// isBlankLine returns 1 if the 4 bytes b are "\r\n\r\n", 0 otherwise
+func isBlankLine(api frontend.API, b []frontend.Variable) frontend.Variable {
+	var is frontend.Variable = 1
+	for i, c := range []byte("\r\n\r\n") {
+		is = api.Mul(is, api.IsZero(api.Sub(b[i], c)))
+	}
+	return is
+}
+
+// DoHPolicyRoot returns the policy root of the DoH firewall policy allowing the requests that start with prefix:
+// the SHA-256 of the prefix padded with zeros to DoHPrefixSize bytes and of its length on 2 bytes, big endian
+func DoHPolicyRoot(prefix []byte) ([]byte, error) {
+	if len(prefix) > DoHPrefixSize {
+		return nil, fmt.Errorf("prefix of %d bytes, the policy allows at most %d", len(prefix), DoHPrefixSize)
+	}
+	b := make([]byte, DoHPrefixSize+2)
+	copy(b, prefix)
+	binary.BigEndian.PutUint16(b[DoHPrefixSize:], uint16(len(prefix)))
+	root := sha256.Sum256(b)
+	return root[:], nil
+}
+
+// Assign sets the inputs of the circuit for the request of st, given the handshake secret of its connection
+// and the prefix whose policy root is that of st
+func (circuit *DoHFirewallCircuit) Assign(st Statement, hs, prefix []byte) error {
+	if err := circuit.KeySchedule.Assign(st, hs); err != nil {
+		return err
+	}
+	root, err := DoHPolicyRoot(prefix)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, st.PolicyRoot) {
+		return errors.New("the policy root of the statement is not that of the prefix")
+	}
+	for i := range circuit.Prefix {
+		circuit.Prefix[i] = 0
+		if i < len(prefix) {
+			circuit.Prefix[i] = prefix[i]
+		}
+	}
+	circuit.PrefixLen = len(prefix)
+	return circuit.assignPolicy(st)
+}
+
+// AssignPublic sets the public inputs of the circuit for the request of st, as a verifier that only observed
+// the traffic does; the secret ones are left unset
+func (circuit *DoHFirewallCircuit) AssignPublic(st Statement) error {
+	if _, err := circuit.KeySchedule.assignInputs(st); err != nil {
+		return err
+	}
+	return circuit.assignPolicy(st)
+}
+
+// assignPolicy sets Policy to the policy root of st, whose halves are the two elements of a SHA-256 commitment
+func (circuit *DoHFirewallCircuit) assignPolicy(st Statement) error {
+	if len(st.PolicyRoot) != 32 {
+		return fmt.Errorf("policy root of %d bytes, expected the 32 bytes of a SHA-256 digest", len(st.PolicyRoot))
+	}
+	for i := range circuit.Policy {
+		circuit.Policy[i] = new(big.Int).SetBytes(st.PolicyRoot[16*i : 16*i+16])
+	}
+	return nil
+}
+
+func init() {
+	Register(Definition{
+		Name: "tls-key-schedule",
+		Description: "HS shortcut of TLS 1.3 with AES-128-GCM: HS decrypts the server Finished and the request, " +
+			"committed to in Commitment, for the handshake of fwall/test_doh.txt",
+		New: func() frontend.Circuit { return NewTLSKeyScheduleCircuit(CloudflareShape) },
+	})
+	Register(Definition{
+		Name:        "doh-firewall",
+		Description: "tls-key-schedule, and the request is one DoH GET starting with the prefix committed to in Policy",
+		New:         func() frontend.Circuit { return NewDoHFirewallCircuit(CloudflareShape) },
+	})
+}
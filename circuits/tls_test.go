@@ -0,0 +1,257 @@
+package circuits
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+
+	"anonpao/aesgcm"
+	"anonpao/hkdf"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+const dohPrefix = "GET /dns-query?dns="
+
+// dohSession is the DoH session of fwall/test_doh.txt
+type dohSession struct {
+	st                  Statement
+	hs                  []byte
+	clientKey, clientIV []byte
+}
+
+// dohStatement returns the statement of the DoH session of fwall/test_doh.txt and its secrets.
+// The file holds the server handshake and the request without their content types and tags, which are
+// sealed again here into the records a middlebox sees.
+func dohStatement(t *testing.T) dohSession {
+	f, err := os.Open("../fwall/test_doh.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() && len(lines) < 14 {
+		// the request has an odd number of digits, of which fwall reads the whole bytes
+		line := strings.TrimSpace(scanner.Text())
+		b, err := hex.DecodeString(line[:len(line)-len(line)%2])
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, b)
+	}
+	hs, h3, chsh, ext, request := lines[6], lines[9], lines[11], lines[12], lines[13]
+	chLen := 4 + (int(chsh[1])<<16 | int(chsh[2])<<8 | int(chsh[3]))
+	h2 := sha256.Sum256(chsh)
+
+	shts := hkdf.HKDF_expand_derive_secret(hs, "s hs traffic", h2[:])
+	serverKey, serverIV := hkdf.HKDF_expand_derive_tk(shts, 16), hkdf.HKDF_expand_derive_iv(shts, 12)
+	empty := sha256.Sum256(nil)
+	ms := hkdf.HKDF_extract(hkdf.HKDF_expand_derive_secret(hs, "derived", empty[:]), make([]byte, 32))
+	cats := hkdf.HKDF_expand_derive_secret(ms, "c ap traffic", h3)
+	clientKey, clientIV := hkdf.HKDF_expand_derive_tk(cats, 16), hkdf.HKDF_expand_derive_iv(cats, 12)
+
+	// the request record of the file holds the request, its content type and the beginning of the tag
+	plaintext := aesgcm.AES_GCM_decrypt(clientKey, clientIV, request, 0)
+	end := strings.Index(string(plaintext), "\r\n\r\n") + 4
+	if !strings.HasPrefix(string(plaintext), dohPrefix) || end < 4 || plaintext[end] != 23 {
+		t.Fatalf("the request of the file decrypts to %q", plaintext)
+	}
+	plaintext = plaintext[:end]
+	root, err := DoHPolicyRoot([]byte(dohPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dohSession{
+		st: Statement{
+			ClientHello:     chsh[:chLen],
+			ServerHello:     chsh[chLen:],
+			ServerHandshake: [][]byte{seal(t, serverKey, serverIV, 0, aesgcm.AES_GCM_decrypt(serverKey, serverIV, ext, 0), 22)},
+			Ciphertext:      seal(t, clientKey, clientIV, 0, plaintext, 23),
+			PolicyRoot:      root,
+		},
+		hs:        hs,
+		clientKey: clientKey,
+		clientIV:  clientIV,
+	}
+}
+
+// seal returns the TLS 1.3 record of sequence number seq holding plaintext of the given content type
+func seal(t *testing.T, key, iv []byte, seq uint64, plaintext []byte, contentType byte) []byte {
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(plaintext) + 1 + tagSize
+	header := []byte{23, 3, 3, byte(n >> 8), byte(n)}
+	return gcm.Seal(header, aesgcm.TLS_nonce(iv, seq), append(append([]byte{}, plaintext...), contentType), header)
+}
+
+func TestCloudflareShape(t *testing.T) {
+	st := dohStatement(t).st
+	if shape := ShapeOf(st); !shape.equal(CloudflareShape) {
+		t.Fatalf("the session of fwall has a %s, CloudflareShape is a %s", shape, CloudflareShape)
+	}
+	l, err := CloudflareShape.layout()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the checkpoint of fwall, whose tail is the one get_tail_minus_36 returns
+	if l.tr3 != 3103 || l.checkpoint != 3008 || len(l.segments) != 1 || l.segments[0] != (tlsSegment{0, 3008 - 447, 95}) {
+		t.Fatalf("layout %+v", l)
+	}
+
+	for _, shape := range []TLSShape{
+		{ClientHello: 292, ServerHello: 155},
+		{ClientHello: 292, ServerHello: 155, ServerRecords: []int{100, 17}},
+		{ClientHello: 292, ServerHello: 155, ServerRecords: []int{100, 36 + 16}},
+		{ClientHello: 300, ServerHello: 155, ServerRecords: []int{60}},
+	} {
+		if _, err := shape.layout(); err == nil {
+			t.Errorf("%s is accepted", shape)
+		}
+	}
+	// a Finished in a record of its own, and a tail starting in the middle of a record
+	shape := TLSShape{ClientHello: 200, ServerHello: 100, ServerRecords: []int{27, 1000, 53}}
+	if l, err := shape.layout(); err != nil {
+		t.Fatal(err)
+	} else if want := []tlsSegment{{1, 970, 13}, {2, 0, 36}}; len(l.segments) != 2 || l.segments[0] != want[0] || l.segments[1] != want[1] {
+		t.Fatalf("segments %v, want %v", l.segments, want)
+	}
+}
+
+// The witness of the session of fwall satisfies both circuits, and a wrong handshake secret, checkpoint,
+// commitment, sequence number or policy does not
+func TestTLSCircuits(t *testing.T) {
+	session := dohStatement(t)
+	st, hs := session.st, session.hs
+	field := ecc.BN254.ScalarField()
+
+	valid := NewDoHFirewallCircuit(CloudflareShape)
+	if err := valid.Assign(st, hs, []byte(dohPrefix)); err != nil {
+		t.Fatal(err)
+	}
+	if err := test.IsSolved(NewTLSKeyScheduleCircuit(CloudflareShape), &valid.KeySchedule, field); err != nil {
+		t.Fatalf("tls-key-schedule: %v", err)
+	}
+	if err := test.IsSolved(NewDoHFirewallCircuit(CloudflareShape), valid, field); err != nil {
+		t.Fatalf("doh-firewall: %v", err)
+	}
+
+	otherHS := append([]byte{}, hs...)
+	otherHS[0] ^= 1
+	if err := NewTLSKeyScheduleCircuit(CloudflareShape).Assign(st, otherHS); err == nil {
+		t.Error("another handshake secret decrypts the server handshake")
+	}
+
+	for name, edit := range map[string]func(c *DoHFirewallCircuit){
+		"another handshake secret": func(c *DoHFirewallCircuit) { c.KeySchedule.HS[0] = hs[0] ^ 1 },
+		"another checkpoint":       func(c *DoHFirewallCircuit) { c.KeySchedule.Checkpoint[31] = 0 },
+		"another sequence number":  func(c *DoHFirewallCircuit) { c.KeySchedule.Sequence = 1 },
+		"another commitment":       func(c *DoHFirewallCircuit) { c.KeySchedule.Commitment[0] = 1 },
+		"another prefix": func(c *DoHFirewallCircuit) {
+			c.Prefix[5] = 'x'
+		},
+	} {
+		invalid := NewDoHFirewallCircuit(CloudflareShape)
+		if err := invalid.Assign(st, hs, []byte(dohPrefix)); err != nil {
+			t.Fatal(err)
+		}
+		edit(invalid)
+		if test.IsSolved(NewDoHFirewallCircuit(CloudflareShape), invalid, field) == nil {
+			t.Errorf("%s is accepted", name)
+		}
+	}
+}
+
+// A request that is not a single GET starting with the prefix of the policy is not proved
+func TestDoHFirewallPolicy(t *testing.T) {
+	session := dohStatement(t)
+	st, hs := session.st, session.hs
+	field := ecc.BN254.ScalarField()
+	for name, prefix := range map[string]string{
+		"another path":  "GET /resolve?name=",
+		"a POST":        "POST /dns-query",
+		"a long prefix": dohPrefix + strings.Repeat("A", 40),
+	} {
+		root, err := DoHPolicyRoot([]byte(prefix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		st.PolicyRoot = root
+		invalid := NewDoHFirewallCircuit(CloudflareShape)
+		if err := invalid.Assign(st, hs, []byte(prefix)); err != nil {
+			t.Fatal(err)
+		}
+		if test.IsSolved(NewDoHFirewallCircuit(CloudflareShape), invalid, field) == nil {
+			t.Errorf("%s is allowed", name)
+		}
+	}
+
+	// two requests in one record, which the second, sent past the firewall, would follow
+	st.PolicyRoot, _ = DoHPolicyRoot([]byte(dohPrefix))
+	plaintext := aesgcm.AES_GCM_decrypt(session.clientKey, session.clientIV, st.Ciphertext[5:len(st.Ciphertext)-17], 0)
+	st.Ciphertext = seal(t, session.clientKey, session.clientIV, 0, append(plaintext, "GET /admin HTTP/1.1\r\n\r\n"...), 23)
+	invalid := NewDoHFirewallCircuit(CloudflareShape)
+	if err := invalid.Assign(st, hs, []byte(dohPrefix)); err != nil {
+		t.Fatal(err)
+	}
+	if test.IsSolved(NewDoHFirewallCircuit(CloudflareShape), invalid, field) == nil {
+		t.Error("two requests in one record are allowed")
+	}
+}
+
+// The public inputs a verifier assigns from the traffic are those of the witness of the prover
+func TestAssignPublic(t *testing.T) {
+	session := dohStatement(t)
+	field := ecc.BN254.ScalarField()
+	full := NewDoHFirewallCircuit(CloudflareShape)
+	if err := full.Assign(session.st, session.hs, []byte(dohPrefix)); err != nil {
+		t.Fatal(err)
+	}
+	for name, c := range map[string]struct {
+		full   frontend.Circuit
+		public interface {
+			frontend.Circuit
+			AssignPublic(st Statement) error
+		}
+	}{
+		"tls-key-schedule": {&full.KeySchedule, NewTLSKeyScheduleCircuit(CloudflareShape)},
+		"doh-firewall":     {full, NewDoHFirewallCircuit(CloudflareShape)},
+	} {
+		if err := c.public.AssignPublic(session.st); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want, err := frontend.NewWitness(c.full, field, frontend.PublicOnly())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := frontend.NewWitness(c.public, field, frontend.PublicOnly())
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantBytes, _ := want.MarshalBinary()
+		gotBytes, _ := got.MarshalBinary()
+		if !bytes.Equal(gotBytes, wantBytes) {
+			t.Errorf("%s: the public inputs of the traffic are not those of the prover", name)
+		}
+	}
+
+	// a connection of another shape, or a policy root that is not a digest
+	other := session.st
+	other.ServerHandshake = [][]byte{session.st.ServerHandshake[0][:len(session.st.ServerHandshake[0])-1]}
+	if err := NewDoHFirewallCircuit(CloudflareShape).AssignPublic(other); err == nil || !strings.Contains(err.Error(), "compiled for") {
+		t.Errorf("a connection of another shape: %v", err)
+	}
+	other = session.st
+	other.PolicyRoot = other.PolicyRoot[:31]
+	if err := NewDoHFirewallCircuit(CloudflareShape).AssignPublic(other); err == nil {
+		t.Error("a policy root of 31 bytes is assigned")
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"flag"
+	"log"
+
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend/groth16"
 	"github.com/consensys/gnark/frontend"
@@ -24,17 +27,32 @@ func (circuit *CubicCircuit) Define(api frontend.API) error {
 	return nil
 }
 
+// curves the example can run on
+var curves = map[string]ecc.ID{
+	"bn254":     ecc.BN254,
+	"bls12_381": ecc.BLS12_381,
+	"bls12_377": ecc.BLS12_377,
+	"bw6_761":   ecc.BW6_761,
+}
+
 func main() {
+	curveName := flag.String("curve", "bn254", "curve to run the example on: bn254, bls12_381, bls12_377 or bw6_761")
+	flag.Parse()
+	curve, ok := curves[*curveName]
+	if !ok {
+		log.Fatal("unsupported curve ", *curveName)
+	}
+
 	// compiles our circuit into a R1CS
 	var circuit CubicCircuit
-	ccs, _ := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	ccs, _ := frontend.Compile(curve.ScalarField(), r1cs.NewBuilder, &circuit)
 
 	// groth16 zkSNARK: Setup
 	pk, vk, _ := groth16.Setup(ccs)
 
 	// witness definition
 	assignment := CubicCircuit{X: 3, Y: 35}
-	witness, _ := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	witness, _ := frontend.NewWitness(&assignment, curve.ScalarField())
 	publicWitness, _ := witness.Public()
 
 	// groth16: Prove & Verify
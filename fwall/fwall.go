@@ -9,7 +9,6 @@ import (
 	"strconv"
 
 	"anonpao/tls"
-	"anonpao/utils"
 )
 
 // Input line is assumed to be the hex representation of a byte string S
@@ -83,7 +82,6 @@ func main() {
 	ch_sh_line := values[11] // ClientHello || ServerHello
 	ext_line := values[12]   // EncryptedServerExtensions: Enc(Certificate || CertificateVerify || SF)
 	dns_ct := values[13]
-	H_state_tr7_line := values[14]
 
 	// ct3_tail_str is the part of ct3 that doesn't fit into a whole SHA block
 	// ct3_tail_str := get_tail_minus_36(ch_sh_line + ext_line)
@@ -98,7 +96,6 @@ func main() {
 	// log.Println("HS: ", len(HS_line)/2)
 
 	http_msg_ciphertext := make([]uint8, 500)
-	H_state_tr7 := make([]uint8, 32)
 	HS := make([]uint8, 32)
 	H2 := make([]uint8, 32)
 	H7 := make([]uint8, 32)
@@ -108,8 +105,8 @@ func main() {
 	// The tail is the suffix of the Extensions that does not fit inside a whole SHA block (64 bytes long)
 	ServExt_ct_tail := make([]uint8, 128)
 
-	ch_sh_len := uint16(len(ch_sh_line) / 2)
-	ServExt_ct_len := uint16(len(ext_line) / 2)
+	ch_sh_len := uint64(len(ch_sh_line) / 2)
+	ServExt_ct_len := uint64(len(ext_line) / 2)
 	ServExt_ct_tail_len := uint8(len(ct_lb) / 2)
 
 	// conversions:
@@ -152,11 +149,6 @@ func main() {
 	// 	ct3_tail[i] = uint8(0)
 	// }
 
-	for i := 0; i < len(H_state_tr7_line)/2; i++ {
-		ui64, _ := strconv.ParseUint(H_state_tr7_line[2*i:2*i+2], 16, 16)
-		H_state_tr7[i] = uint8(ui64)
-	}
-
 	for i := 0; i < len(dns_ct)/2; i++ {
 		ui64, _ := strconv.ParseUint(dns_ct[2*i:2*i+2], 16, 16)
 		http_msg_ciphertext[i] = uint8(ui64)
@@ -166,15 +158,14 @@ func main() {
 		http_msg_ciphertext[i] = 0
 	}
 
-	// now run the TLS Key Schedule
-	H_state_tr7_32 := utils.Convert_8_to_32(H_state_tr7)
-	// for i := 0; i < 8; i++ {
-	// 	// log.Println("H_state_tr7_32: ", H_state_tr7_32[i])
-	// 	log.Print(", ", hex.EncodeToString(utils.Convert_32_to_8(H_state_tr7_32))[i*8:(i+1)*8])
-	// }
-	// log.Println("H_state_tr7: ", hex.EncodeToString(H_state_tr7))
-	// log.Println("H_state_tr7_32 ", hex.EncodeToString(utils.Convert_32_to_8(H_state_tr7_32)))
+	// the checkpoint of SHA-256 common to TR3 and TR7, which the key schedule resumes from
+	tr7_checkpoint, err := tls.TR7_checkpoint(HS, H2, ch_sh, ServExt_ct)
+	if err != nil {
+		log.Fatal(err)
+	}
+	H_state_tr7_32 := tr7_checkpoint.H[:]
 
+	// now run the TLS Key Schedule
 	newvalues, err := tls.Get1RTT_HS_new(
 		HS, H2, H7,
 		ch_sh_len, ch_sh,
@@ -186,8 +177,6 @@ func main() {
 		fmt.Println("Error in TLS Key Schedule", err)
 	}
 
-	// log.Println("H_state_tr7_32 ", hex.EncodeToString(utils.Convert_32_to_8(H_state_tr7_32)))
-
 	plaintext := newvalues[0]
 	cr_int := 0x0d
 	lf_int := 0x0a
@@ -218,7 +207,7 @@ func main() {
 
 // private witnesses
 //  1. Handshake secret HS
-//  2. SHA_H_Checkpoint - the H-state of SHA up to the last whole block of TR7
+//  2. SHA_H_Checkpoint - the H-state of SHA up to the last whole block of TR7, see tls.TR7_checkpoint
 
 // public witnesses
 //  1. transcript hash H2 = hash( CH || SH)
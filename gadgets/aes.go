@@ -0,0 +1,136 @@
+package gadgets
+
+import (
+	"anonpao/aesgcm"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// AES128 encrypts blocks under a key expanded once in the circuit.
+// Blocks are 16 bytes in the order of FIPS-197, column by column.
+type AES128 struct {
+	api       frontend.API
+	roundKeys [11][][]frontend.Variable
+}
+
+// NewAES128 expands the 16-byte key
+func NewAES128(api frontend.API, key []frontend.Variable) *AES128 {
+	if len(key) != 16 {
+		panic("gadgets: AES-128 keys are 16 bytes")
+	}
+	return &AES128{api, aesExpandKey(api, bytesBits(api, key))}
+}
+
+// Encrypt returns the encryption of the 16-byte block
+func (a *AES128) Encrypt(block []frontend.Variable) []frontend.Variable {
+	if len(block) != 16 {
+		panic("gadgets: AES blocks are 16 bytes")
+	}
+	return bitsBytes(a.api, a.encryptBits(bytesBits(a.api, block)))
+}
+
+// encryptBits is Encrypt on the bits of the bytes of the block
+func (a *AES128) encryptBits(block [][]frontend.Variable) [][]frontend.Variable {
+	state := make([][]frontend.Variable, 16)
+	for i := range state {
+		state[i] = xorBits(a.api, block[i], a.roundKeys[0][i])
+	}
+	for round := 1; round <= 10; round++ {
+		state = aesRound(a.api, state, a.roundKeys[round], round == 10)
+	}
+	return state
+}
+
+// aesExpandKey returns the 11 round keys of AES-128, FIPS-197 section 5.2
+func aesExpandKey(api frontend.API, key [][]frontend.Variable) (roundKeys [11][][]frontend.Variable) {
+	w := append([][]frontend.Variable{}, key...)
+	for i := 4; i < 44; i++ {
+		temp := w[4*(i-1) : 4*i]
+		if i%4 == 0 {
+			// SubWord(RotWord(temp)) xor Rcon[i/4]
+			temp = [][]frontend.Variable{
+				xorConst(api, sbox(api, temp[1]), aesgcm.RCON[i/4]),
+				sbox(api, temp[2]),
+				sbox(api, temp[3]),
+				sbox(api, temp[0]),
+			}
+		}
+		for k := 0; k < 4; k++ {
+			w = append(w, xorBits(api, w[4*(i-4)+k], temp[k]))
+		}
+	}
+	for round := range roundKeys {
+		roundKeys[round] = w[16*round : 16*round+16]
+	}
+	return roundKeys
+}
+
+// aesRound applies SubBytes, ShiftRows, MixColumns (except in the last round) and AddRoundKey to the state
+func aesRound(api frontend.API, state, roundKey [][]frontend.Variable, last bool) [][]frontend.Variable {
+	// the byte in row r and column c is state[r+4c]
+	shifted := make([][]frontend.Variable, 16)
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			shifted[r+4*c] = sbox(api, state[r+4*((c+r)%4)])
+		}
+	}
+
+	mixed := shifted
+	if !last {
+		mixed = make([][]frontend.Variable, 16)
+		for c := 0; c < 4; c++ {
+			a := shifted[4*c : 4*c+4]
+			var x [4][]frontend.Variable
+			for r := range x {
+				x[r] = xtime(api, a[r])
+			}
+			// 2a0 + 3a1 + a2 + a3, 3a1 being 2a1 + a1, and so on by rotating the column
+			for r := 0; r < 4; r++ {
+				terms := [][]frontend.Variable{x[r], x[(r+1)%4], a[(r+1)%4], a[(r+2)%4], a[(r+3)%4]}
+				mixed[r+4*c] = terms[0]
+				for _, t := range terms[1:] {
+					mixed[r+4*c] = xorBits(api, mixed[r+4*c], t)
+				}
+			}
+		}
+	}
+
+	next := make([][]frontend.Variable, 16)
+	for i := range next {
+		next[i] = xorBits(api, mixed[i], roundKey[i])
+	}
+	return next
+}
+
+// xtime multiplies the byte b by x in GF(2^8) modulo x^8 + x^4 + x^3 + x + 1
+func xtime(api frontend.API, b []frontend.Variable) []frontend.Variable {
+	// shifting left drops b7, which is reduced by adding 0x1b
+	return []frontend.Variable{
+		b[7],
+		api.Xor(b[0], b[7]),
+		b[1],
+		api.Xor(b[2], b[7]),
+		api.Xor(b[3], b[7]),
+		b[4],
+		b[5],
+		b[6],
+	}
+}
+
+// sbox looks the byte b up in the S-box of AES. The table is a binary tree of selections on the bits of b,
+// the first level of which is linear in the constants of the table: 127 constraints, and 9 to decompose the result.
+func sbox(api frontend.API, b []frontend.Variable) []frontend.Variable {
+	level := make([]frontend.Variable, 128)
+	for k := range level {
+		lo, hi := int(aesgcm.SBOX[2*k]), int(aesgcm.SBOX[2*k+1])
+		level[k] = api.Add(lo, api.Mul(b[0], hi-lo))
+	}
+	for i := 1; i < 8; i++ {
+		next := make([]frontend.Variable, len(level)/2)
+		for k := range next {
+			next[k] = api.Select(b[i], level[2*k+1], level[2*k])
+		}
+		level = next
+	}
+	return byteBits(api, level[0])
+}
@@ -0,0 +1,76 @@
+// Package gadgets implements in circuit the primitives of the native packages sha2, hkdf and aesgcm:
+// the SHA-256 compression function, HMAC-SHA256, the AES-128 block cipher and AES-128-GCM with its GHASH.
+//
+// Bytes are one frontend.Variable each, as in the witnesses (see snark/witness.go), and lengths are fixed
+// when the circuit is compiled. The gadgets work on the bits of the bytes, least significant bit first,
+// which they get with api.ToBinary: this also constrains every input byte to be smaller than 256.
+package gadgets
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+// byteBits returns the 8 bits of b, least significant first
+func byteBits(api frontend.API, b frontend.Variable) []frontend.Variable {
+	return api.ToBinary(b, 8)
+}
+
+// bytesBits returns the bits of every byte of bs
+func bytesBits(api frontend.API, bs []frontend.Variable) [][]frontend.Variable {
+	bits := make([][]frontend.Variable, len(bs))
+	for i := range bs {
+		bits[i] = byteBits(api, bs[i])
+	}
+	return bits
+}
+
+// bitsBytes is the inverse of bytesBits, it costs no constraint
+func bitsBytes(api frontend.API, bits [][]frontend.Variable) []frontend.Variable {
+	bs := make([]frontend.Variable, len(bits))
+	for i := range bits {
+		bs[i] = api.FromBinary(bits[i]...)
+	}
+	return bs
+}
+
+// xorBits returns the bitwise xor of a and b, one constraint per bit
+func xorBits(api frontend.API, a, b []frontend.Variable) []frontend.Variable {
+	c := make([]frontend.Variable, len(a))
+	for i := range a {
+		c[i] = api.Xor(a[i], b[i])
+	}
+	return c
+}
+
+// xorConst returns the bitwise xor of the bits a with the constant byte c, which costs no constraint
+func xorConst(api frontend.API, a []frontend.Variable, c byte) []frontend.Variable {
+	b := make([]frontend.Variable, len(a))
+	for i := range a {
+		if c>>i&1 == 1 {
+			b[i] = api.Sub(1, a[i])
+		} else {
+			b[i] = a[i]
+		}
+	}
+	return b
+}
+
+// constBits returns the bits of the constant byte c
+func constBits(c byte) []frontend.Variable {
+	bits := make([]frontend.Variable, 8)
+	for i := range bits {
+		bits[i] = c >> i & 1
+	}
+	return bits
+}
+
+// sum adds the variables vs, which may be empty
+func sum(api frontend.API, vs ...frontend.Variable) frontend.Variable {
+	switch len(vs) {
+	case 0:
+		return 0
+	case 1:
+		return vs[0]
+	}
+	return api.Add(vs[0], vs[1], vs[2:]...)
+}
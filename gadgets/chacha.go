@@ -0,0 +1,199 @@
+package gadgets
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/emulated"
+)
+
+// ChaCha20 only adds, xors and rotates 32-bit words: a rotation is free, a xor costs a constraint per bit
+// and an addition the decomposition of the sum, about 22k constraints per 64-byte block where AES-128
+// spends its S-boxes on every 16-byte block.
+
+// leWord packs the bits of 4 bytes into a word, little-endian as in ChaCha20
+func leWord(b [][]frontend.Variable) (w word) {
+	for k := 0; k < 4; k++ {
+		copy(w[8*k:], b[k])
+	}
+	return w
+}
+
+// leBytes is the inverse of leWord
+func (w word) leBytes() [][]frontend.Variable {
+	b := make([][]frontend.Variable, 4)
+	for k := range b {
+		b[k] = w[8*k : 8*k+8]
+	}
+	return b
+}
+
+func (w word) rotl(n int) word {
+	return w.rotr(32 - n)
+}
+
+func xorWords(api frontend.API, a, b word) (r word) {
+	copy(r[:], xorBits(api, a[:], b[:]))
+	return r
+}
+
+func chachaQuarterRound(api frontend.API, a, b, c, d word) (word, word, word, word) {
+	a = add32(api, a.value(api), b.value(api))
+	d = xorWords(api, d, a).rotl(16)
+	c = add32(api, c.value(api), d.value(api))
+	b = xorWords(api, b, c).rotl(12)
+	a = add32(api, a.value(api), b.value(api))
+	d = xorWords(api, d, a).rotl(8)
+	c = add32(api, c.value(api), d.value(api))
+	b = xorWords(api, b, c).rotl(7)
+	return a, b, c, d
+}
+
+// chacha20Block returns the 64 bytes of keystream of the block of the given counter, as chacha20poly1305's
+// chacha20_block, from the words of the key and the nonce
+func chacha20Block(api frontend.API, key [8]word, nonce [3]word, counter uint32) [][]frontend.Variable {
+	var s [16]word
+	for i, c := range []uint32{0x61707865, 0x3320646e, 0x79622d32, 0x6b206574} {
+		s[i] = constWord(c)
+	}
+	copy(s[4:], key[:])
+	s[12] = constWord(counter)
+	copy(s[13:], nonce[:])
+
+	x := s
+	for round := 0; round < 10; round++ {
+		for i := 0; i < 4; i++ {
+			x[i], x[4+i], x[8+i], x[12+i] = chachaQuarterRound(api, x[i], x[4+i], x[8+i], x[12+i])
+		}
+		for i := 0; i < 4; i++ {
+			a, b, c, d := i, 4+(i+1)%4, 8+(i+2)%4, 12+(i+3)%4
+			x[a], x[b], x[c], x[d] = chachaQuarterRound(api, x[a], x[b], x[c], x[d])
+		}
+	}
+
+	out := make([][]frontend.Variable, 0, 64)
+	for i := range x {
+		out = append(out, add32(api, x[i].value(api), s[i].value(api)).leBytes()...)
+	}
+	return out
+}
+
+// chachaWords returns the words of the 32-byte key and the 12-byte nonce
+func chachaWords(api frontend.API, key, nonce []frontend.Variable) (k [8]word, n [3]word) {
+	if len(key) != 32 {
+		panic("gadgets: ChaCha20 keys are 32 bytes")
+	}
+	if len(nonce) != 12 {
+		panic("gadgets: ChaCha20 nonces are 12 bytes")
+	}
+	keyBits, nonceBits := bytesBits(api, key), bytesBits(api, nonce)
+	for i := range k {
+		k[i] = leWord(keyBits[4*i : 4*i+4])
+	}
+	for i := range n {
+		n[i] = leWord(nonceBits[4*i : 4*i+4])
+	}
+	return k, n
+}
+
+// chacha20XOR xors the bits of the bytes in with the keystream from the block of the given counter
+func chacha20XOR(api frontend.API, key [8]word, nonce [3]word, counter uint32, in [][]frontend.Variable) [][]frontend.Variable {
+	if uint64(counter)+uint64(len(in)+63)/64 > 1<<32 {
+		panic("gadgets: the ChaCha20 block counter wraps around")
+	}
+	out := make([][]frontend.Variable, len(in))
+	for i := 0; i < len(in); i += 64 {
+		keystream := chacha20Block(api, key, nonce, counter+uint32(i/64))
+		for k := i; k < len(in) && k < i+64; k++ {
+			out[k] = xorBits(api, in[k], keystream[k-i])
+		}
+	}
+	return out
+}
+
+// ChaCha20 xors in with the keystream of the 32-byte key and 12-byte nonce from the block of the given
+// counter: as chacha20poly1305.ChaCha20_encrypt from block starting_block of a record with the counter
+// starting_block + 1
+func ChaCha20(api frontend.API, key, nonce []frontend.Variable, counter uint32, in []frontend.Variable) []frontend.Variable {
+	k, n := chachaWords(api, key, nonce)
+	return bitsBytes(api, chacha20XOR(api, k, n, counter, bytesBits(api, in)))
+}
+
+// ChaCha20Poly1305Seal encrypts plaintext with ChaCha20-Poly1305 under the 32-byte key and the 12-byte nonce,
+// authenticating aad, and returns the ciphertext and the 16-byte tag (RFC 8439 section 2.8)
+func ChaCha20Poly1305Seal(api frontend.API, key, nonce, plaintext, aad []frontend.Variable) (ciphertext, tag []frontend.Variable) {
+	k, n := chachaWords(api, key, nonce)
+	ct := chacha20XOR(api, k, n, 1, bytesBits(api, plaintext))
+
+	// the Poly1305 of aad and the ciphertext, each padded with zeros to a whole number of blocks, then of their
+	// lengths as 64-bit little-endian integers, under the first 32 bytes of the block of counter 0
+	blocks := append(padBlock(bytesBits(api, aad)), padBlock(ct)...)
+	for k := 0; k < 8; k++ {
+		blocks = append(blocks, constBits(byte(uint64(len(aad))>>(8*k))))
+	}
+	for k := 0; k < 8; k++ {
+		blocks = append(blocks, constBits(byte(uint64(len(ct))>>(8*k))))
+	}
+	otk := chacha20Block(api, k, n, 0)[:32]
+	return bitsBytes(api, ct), bitsBytes(api, poly1305(api, otk, blocks))
+}
+
+// poly1305Field is the field of Poly1305, modulo 2^130 - 5, emulated on 2 limbs of 65 bits
+type poly1305Field struct{}
+
+var poly1305Modulus = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 130), big.NewInt(5))
+
+func (poly1305Field) NbLimbs() uint     { return 2 }
+func (poly1305Field) BitsPerLimb() uint { return 65 }
+func (poly1305Field) IsPrime() bool     { return true }
+func (poly1305Field) Modulus() *big.Int { return poly1305Modulus }
+
+// poly1305 returns the bits of the tag of the bytes b, whose length is a multiple of 16, under the one-time
+// key r || s of 32 bytes: h = (h + block + 2^128)·r modulo 2^130 - 5 over the blocks, then h + s modulo 2^128
+func poly1305(api frontend.API, key, b [][]frontend.Variable) [][]frontend.Variable {
+	f, err := emulated.NewField[poly1305Field](api)
+	if err != nil {
+		panic(err)
+	}
+	// the bytes of a block, little-endian, followed by a one
+	element := func(block [][]frontend.Variable) *emulated.Element[poly1305Field] {
+		bits := make([]frontend.Variable, 0, 129)
+		for _, byteBits := range block {
+			bits = append(bits, byteBits...)
+		}
+		return f.FromBits(append(bits, 1)...)
+	}
+
+	// r is clamped: the top 4 bits of its bytes 3, 7, 11 and 15, and the bottom 2 of 4, 8 and 12 are cleared
+	rBits := make([]frontend.Variable, 0, 128)
+	for i, byteBits := range key[:16] {
+		for j, bit := range byteBits {
+			if (i%4 == 3 && j >= 4) || (i%4 == 0 && i > 0 && j < 2) {
+				bit = 0
+			}
+			rBits = append(rBits, bit)
+		}
+	}
+	r := f.FromBits(rBits...)
+
+	h := f.Zero()
+	for i := 0; i < len(b); i += 16 {
+		h = f.Mul(f.Add(h, element(b[i:i+16])), r)
+	}
+	// the canonical bits of h
+	h = f.Reduce(h)
+	f.AssertIsInRange(h)
+	hBits := f.ToBits(h)[:128]
+
+	var sBits []frontend.Variable
+	for _, byteBits := range key[16:32] {
+		sBits = append(sBits, byteBits...)
+	}
+	tagBits := api.ToBinary(api.Add(api.FromBinary(hBits...), api.FromBinary(sBits...)), 129)
+
+	tag := make([][]frontend.Variable, 16)
+	for k := range tag {
+		tag[k] = tagBits[8*k : 8*k+8]
+	}
+	return tag
+}
@@ -0,0 +1,101 @@
+package gadgets
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// A commitment replaces many public bytes by one or two public field elements: the circuit takes the bytes
+// as secret inputs and exposes their commitment only, which the verifier recomputes with CommitBytes from
+// the bytes it observed. The number of public inputs, and so the cost of verifying a Groth16 proof,
+// no longer depends on the number of bytes.
+//
+//	SHA256Commitment    the SHA-256 of the bytes, as two field elements: the first and the last 16 bytes of the digest
+//	PoseidonCommitment  h₀ = n, hᵢ₊₁ = Poseidon(hᵢ, cᵢ), the cᵢ being the n bytes in chunks of 31 (BN254 only)
+//
+// Chunks and digest halves are read big endian. Poseidon costs about 250 constraints per chunk (plus the
+// range checks of the bytes) where SHA-256 costs about 30000 per 64 bytes, but it needs the BN254 scalar field.
+
+// Commitment is a scheme committing to bytes, see commit.go
+type Commitment int
+
+const (
+	SHA256Commitment Commitment = iota
+	PoseidonCommitment
+)
+
+// poseidonChunk is the number of bytes packed in one field element, which must stay below the 254-bit order
+const poseidonChunk = 31
+
+func (c Commitment) String() string {
+	switch c {
+	case SHA256Commitment:
+		return "sha256"
+	case PoseidonCommitment:
+		return "poseidon"
+	}
+	return fmt.Sprintf("Commitment(%d)", int(c))
+}
+
+// Size is the number of field elements of a commitment
+func (c Commitment) Size() int {
+	if c == SHA256Commitment {
+		return 2
+	}
+	return 1
+}
+
+// Commit returns the commitment to the bytes b, range checking them
+func Commit(api frontend.API, c Commitment, b []frontend.Variable) []frontend.Variable {
+	switch c {
+	case SHA256Commitment:
+		digest := SHA256(api, b)
+		return []frontend.Variable{packBytes(api, digest[:16]), packBytes(api, digest[16:])}
+
+	case PoseidonCommitment:
+		// the bits are not needed, only the constraint that every byte is smaller than 256
+		bytesBits(api, b)
+		h := frontend.Variable(len(b))
+		for i := 0; i < len(b); i += poseidonChunk {
+			h = Poseidon(api, h, packBytes(api, b[i:min(i+poseidonChunk, len(b))]))
+		}
+		return []frontend.Variable{h}
+	}
+	panic("gadgets: unknown commitment " + c.String())
+}
+
+// CommitBytes returns the commitment to the bytes b computed by Commit, outside of any circuit
+func CommitBytes(c Commitment, b []byte) []*big.Int {
+	switch c {
+	case SHA256Commitment:
+		digest := sha256.Sum256(b)
+		return []*big.Int{new(big.Int).SetBytes(digest[:16]), new(big.Int).SetBytes(digest[16:])}
+
+	case PoseidonCommitment:
+		h := big.NewInt(int64(len(b)))
+		for i := 0; i < len(b); i += poseidonChunk {
+			h = PoseidonNative(h, new(big.Int).SetBytes(b[i:min(i+poseidonChunk, len(b))]))
+		}
+		return []*big.Int{h}
+	}
+	panic("gadgets: unknown commitment " + c.String())
+}
+
+// packBytes returns the big-endian integer of the bytes b, which costs no constraint with Groth16
+func packBytes(api frontend.API, b []frontend.Variable) frontend.Variable {
+	var x frontend.Variable = 0
+	for i := range b {
+		x = api.Add(api.Mul(x, 256), b[i])
+	}
+	return x
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
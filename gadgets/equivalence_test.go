@@ -0,0 +1,268 @@
+package gadgets
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"anonpao/aesgcm"
+	"anonpao/chacha20poly1305"
+	"anonpao/hkdf"
+	"anonpao/sha2"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// An equivalence declares that a gadget computes a native function: both take byte inputs of the given
+// lengths, and return the same outputs, bytes or field elements. checkEquivalence draws random inputs and
+// checks that the gadget is satisfied by the native outputs, and by no other: neither by a corrupted
+// output, nor by the native output with a corrupted input.
+type equivalence struct {
+	name   string
+	inputs []int
+	native func(in [][]byte) []frontend.Variable
+	gadget func(api frontend.API, in [][]frontend.Variable) []frontend.Variable
+	// curves restricts the curves of the check, for gadgets that need a given scalar field
+	curves []ecc.ID
+}
+
+var equivalenceCurves = []ecc.ID{ecc.BN254, ecc.BLS12_381, ecc.BLS12_377}
+
+// equivalenceWitnesses is the number of random witnesses checked for each equivalence, the first with a
+// corrupted output and the second with a corrupted input
+const equivalenceWitnesses = 2
+
+// equivalenceCircuit checks the gadget of equivalences[index], an index rather than the function as gnark's
+// test engine compares copies of the circuit with reflect.DeepEqual
+type equivalenceCircuit struct {
+	In    [][]frontend.Variable
+	Out   []frontend.Variable `gnark:",public"`
+	index int
+}
+
+func (c *equivalenceCircuit) Define(api frontend.API) error {
+	out := equivalences[c.index].gadget(api, c.In)
+	if len(out) != len(c.Out) {
+		return fmt.Errorf("the gadget has %d outputs, the native function %d", len(out), len(c.Out))
+	}
+	for i := range out {
+		api.AssertIsEqual(out[i], c.Out[i])
+	}
+	return nil
+}
+
+func (e equivalence) assignment(index int, in [][]byte) *equivalenceCircuit {
+	a := &equivalenceCircuit{In: make([][]frontend.Variable, len(in)), Out: e.native(in), index: index}
+	for i := range in {
+		a.In[i] = variables(in[i])
+	}
+	return a
+}
+
+// corrupt returns v, a byte or a field element, changed to another value
+func corrupt(v frontend.Variable, r *rand.Rand) frontend.Variable {
+	delta := big.NewInt(1 + r.Int63n(255))
+	switch v := v.(type) {
+	case byte:
+		return new(big.Int).Add(big.NewInt(int64(v)), delta)
+	case *big.Int:
+		return new(big.Int).Add(v, delta)
+	}
+	panic(fmt.Sprintf("unexpected output %T", v))
+}
+
+func checkEquivalence(t *testing.T, index int) {
+	e := equivalences[index]
+	r := rand.New(rand.NewSource(int64(index)))
+	circuit := &equivalenceCircuit{In: make([][]frontend.Variable, len(e.inputs)), index: index}
+	for i, n := range e.inputs {
+		circuit.In[i] = make([]frontend.Variable, n)
+	}
+
+	var opts []test.TestingOption
+	for k := 0; k < equivalenceWitnesses; k++ {
+		in := make([][]byte, len(e.inputs))
+		for i, n := range e.inputs {
+			in[i] = make([]byte, n)
+			r.Read(in[i])
+		}
+		valid := e.assignment(index, in)
+		if k == 0 {
+			circuit.Out = make([]frontend.Variable, len(valid.Out))
+		}
+		opts = append(opts, test.WithValidAssignment(valid))
+
+		invalid := e.assignment(index, in)
+		if k == 0 {
+			j := r.Intn(len(invalid.Out))
+			invalid.Out[j] = corrupt(invalid.Out[j], r)
+		} else {
+			// the output of the input before its corruption
+			i := r.Intn(len(in))
+			j := r.Intn(len(in[i]))
+			invalid.In[i][j] = in[i][j] ^ byte(1+r.Intn(255))
+		}
+		opts = append(opts, test.WithInvalidAssignment(invalid))
+	}
+
+	curves := e.curves
+	if curves == nil {
+		curves = equivalenceCurves
+	}
+	if testing.Short() {
+		curves = curves[:1]
+	}
+	opts = append(opts, test.WithCurves(curves[0], curves[1:]...), test.WithBackends(backend.GROTH16, backend.PLONK))
+	test.NewAssert(t).CheckCircuit(circuit, opts...)
+}
+
+// concat returns the concatenation of the slices vs
+func concat(vs ...[]frontend.Variable) []frontend.Variable {
+	var c []frontend.Variable
+	for _, v := range vs {
+		c = append(c, v...)
+	}
+	return c
+}
+
+var equivalences = []equivalence{
+	// one block, a pad-only last block, and two blocks
+	{name: "sha256-55", inputs: []int{55}, native: func(in [][]byte) []frontend.Variable { return variables(sha2.SHA2(in[0])) },
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable { return SHA256(api, in[0]) }},
+	{name: "sha256-64", inputs: []int{64}, native: func(in [][]byte) []frontend.Variable { return variables(sha2.SHA2(in[0])) },
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable { return SHA256(api, in[0]) }},
+	{name: "sha256-100", inputs: []int{100}, native: func(in [][]byte) []frontend.Variable { return variables(sha2.SHA2(in[0])) },
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable { return SHA256(api, in[0]) }},
+
+	// prefixes of a message from a checkpoint after one block: the state and the 136 following bytes
+	{name: "sha256-prefixes", inputs: []int{32, 136},
+		native: func(in [][]byte) []frontend.Variable {
+			c := sha2.Checkpoint{Length: 64}
+			for i := range c.H {
+				c.H[i] = binary.BigEndian.Uint32(in[0][4*i:])
+			}
+			digests, err := sha2.SumPrefixes(c, in[1], []uint64{64, 120, 128, 200})
+			if err != nil {
+				panic(err)
+			}
+			return variables(append(append(append(digests[0], digests[1]...), digests[2]...), digests[3]...))
+		},
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			return concat(SHA256Prefixes(api, in[0], 64, in[1], []uint64{64, 120, 128, 200})...)
+		}},
+
+	{name: "hmac-sha256", inputs: []int{32, 48},
+		native: func(in [][]byte) []frontend.Variable { return variables(hkdf.HMAC(in[0], in[1])) },
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable { return HMACSHA256(api, in[0], in[1]) }},
+
+	// the server handshake traffic secret of TLS 1.3 from the handshake secret and H2, as in tls.Get1RTT_HS_new
+	{name: "hkdf-derive-secret", inputs: []int{32, 32},
+		native: func(in [][]byte) []frontend.Variable {
+			return variables(hkdf.HKDF_expand_derive_secret(in[0], "s hs traffic", in[1]))
+		},
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			return HKDFExpandLabel(api, in[0], "s hs traffic", in[1], 32)
+		}},
+
+	// the ServerFinished value of TLS 1.3 from the server handshake traffic secret and H7, as in tls.Get1RTT_HS_new
+	{name: "tls-server-finished", inputs: []int{32, 32},
+		native: func(in [][]byte) []frontend.Variable {
+			fk_S := hkdf.HKDF_expand_derive_secret(in[0], "finished", []byte{})
+			return variables(hkdf.HMAC(fk_S, in[1]))
+		},
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			fk_S := HKDFExpandLabel(api, in[0], "finished", nil, 32)
+			return HMACSHA256(api, fk_S, in[1])
+		}},
+	// the traffic key and iv of AES-128-GCM from a traffic secret
+	{name: "hkdf-traffic-key", inputs: []int{32},
+		native: func(in [][]byte) []frontend.Variable {
+			return variables(append(hkdf.HKDF_expand_derive_tk(in[0], 16), hkdf.HKDF_expand_derive_iv(in[0], 12)...))
+		},
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			return concat(HKDFExpandLabel(api, in[0], "key", nil, 16), HKDFExpandLabel(api, in[0], "iv", nil, 12))
+		}},
+
+	// the ciphertext of a record with a partial last block: the native aesgcm does not compute the tag
+	{name: "aes128-gcm", inputs: []int{16, 12, 37},
+		native: func(in [][]byte) []frontend.Variable {
+			return variables(aesgcm.AES_GCM_encrypt(in[0], in[1], in[2], 0))
+		},
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			ct, _ := AESGCMSeal(api, in[0], in[1], in[2], nil)
+			return ct
+		}},
+
+	// the keystream from the middle of a block, as the HS shortcut decrypts the tail of the server handshake
+	{name: "aes128-gcm-middle", inputs: []int{16, 12, 128},
+		native: func(in [][]byte) []frontend.Variable {
+			return variables(aesgcm.AES_GCM_decrypt_128bytes_middle(in[0], in[1], in[2], 5, 7))
+		},
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			return NewAES128(api, in[0]).XORKeyStream(in[1], 5, 7, in[2])
+		}},
+	// the nonce of a record whose sequence number is a variable
+	{name: "tls-nonce", inputs: []int{12, 8},
+		native: func(in [][]byte) []frontend.Variable {
+			return variables(aesgcm.TLS_nonce(in[0], binary.BigEndian.Uint64(in[1])))
+		},
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			var seq frontend.Variable = 0
+			for _, b := range in[1] {
+				seq = api.Add(api.Mul(seq, 256), b)
+			}
+			return TLSNonce(api, in[0], seq)
+		}},
+
+	// a record with a partial last block and its header as additional data, and the keystream from the middle of a record
+	{name: "chacha20-poly1305", inputs: []int{32, 12, 5, 70},
+		native: func(in [][]byte) []frontend.Variable {
+			ct := chacha20poly1305.ChaCha20_encrypt(in[0], in[1], in[3], 0)
+			return variables(append(ct, chacha20poly1305.Compute_tag(in[0], in[1], in[2], ct)...))
+		},
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			ct, tag := ChaCha20Poly1305Seal(api, in[0], in[1], in[3], in[2])
+			return concat(ct, tag)
+		}},
+	{name: "chacha20-block-3", inputs: []int{32, 12, 40},
+		native: func(in [][]byte) []frontend.Variable {
+			return variables(chacha20poly1305.ChaCha20_decrypt(in[0], in[1], in[2], 3))
+		},
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			return ChaCha20(api, in[0], in[1], 4, in[2])
+		}},
+
+	{name: "commit-sha256", inputs: []int{70},
+		native: func(in [][]byte) []frontend.Variable { return fieldElements(CommitBytes(SHA256Commitment, in[0])) },
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			return Commit(api, SHA256Commitment, in[0])
+		}},
+	{name: "commit-poseidon", inputs: []int{70}, curves: []ecc.ID{ecc.BN254},
+		native: func(in [][]byte) []frontend.Variable { return fieldElements(CommitBytes(PoseidonCommitment, in[0])) },
+		gadget: func(api frontend.API, in [][]frontend.Variable) []frontend.Variable {
+			return Commit(api, PoseidonCommitment, in[0])
+		}},
+}
+
+func fieldElements(xs []*big.Int) []frontend.Variable {
+	vs := make([]frontend.Variable, len(xs))
+	for i := range xs {
+		vs[i] = xs[i]
+	}
+	return vs
+}
+
+func TestEquivalence(t *testing.T) {
+	for i := range equivalences {
+		i := i
+		t.Run(equivalences[i].name, func(t *testing.T) {
+			t.Parallel()
+			checkEquivalence(t, i)
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package gadgets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"anonpao/sha2"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/test"
+)
+
+func variables(b []byte) []frontend.Variable {
+	vs := make([]frontend.Variable, len(b))
+	for i := range b {
+		vs[i] = b[i]
+	}
+	return vs
+}
+
+func assertBytesEqual(api frontend.API, got, want []frontend.Variable) {
+	for i := range want {
+		api.AssertIsEqual(got[i], want[i])
+	}
+}
+
+type sha256Circuit struct {
+	Msg, Digest []frontend.Variable
+}
+
+func (c *sha256Circuit) Define(api frontend.API) error {
+	assertBytesEqual(api, SHA256(api, c.Msg), c.Digest)
+	return nil
+}
+
+type prefixesCircuit struct {
+	State, Tail []frontend.Variable
+	Digests     [][]frontend.Variable
+	stateLength uint64
+	lengths     []uint64
+}
+
+func (c *prefixesCircuit) Define(api frontend.API) error {
+	for i, digest := range SHA256Prefixes(api, c.State, c.stateLength, c.Tail, c.lengths) {
+		assertBytesEqual(api, digest, c.Digests[i])
+	}
+	return nil
+}
+
+type hmacCircuit struct {
+	Key, Msg, MAC []frontend.Variable
+}
+
+func (c *hmacCircuit) Define(api frontend.API) error {
+	assertBytesEqual(api, HMACSHA256(api, c.Key, c.Msg), c.MAC)
+	return nil
+}
+
+type gcmCircuit struct {
+	Key, Nonce, Plaintext, AAD, Ciphertext, Tag []frontend.Variable
+}
+
+func (c *gcmCircuit) Define(api frontend.API) error {
+	ct, tag := AESGCMSeal(api, c.Key, c.Nonce, c.Plaintext, c.AAD)
+	assertBytesEqual(api, ct, c.Ciphertext)
+	assertBytesEqual(api, tag, c.Tag)
+	return nil
+}
+
+type chachaCircuit struct {
+	Key, Nonce, Plaintext, AAD, Ciphertext, Tag []frontend.Variable
+}
+
+func (c *chachaCircuit) Define(api frontend.API) error {
+	ct, tag := ChaCha20Poly1305Seal(api, c.Key, c.Nonce, c.Plaintext, c.AAD)
+	assertBytesEqual(api, ct, c.Ciphertext)
+	assertBytesEqual(api, tag, c.Tag)
+	return nil
+}
+
+type poseidonCircuit struct {
+	A, B, H frontend.Variable
+}
+
+func (c *poseidonCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(Poseidon(api, c.A, c.B), c.H)
+	return nil
+}
+
+type commitCircuit struct {
+	Bytes, Commitment []frontend.Variable
+	scheme            Commitment
+}
+
+func (c *commitCircuit) Define(api frontend.API) error {
+	assertBytesEqual(api, Commit(api, c.scheme, c.Bytes), c.Commitment)
+	return nil
+}
+
+// counting returns n bytes starting from first and increasing by one
+func counting(first byte, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = first + byte(i)
+	}
+	return b
+}
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestSHA256(t *testing.T) {
+	// one block, the largest message that fits in one block, and two blocks
+	for _, n := range []int{3, 55, 64} {
+		msg := counting(0, n)
+		digest := sha256.Sum256(msg)
+		circuit := &sha256Circuit{make([]frontend.Variable, n), make([]frontend.Variable, 32)}
+		if err := test.IsSolved(circuit, &sha256Circuit{variables(msg), variables(digest[:])}, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		digest[0]++
+		if err := test.IsSolved(circuit, &sha256Circuit{variables(msg), variables(digest[:])}, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("%d bytes: wrong digest accepted", n)
+		}
+	}
+}
+
+func TestSHA256Prefixes(t *testing.T) {
+	msg := counting(0, 200)
+	d := sha2.New()
+	d.Write(msg[:64])
+	c, _ := d.Checkpoint()
+	state := make([]byte, 0, 32)
+	for _, h := range c.H {
+		state = binary.BigEndian.AppendUint32(state, h)
+	}
+
+	// at the checkpoint, with a pad over two blocks, at a block boundary and the whole message
+	lengths := []uint64{64, 120, 128, 200}
+	digests := make([][]frontend.Variable, len(lengths))
+	for i, length := range lengths {
+		digest := sha256.Sum256(msg[:length])
+		digests[i] = variables(digest[:])
+	}
+	circuit := &prefixesCircuit{make([]frontend.Variable, 32), make([]frontend.Variable, 136), make([][]frontend.Variable, len(lengths)), 64, lengths}
+	for i := range circuit.Digests {
+		circuit.Digests[i] = make([]frontend.Variable, 32)
+	}
+	assignment := &prefixesCircuit{State: variables(state), Tail: variables(msg[64:]), Digests: digests}
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+	assignment.Tail[100] = 0
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("wrong tail accepted")
+	}
+}
+
+// sha256Constraints returns the number of constraints of SHA256 over n bytes
+func sha256Constraints(tb testing.TB, n int) int {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &sha256Circuit{make([]frontend.Variable, n), make([]frontend.Variable, 32)})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return ccs.GetNbConstraints()
+}
+
+func TestSHA256PadBlock(t *testing.T) {
+	// digests over a last block holding only the pad, at a block boundary and after 56 bytes
+	for _, n := range []int{0, 56, 64, 120, 128} {
+		msg := counting(0, n)
+		digest := sha256.Sum256(msg)
+		circuit := &sha256Circuit{make([]frontend.Variable, n), make([]frontend.Variable, 32)}
+		if err := test.IsSolved(circuit, &sha256Circuit{variables(msg), variables(digest[:])}, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+	}
+	// the precomputed message schedule makes a pad block cheaper than a block of the message
+	data, pad := sha256Constraints(t, 119)-sha256Constraints(t, 55), sha256Constraints(t, 56)-sha256Constraints(t, 55)
+	if pad >= data {
+		t.Fatalf("a pad block costs %d constraints, a data block %d", pad, data)
+	}
+}
+
+// BenchmarkSHA256Constraints reports the constraints of SHA256 over one block (55 bytes), one block and a
+// pad block (56 and 64 bytes), and two blocks (119 bytes)
+func BenchmarkSHA256Constraints(b *testing.B) {
+	for _, n := range []int{55, 56, 64, 119} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			var constraints int
+			for i := 0; i < b.N; i++ {
+				constraints = sha256Constraints(b, n)
+			}
+			b.ReportMetric(float64(constraints), "constraints")
+		})
+	}
+}
+
+func TestHMACSHA256(t *testing.T) {
+	key, msg := counting(1, 32), counting(100, 40)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(msg)
+	circuit := &hmacCircuit{make([]frontend.Variable, 32), make([]frontend.Variable, 40), make([]frontend.Variable, 32)}
+	if err := test.IsSolved(circuit, &hmacCircuit{variables(key), variables(msg), variables(mac.Sum(nil))}, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAESGCMSeal(t *testing.T) {
+	key, nonce, aad := counting(7, 16), counting(50, 12), []byte{23, 3, 3, 0, 53}
+	// a partial last block
+	plaintext := counting(200, 37)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	sealed := gcm.Seal(nil, nonce, plaintext, aad)
+	ct, tag := sealed[:len(plaintext)], sealed[len(plaintext):]
+
+	circuit := &gcmCircuit{
+		make([]frontend.Variable, 16), make([]frontend.Variable, 12), make([]frontend.Variable, len(plaintext)),
+		make([]frontend.Variable, len(aad)), make([]frontend.Variable, len(ct)), make([]frontend.Variable, 16),
+	}
+	assignment := &gcmCircuit{variables(key), variables(nonce), variables(plaintext), variables(aad), variables(ct), variables(tag)}
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+	tag[15] ^= 1
+	assignment.Tag = variables(tag)
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("wrong tag accepted")
+	}
+}
+
+// The AEAD test vector of RFC 8439, section 2.8.2
+func TestChaCha20Poly1305Seal(t *testing.T) {
+	key, nonce, aad := counting(0x80, 32), unhex("070000004041424344454647"), unhex("50515253c0c1c2c3c4c5c6c7")
+	plaintext := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+	ct := unhex("d31a8d34648e60db7b86afbc53ef7ec2a4aded51296e08fea9e2b5a736ee62d63dbea45e8ca9671282fafb69da92728b1a71de0a9e060b2905d6a5b67ecd3b3692ddbd7f2d778b8c9803aee328091b58fab324e4fad675945585808b4831d7bc3ff4def08e4b7a9de576d26586cec64b6116")
+	tag := unhex("1ae10b594f09e26a7e902ecbd0600691")
+
+	circuit := &chachaCircuit{
+		make([]frontend.Variable, 32), make([]frontend.Variable, 12), make([]frontend.Variable, len(plaintext)),
+		make([]frontend.Variable, len(aad)), make([]frontend.Variable, len(ct)), make([]frontend.Variable, 16),
+	}
+	assignment := &chachaCircuit{variables(key), variables(nonce), variables(plaintext), variables(aad), variables(ct), variables(tag)}
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+	tag[0] ^= 1
+	assignment.Tag = variables(tag)
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Fatal("wrong tag accepted")
+	}
+}
+
+// sealConstraints returns the number of constraints of AES-128-GCM and ChaCha20-Poly1305 over a TLS record
+// of n bytes of application data
+func sealConstraints(tb testing.TB, n int) (aesgcm, chacha int) {
+	for _, c := range []struct {
+		circuit frontend.Circuit
+		count   *int
+	}{
+		{&gcmCircuit{make([]frontend.Variable, 16), make([]frontend.Variable, 12), make([]frontend.Variable, n),
+			make([]frontend.Variable, 5), make([]frontend.Variable, n), make([]frontend.Variable, 16)}, &aesgcm},
+		{&chachaCircuit{make([]frontend.Variable, 32), make([]frontend.Variable, 12), make([]frontend.Variable, n),
+			make([]frontend.Variable, 5), make([]frontend.Variable, n), make([]frontend.Variable, 16)}, &chacha},
+	} {
+		ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, c.circuit)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		*c.count = ccs.GetNbConstraints()
+	}
+	return aesgcm, chacha
+}
+
+func TestChaCha20CheaperThanAES(t *testing.T) {
+	aesgcm, chacha := sealConstraints(t, 256)
+	t.Logf("a record of 256 bytes: %d constraints with AES-128-GCM, %d with ChaCha20-Poly1305", aesgcm, chacha)
+	if chacha >= aesgcm {
+		t.Fatalf("ChaCha20-Poly1305 costs %d constraints, AES-128-GCM %d", chacha, aesgcm)
+	}
+}
+
+// BenchmarkSealConstraints reports the constraints of both AEADs over records of 32 and 256 bytes
+func BenchmarkSealConstraints(b *testing.B) {
+	for _, n := range []int{32, 256} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			var aesgcm, chacha int
+			for i := 0; i < b.N; i++ {
+				aesgcm, chacha = sealConstraints(b, n)
+			}
+			b.ReportMetric(float64(aesgcm), "aesgcm-constraints")
+			b.ReportMetric(float64(chacha), "chacha-constraints")
+		})
+	}
+}
+
+func TestPoseidon(t *testing.T) {
+	// poseidon([1, 2]) of circomlibjs
+	want, _ := new(big.Int).SetString("115cc0f5e7d690413df64c6b9662e9cf2a3617f2743245519e19607a4417189a", 16)
+	if h := PoseidonNative(big.NewInt(1), big.NewInt(2)); h.Cmp(want) != 0 {
+		t.Fatalf("Poseidon(1, 2) = %x, want %x", h, want)
+	}
+	if err := test.IsSolved(&poseidonCircuit{}, &poseidonCircuit{1, 2, want}, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	// a partial last chunk for Poseidon, two blocks for SHA-256
+	b := counting(40, 70)
+	for _, scheme := range []Commitment{SHA256Commitment, PoseidonCommitment} {
+		commitment := CommitBytes(scheme, b)
+		values := make([]frontend.Variable, len(commitment))
+		for i := range commitment {
+			values[i] = commitment[i]
+		}
+		circuit := &commitCircuit{make([]frontend.Variable, len(b)), make([]frontend.Variable, scheme.Size()), scheme}
+		if err := test.IsSolved(circuit, &commitCircuit{variables(b), values, scheme}, ecc.BN254.ScalarField()); err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		b[69] ^= 1
+		if err := test.IsSolved(circuit, &commitCircuit{variables(b), values, scheme}, ecc.BN254.ScalarField()); err == nil {
+			t.Fatalf("%s: wrong bytes accepted", scheme)
+		}
+		b[69] ^= 1
+	}
+}
@@ -0,0 +1,166 @@
+package gadgets
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+// AESGCMSeal encrypts plaintext with AES-128-GCM under the 16-byte key and the 12-byte nonce, authenticating aad,
+// and returns the ciphertext and the 16-byte tag (NIST SP 800-38D)
+func AESGCMSeal(api frontend.API, key, nonce, plaintext, aad []frontend.Variable) (ciphertext, tag []frontend.Variable) {
+	if len(nonce) != 12 {
+		panic("gadgets: AES-GCM nonces are 12 bytes")
+	}
+	aes := NewAES128(api, key)
+	nonceBits := bytesBits(api, nonce)
+	ct := aes.xorKeyStream(nonceBits, 0, 0, bytesBits(api, plaintext))
+
+	zero := make([][]frontend.Variable, 16)
+	for i := range zero {
+		zero[i] = constBits(0)
+	}
+	h := aes.encryptBits(zero)
+
+	// GHASH of aad and the ciphertext, each padded with zeros to a whole number of blocks, then of their lengths in bits
+	blocks := append(padBlock(bytesBits(api, aad)), padBlock(ct)...)
+	lengths := make([][]frontend.Variable, 16)
+	for k := 0; k < 8; k++ {
+		lengths[7-k] = constBits(byte(uint64(len(aad)) * 8 >> (8 * k)))
+		lengths[15-k] = constBits(byte(uint64(len(ct)) * 8 >> (8 * k)))
+	}
+	s := ghash(api, h, append(blocks, lengths...))
+
+	mask := aes.encryptBits(gcmCounter(nonceBits, 1))
+	tagBits := make([][]frontend.Variable, 16)
+	for k := range tagBits {
+		tagBits[k] = xorBits(api, s[k], mask[k])
+	}
+	return bitsBytes(api, ct), bitsBytes(api, tagBits)
+}
+
+// XORKeyStream xors in with the AES-128-GCM keystream under the 12-byte nonce, from byte offset of the given block
+// of plaintext, whose counter is block + 2: as aesgcm.AES_GCM_decrypt_128bytes_middle, for records of any length
+func (a *AES128) XORKeyStream(nonce []frontend.Variable, block uint32, offset int, in []frontend.Variable) []frontend.Variable {
+	if len(nonce) != 12 {
+		panic("gadgets: AES-GCM nonces are 12 bytes")
+	}
+	return bitsBytes(a.api, a.xorKeyStream(bytesBits(a.api, nonce), block, offset, bytesBits(a.api, in)))
+}
+
+// xorKeyStream is XORKeyStream on bits, which only encrypts the counter blocks that in covers
+func (a *AES128) xorKeyStream(nonce [][]frontend.Variable, block uint32, offset int, in [][]frontend.Variable) [][]frontend.Variable {
+	if uint64(block)+uint64(offset+len(in)+15)/16+2 > 1<<32 {
+		panic("gadgets: the AES-GCM block counter wraps around")
+	}
+	out := make([][]frontend.Variable, len(in))
+	var keystream [][]frontend.Variable
+	for i := range in {
+		k := offset + i
+		if keystream == nil || k%16 == 0 {
+			keystream = a.encryptBits(gcmCounter(nonce, block+uint32(k/16)+2))
+		}
+		out[i] = xorBits(a.api, in[i], keystream[k%16])
+	}
+	return out
+}
+
+// gcmCounter returns the counter block i: the nonce followed by i as a 32-bit big-endian integer,
+// 1 for the tag and 2 for the first block of plaintext
+func gcmCounter(nonce [][]frontend.Variable, i uint32) [][]frontend.Variable {
+	block := append([][]frontend.Variable{}, nonce...)
+	for k := 3; k >= 0; k-- {
+		block = append(block, constBits(byte(i>>(8*k))))
+	}
+	return block
+}
+
+// TLSNonce returns the nonce of the TLS 1.3 record of sequence number seq: the 12-byte iv xored with seq,
+// a 64-bit integer padded on the left with zeros, as aesgcm.TLS_nonce (RFC 8446 section 5.3)
+func TLSNonce(api frontend.API, iv []frontend.Variable, seq frontend.Variable) []frontend.Variable {
+	if len(iv) != 12 {
+		panic("gadgets: TLS 1.3 ivs are 12 bytes")
+	}
+	seqBits := api.ToBinary(seq, 64)
+	nonce := append([]frontend.Variable{}, iv[:4]...)
+	for i := 4; i < 12; i++ {
+		k := 8 * (11 - i)
+		nonce = append(nonce, api.FromBinary(xorBits(api, byteBits(api, iv[i]), seqBits[k:k+8])...))
+	}
+	return nonce
+}
+
+// padBlock appends zero bytes to the bytes b up to a multiple of 16
+func padBlock(b [][]frontend.Variable) [][]frontend.Variable {
+	padded := append([][]frontend.Variable{}, b...)
+	for len(padded)%16 != 0 {
+		padded = append(padded, constBits(0))
+	}
+	return padded
+}
+
+// ghash returns GHASH_h of the bytes b, whose length is a multiple of 16
+func ghash(api frontend.API, h, b [][]frontend.Variable) [][]frontend.Variable {
+	hBits := gcmBits(h)
+	var y []frontend.Variable
+	for i := 0; i < len(b); i += 16 {
+		x := gcmBits(b[i : i+16])
+		if y != nil {
+			x = xorBits(api, y, x)
+		}
+		y = ghashMul(api, x, hBits)
+	}
+
+	s := make([][]frontend.Variable, 16)
+	for k := range s {
+		s[k] = make([]frontend.Variable, 8)
+		for i := range s[k] {
+			s[k][i] = y[8*k+7-i]
+		}
+	}
+	return s
+}
+
+// gcmBits returns the 128 bits of a block in the order of GCM: bit i is bit 7-i%8 of byte i/8,
+// so bit 0 is the most significant bit of the first byte and the coefficient of x^0
+func gcmBits(block [][]frontend.Variable) []frontend.Variable {
+	bits := make([]frontend.Variable, 128)
+	for i := range bits {
+		bits[i] = block[i/8][7-i%8]
+	}
+	return bits
+}
+
+// ghashMul returns x·h in GF(2^128) modulo x^128 + x^7 + x^2 + x + 1, NIST SP 800-38D algorithm 1.
+// Bit j of the product is the xor over i of x_i·v_i,j, with v_0 = h and v_i+1 = v_i·x.
+// It is computed as the parity of the sum of the products, which saves a constraint per xor.
+func ghashMul(api frontend.API, x, h []frontend.Variable) []frontend.Variable {
+	// the sums are accumulated one product at a time: with PLONK, a single addition of 128 terms
+	// recurses deeper than the stacks gnark's profiler records
+	sums := make([]frontend.Variable, 128)
+	for j := range sums {
+		sums[j] = 0
+	}
+	v := h
+	for i := 0; i < 128; i++ {
+		for j := range sums {
+			sums[j] = api.Add(sums[j], api.Mul(x[i], v[j]))
+		}
+		// v·x: shift towards the high degrees, and reduce the dropped bit by adding 11100001 || 0^120
+		lsb := v[127]
+		next := make([]frontend.Variable, 128)
+		next[0] = lsb
+		for j := 1; j < 128; j++ {
+			next[j] = v[j-1]
+		}
+		for _, j := range []int{1, 2, 7} {
+			next[j] = api.Xor(v[j-1], lsb)
+		}
+		v = next
+	}
+
+	z := make([]frontend.Variable, 128)
+	for j := range z {
+		// at most 128 products of bits, the sum fits in 8 bits
+		z[j] = api.ToBinary(sums[j], 8)[0]
+	}
+	return z
+}
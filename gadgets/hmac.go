@@ -0,0 +1,47 @@
+package gadgets
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+// HMACSHA256 returns the 32 bytes of HMAC-SHA256(key, msg), RFC 2104, for a key of at most 64 bytes
+func HMACSHA256(api frontend.API, key, msg []frontend.Variable) []frontend.Variable {
+	if len(key) > 64 {
+		panic("gadgets: HMAC keys longer than a SHA-256 block are not supported")
+	}
+	return bitsBytes(api, hmacSHA256(api, bytesBits(api, key), bytesBits(api, msg)))
+}
+
+// hmacSHA256 is HMACSHA256 on the bits of the bytes of key and msg
+func hmacSHA256(api frontend.API, key, msg [][]frontend.Variable) [][]frontend.Variable {
+	// the padded key xored with a constant costs no constraint
+	pad := func(c byte) [][]frontend.Variable {
+		padded := make([][]frontend.Variable, 64)
+		for i := range padded {
+			if i < len(key) {
+				padded[i] = xorConst(api, key[i], c)
+			} else {
+				padded[i] = constBits(c)
+			}
+		}
+		return padded
+	}
+
+	inner := digestBits(sha256Bits(api, append(pad(0x36), msg...)))
+	return digestBits(sha256Bits(api, append(pad(0x5c), inner...)))
+}
+
+// HKDFExpandLabel returns the length bytes of HKDF-Expand-Label(secret, label, context, length) of TLS 1.3,
+// RFC 8446 section 7.1, as the hkdf functions HKDF_expand_derive_secret, _tk and _iv: the label is prefixed
+// with "tls13 ", and length is at most 32, the first block of HKDF-Expand
+func HKDFExpandLabel(api frontend.API, secret []frontend.Variable, label string, context []frontend.Variable, length int) []frontend.Variable {
+	if length < 1 || length > 32 || len(label) > 249 || len(context) > 255 {
+		panic("gadgets: HKDF-Expand-Label of more than one block, or with a label or context too long")
+	}
+	info := []frontend.Variable{0, length, 6 + len(label)}
+	for _, c := range []byte("tls13 " + label) {
+		info = append(info, c)
+	}
+	info = append(append(append(info, len(context)), context...), 1)
+	return HMACSHA256(api, secret, info)[:length]
+}
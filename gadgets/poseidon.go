@@ -0,0 +1,174 @@
+package gadgets
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+// Poseidon (https://eprint.iacr.org/2019/458) with 2 inputs over the BN254 scalar field, with the parameters of
+// circomlib: a state of t = 3 elements, the x⁵ S-box, 8 full rounds and 57 partial rounds, so that
+// Poseidon(a, b) matches poseidon([a, b]) of circomlib and circomlibjs.
+// The round constants and the MDS matrix are generated as by the reference implementation,
+// with the Grain LFSR seeded by the parameters.
+
+const (
+	poseidonT        = 3
+	poseidonFull     = 8
+	poseidonPartial  = 57
+	poseidonConstant = (poseidonFull + poseidonPartial) * poseidonT
+)
+
+var (
+	poseidonOnce sync.Once
+	// poseidonC are the round constants, t per round, and poseidonM the MDS matrix
+	poseidonC []*big.Int
+	poseidonM [poseidonT][poseidonT]*big.Int
+)
+
+func poseidonParams() {
+	poseidonOnce.Do(func() {
+		p := ecc.BN254.ScalarField()
+		n := p.BitLen()
+		g := newGrain(n, poseidonT, poseidonFull, poseidonPartial)
+
+		poseidonC = make([]*big.Int, poseidonConstant)
+		for i := range poseidonC {
+			// rejection sampling, for uniform constants
+			c := g.int(n)
+			for c.Cmp(p) >= 0 {
+				c = g.int(n)
+			}
+			poseidonC[i] = c
+		}
+
+		// Cauchy matrix 1/(xᵢ + yⱼ)
+		var xy [2 * poseidonT]*big.Int
+		for i := range xy {
+			xy[i] = new(big.Int).Mod(g.int(n), p)
+		}
+		for i := 0; i < poseidonT; i++ {
+			for j := 0; j < poseidonT; j++ {
+				s := new(big.Int).Add(xy[i], xy[poseidonT+j])
+				poseidonM[i][j] = s.ModInverse(s.Mod(s, p), p)
+			}
+		}
+	})
+}
+
+// grain is the LFSR of the Poseidon reference implementation, in self-shrinking mode
+type grain struct{ s []byte }
+
+func newGrain(n, t, full, partial int) *grain {
+	var bits []byte
+	put := func(v, width int) {
+		for i := width - 1; i >= 0; i-- {
+			bits = append(bits, byte(v>>i&1))
+		}
+	}
+	put(1, 2) // prime field
+	put(0, 4) // x^α S-box
+	put(n, 12)
+	put(t, 12)
+	put(full, 10)
+	put(partial, 10)
+	for i := 0; i < 30; i++ {
+		bits = append(bits, 1)
+	}
+	g := &grain{bits}
+	for i := 0; i < 160; i++ {
+		g.step()
+	}
+	return g
+}
+
+func (g *grain) step() byte {
+	s := g.s
+	b := s[62] ^ s[51] ^ s[38] ^ s[23] ^ s[13] ^ s[0]
+	g.s = append(s[1:], b)
+	return b
+}
+
+// bit outputs the second bit of each pair whose first bit is 1
+func (g *grain) bit() byte {
+	for {
+		b, out := g.step(), g.step()
+		if b == 1 {
+			return out
+		}
+	}
+}
+
+// int returns the next n bits, most significant first
+func (g *grain) int(n int) *big.Int {
+	x := new(big.Int)
+	for i := 0; i < n; i++ {
+		x.Lsh(x, 1)
+		x.SetBit(x, 0, uint(g.bit()))
+	}
+	return x
+}
+
+// poseidonFullRound reports whether round r applies the S-box to the whole state, rather than to its first element
+func poseidonFullRound(r int) bool {
+	return r < poseidonFull/2 || r >= poseidonFull/2+poseidonPartial
+}
+
+// PoseidonNative returns Poseidon(a, b) over the BN254 scalar field, a and b being reduced modulo its order
+func PoseidonNative(a, b *big.Int) *big.Int {
+	poseidonParams()
+	p := ecc.BN254.ScalarField()
+	five := big.NewInt(5)
+
+	state := [poseidonT]*big.Int{new(big.Int), new(big.Int).Mod(a, p), new(big.Int).Mod(b, p)}
+	for r := 0; r < poseidonFull+poseidonPartial; r++ {
+		for i := range state {
+			state[i].Add(state[i], poseidonC[r*poseidonT+i]).Mod(state[i], p)
+			if i == 0 || poseidonFullRound(r) {
+				state[i].Exp(state[i], five, p)
+			}
+		}
+		var next [poseidonT]*big.Int
+		for i := range next {
+			next[i] = new(big.Int)
+			for j := range state {
+				next[i].Add(next[i], new(big.Int).Mul(poseidonM[i][j], state[j]))
+			}
+			next[i].Mod(next[i], p)
+		}
+		state = next
+	}
+	return state[0]
+}
+
+// Poseidon returns Poseidon(a, b) in a circuit over the BN254 scalar field, 3 constraints per S-box
+// with Groth16, 243 in all
+func Poseidon(api frontend.API, a, b frontend.Variable) frontend.Variable {
+	if api.Compiler().Field().Cmp(ecc.BN254.ScalarField()) != 0 {
+		panic("gadgets: Poseidon is only defined over the BN254 scalar field")
+	}
+	poseidonParams()
+
+	state := [poseidonT]frontend.Variable{0, a, b}
+	for r := 0; r < poseidonFull+poseidonPartial; r++ {
+		for i := range state {
+			state[i] = api.Add(state[i], poseidonC[r*poseidonT+i])
+			if i == 0 || poseidonFullRound(r) {
+				x2 := api.Mul(state[i], state[i])
+				state[i] = api.Mul(api.Mul(x2, x2), state[i])
+			}
+		}
+		var next [poseidonT]frontend.Variable
+		for i := range next {
+			terms := make([]frontend.Variable, poseidonT)
+			for j := range state {
+				terms[j] = api.Mul(poseidonM[i][j], state[j])
+			}
+			next[i] = sum(api, terms...)
+		}
+		state = next
+	}
+	return state[0]
+}
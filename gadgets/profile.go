@@ -0,0 +1,30 @@
+package gadgets
+
+import (
+	"reflect"
+	"runtime"
+)
+
+// Gadget names a function of this package whose constraints zk profile reports separately
+type Gadget struct {
+	Name string
+	// Function is the name of the function in the stack traces recorded by gnark's profiler
+	Function string
+}
+
+// Profiled lists the gadgets in the order zk profile reports them.
+// A constraint counts for every gadget in its stack: those of the SHA-256 compressions of an HMAC count for both.
+var Profiled = []Gadget{
+	{"SHA-256 compression", funcName(sha256Compress)},
+	{"SHA-256 pad compression", funcName(sha256CompressPad)},
+	{"HMAC-SHA256", funcName(hmacSHA256)},
+	{"AES key expansion", funcName(aesExpandKey)},
+	{"AES round", funcName(aesRound)},
+	{"GHASH", funcName(ghash)},
+	{"ChaCha20 block", funcName(chacha20Block)},
+	{"Poly1305", funcName(poly1305)},
+}
+
+func funcName(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
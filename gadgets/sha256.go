@@ -0,0 +1,231 @@
+package gadgets
+
+import (
+	"fmt"
+	"math/bits"
+
+	"anonpao/sha2"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// word is a 32-bit word of SHA-256 as its bits, least significant first
+type word [32]frontend.Variable
+
+// wordFromBytes packs the bits of 4 bytes into a word, big-endian as in SHA-256
+func wordFromBytes(b [][]frontend.Variable) (w word) {
+	for k := 0; k < 4; k++ {
+		copy(w[8*(3-k):], b[k])
+	}
+	return w
+}
+
+// bytes is the inverse of wordFromBytes
+func (w word) bytes() [][]frontend.Variable {
+	b := make([][]frontend.Variable, 4)
+	for k := range b {
+		b[k] = w[8*(3-k) : 8*(4-k)]
+	}
+	return b
+}
+
+func constWord(x uint32) (w word) {
+	for i := range w {
+		w[i] = x >> i & 1
+	}
+	return w
+}
+
+func (w word) value(api frontend.API) frontend.Variable {
+	return api.FromBinary(w[:]...)
+}
+
+func (w word) rotr(n int) (r word) {
+	for i := range r {
+		r[i] = w[(i+n)%32]
+	}
+	return r
+}
+
+func (w word) shr(n int) (r word) {
+	for i := range r {
+		if i+n < 32 {
+			r[i] = w[i+n]
+		} else {
+			r[i] = 0
+		}
+	}
+	return r
+}
+
+func xor3(api frontend.API, a, b, c word) (r word) {
+	for i := range r {
+		r[i] = api.Xor(api.Xor(a[i], b[i]), c[i])
+	}
+	return r
+}
+
+// ch is (e and f) xor (not e and g), i.e. f where e is set and g elsewhere
+func ch(api frontend.API, e, f, g word) (r word) {
+	for i := range r {
+		r[i] = api.Select(e[i], f[i], g[i])
+	}
+	return r
+}
+
+// maj is the majority of a, b and c, computed as ab + c(a + b - 2ab)
+func maj(api frontend.API, a, b, c word) (r word) {
+	for i := range r {
+		ab := api.Mul(a[i], b[i])
+		r[i] = api.Add(ab, api.Mul(c[i], api.Sub(api.Add(a[i], b[i]), api.Mul(ab, 2))))
+	}
+	return r
+}
+
+// add32 returns the sum modulo 2^32 of the values of words and constants in vs,
+// by decomposing the sum on just enough bits to hold it and dropping the carries
+func add32(api frontend.API, vs ...frontend.Variable) (w word) {
+	carries := bits.Len(uint(len(vs) - 1))
+	copy(w[:], api.ToBinary(sum(api, vs...), 32+carries))
+	return w
+}
+
+// sha256Compress is the SHA-256 compression function: it returns the state after the 64-byte block,
+// given as the bits of its bytes
+func sha256Compress(api frontend.API, state [8]word, block [][]frontend.Variable) [8]word {
+	var w [64]word
+	for t := 0; t < 16; t++ {
+		w[t] = wordFromBytes(block[4*t : 4*t+4])
+	}
+	for t := 16; t < 64; t++ {
+		s0 := xor3(api, w[t-15].rotr(7), w[t-15].rotr(18), w[t-15].shr(3))
+		s1 := xor3(api, w[t-2].rotr(17), w[t-2].rotr(19), w[t-2].shr(10))
+		w[t] = add32(api, s1.value(api), w[t-7].value(api), s0.value(api), w[t-16].value(api))
+	}
+	return sha256Rounds(api, state, w)
+}
+
+// sha256CompressPad is sha256Compress on the last block of the padded message of length bytes, when that
+// block only holds the pad: its message schedule is a constant, precomputed by sha2.Pad_schedule
+func sha256CompressPad(api frontend.API, state [8]word, length uint64) [8]word {
+	_, words, ok := sha2.Pad_schedule(length)
+	if !ok {
+		panic(fmt.Sprintf("the last block of %d bytes holds bytes of the message", length))
+	}
+	var w [64]word
+	for t := range w {
+		w[t] = constWord(words[t])
+	}
+	return sha256Rounds(api, state, w)
+}
+
+// sha256Rounds returns the state after the 64 rounds of the compression function over the message schedule w
+func sha256Rounds(api frontend.API, state [8]word, w [64]word) [8]word {
+	a, b, c, d, e, f, g, h := state[0], state[1], state[2], state[3], state[4], state[5], state[6], state[7]
+	for t := 0; t < 64; t++ {
+		s1 := xor3(api, e.rotr(6), e.rotr(11), e.rotr(25))
+		t1 := []frontend.Variable{h.value(api), s1.value(api), ch(api, e, f, g).value(api), sha2.K_CONST[t], w[t].value(api)}
+		s0 := xor3(api, a.rotr(2), a.rotr(13), a.rotr(22))
+		t2 := []frontend.Variable{s0.value(api), maj(api, a, b, c).value(api)}
+
+		h, g, f = g, f, e
+		e = add32(api, append(t1, d.value(api))...)
+		d, c, b = c, b, a
+		a = add32(api, append(t1, t2...)...)
+	}
+
+	next := [8]word{a, b, c, d, e, f, g, h}
+	for i := range next {
+		next[i] = add32(api, state[i].value(api), next[i].value(api))
+	}
+	return next
+}
+
+// sha256Bits returns the state after hashing the message whose bytes have the bits msg
+func sha256Bits(api frontend.API, msg [][]frontend.Variable) [8]word {
+	padded := append(append([][]frontend.Variable{}, msg...), padBits(uint64(len(msg)))...)
+
+	var state [8]word
+	for i := range state {
+		state[i] = constWord(sha2.H_CONST[i])
+	}
+	return sha256Blocks(api, state, padded, uint64(len(msg)))
+}
+
+// sha256Blocks returns the state after the blocks of padded, the end of a padded message of length bytes,
+// compressing a last block holding only the pad with its precomputed message schedule
+func sha256Blocks(api frontend.API, state [8]word, padded [][]frontend.Variable, length uint64) [8]word {
+	blocks := len(padded) / 64
+	_, _, padOnly := sha2.Pad_schedule(length)
+	if padOnly {
+		blocks--
+	}
+	for i := 0; i < blocks; i++ {
+		state = sha256Compress(api, state, padded[64*i:64*i+64])
+	}
+	if padOnly {
+		state = sha256CompressPad(api, state, length)
+	}
+	return state
+}
+
+// padBits returns the bits of the pad of a message of length bytes: 0x80, zeros up to 56 bytes modulo 64,
+// then the length in bits as a 64-bit big-endian integer
+func padBits(length uint64) [][]frontend.Variable {
+	pad := [][]frontend.Variable{constBits(0x80)}
+	for (length+uint64(len(pad)))%64 != 56 {
+		pad = append(pad, constBits(0))
+	}
+	for k := 7; k >= 0; k-- {
+		pad = append(pad, constBits(byte(length*8>>(8*k))))
+	}
+	return pad
+}
+
+// digestBits returns the bits of the bytes of the digest in the final state
+func digestBits(state [8]word) [][]frontend.Variable {
+	digest := make([][]frontend.Variable, 0, 32)
+	for _, w := range state {
+		digest = append(digest, w.bytes()...)
+	}
+	return digest
+}
+
+// SHA256 returns the 32 bytes of the SHA-256 digest of msg
+func SHA256(api frontend.API, msg []frontend.Variable) []frontend.Variable {
+	return bitsBytes(api, digestBits(sha256Bits(api, bytesBits(api, msg))))
+}
+
+// SHA256Prefixes returns the SHA-256 digests of prefixes of a message, resuming from state, the 32 bytes of
+// the H-state after its first stateLength bytes (sha2.Checkpoint, big endian), which must be whole blocks.
+// tail holds the bytes that follow, and lengths the lengths of the prefixes, counted from the start of the message.
+// The whole blocks of the tail are compressed once for all prefixes, each of which then only adds the one
+// or two blocks holding the end of its bytes and its pad, as sha2.SumPrefixes does natively.
+func SHA256Prefixes(api frontend.API, state []frontend.Variable, stateLength uint64, tail []frontend.Variable, lengths []uint64) [][]frontend.Variable {
+	if stateLength%64 != 0 {
+		panic(fmt.Sprintf("SHA-256 state after %d bytes, which is not a block boundary", stateLength))
+	}
+	bits := bytesBits(api, tail)
+
+	// states[k] is the state after the first k blocks of the tail, compressed once the first prefix needs it
+	var initial [8]word
+	stateBits := bytesBits(api, state)
+	for i := range initial {
+		initial[i] = wordFromBytes(stateBits[4*i : 4*i+4])
+	}
+	states := [][8]word{initial}
+
+	digests := make([][]frontend.Variable, len(lengths))
+	for i, length := range lengths {
+		if length < stateLength || length-stateLength > uint64(len(tail)) {
+			panic(fmt.Sprintf("prefix of %d bytes, outside the %d bytes after the state", length, len(tail)))
+		}
+		n := int(length - stateLength)
+		for k := len(states); k <= n/64; k++ {
+			states = append(states, sha256Compress(api, states[k-1], bits[64*(k-1):64*k]))
+		}
+		last := append(append([][]frontend.Variable{}, bits[n-n%64:n]...), padBits(length)...)
+		digests[i] = bitsBytes(api, digestBits(sha256Blocks(api, states[n/64], last, length)))
+	}
+	return digests
+}
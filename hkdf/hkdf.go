@@ -13,6 +13,7 @@ import (
 // The last two call HMAC after processing their inputs.
 // Furthermore, TLS 1.3 uses Expand in particular ways depending on what the desired output is (a secret, key or iv)
 // It also pre-processes the inputs in specific ways, such as prepending the string "tls13 " to the label
+// The hashes use crypto/sha256 rather than the sha2 module, so sha2.Pad_schedule does not apply here.
 
 // Fixed bytes used in the HMAC function
 
@@ -24,10 +25,15 @@ const OPAD = 0x5c
 // where ipad and opad are fixed bytes (0x36 and 0x5c respective)
 
 func HMAC(key, salt []byte) []byte {
-	// the key is padded to 512 bits when using SHA256
+	// keys longer than a block are hashed first (RFC 2104, section 2)
+	if len(key) > 64 {
+		hashed := sha256.Sum256(key)
+		key = hashed[:]
+	}
+
+	// the key is padded to 512 bits when using SHA256, in a copy so as not to write past the caller's slice
 	if len(key) < 64 {
-		key_pad := make([]byte, 64-len(key))
-		key = append(key, key_pad...)
+		key = append(append(make([]byte, 0, 64), key...), make([]byte, 64-len(key))...)
 	}
 
 	// We xor every byte of the key with ipad and opad to generate the following two strings
@@ -58,10 +64,11 @@ func HKDF_extract(salt, key []byte) []byte {
 	return HMAC(salt, key)
 }
 
-// One iteration of HKDF expand, the one_byte being appending to the 'info' input
+// One iteration of HKDF expand, the one_byte being appending to the 'info' input,
+// in a copy so as not to write to the spare capacity of the caller's slice
 func hkdf_expand(prk, info []byte) []byte {
 	one_byte := []byte{0x01}
-	label := append(info, one_byte...)
+	label := append(append(make([]byte, 0, len(info)+1), info...), one_byte...)
 	return HMAC(prk, label)
 }
 
@@ -0,0 +1,159 @@
+package hkdf
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// The HMAC-SHA256 test cases of RFC 4231, the fifth being truncated to 128 bits
+func TestRFC4231(t *testing.T) {
+	for i, v := range []struct {
+		key  []byte
+		data string
+		mac  string
+	}{
+		{bytes.Repeat([]byte{0x0b}, 20), "Hi There", "b0344c61d8db38535ca8afceaf0bf12b881dc200c9833da726e9376c2e32cff7"},
+		{[]byte("Jefe"), "what do ya want for nothing?", "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{bytes.Repeat([]byte{0xaa}, 20), strings.Repeat("\xdd", 50), "773ea91e36800e46854db8ebd09181a72959098b3ef8c122d9635514ced565fe"},
+		{unhex("0102030405060708090a0b0c0d0e0f10111213141516171819"), strings.Repeat("\xcd", 50),
+			"82558a389a443c0ea4cc819899f2083a85f0faa3e578f8077a2e3ff46729665b"},
+		{bytes.Repeat([]byte{0x0c}, 20), "Test With Truncation", "a3b6167473100ee06e0c796c2955552b"},
+		{bytes.Repeat([]byte{0xaa}, 131), "Test Using Larger Than Block-Size Key - Hash Key First",
+			"60e431591ee0b67f0d8a26aacbf5b77f8e0bc6213728c5140546040f0ee37f54"},
+		{bytes.Repeat([]byte{0xaa}, 131), "This is a test using a larger than block-size key and a larger than block-size data. " +
+			"The key needs to be hashed before being used by the HMAC algorithm.",
+			"9b09ffa71b942fcb27635fbcd5b0e944bfdc63644f0713938a7f51535c3a35e2"},
+	} {
+		if got := hex.EncodeToString(HMAC(v.key, []byte(v.data))); got[:len(v.mac)] != v.mac {
+			t.Errorf("test case %d: got %s, want %s", i+1, got, v.mac)
+		}
+	}
+}
+
+// The SHA-256 test cases of RFC 5869: expand is one iteration, the first 32 bytes of the OKM
+func TestRFC5869(t *testing.T) {
+	for i, v := range []struct{ ikm, salt, info, prk, okm string }{
+		{"0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b", "000102030405060708090a0b0c", "f0f1f2f3f4f5f6f7f8f9",
+			"077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5",
+			"3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf"},
+		{"0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b", "", "",
+			"19ef24a32c717b167f33a91d6f648bdf96596776afdb6377ac434c1c293ccb04",
+			"8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d"},
+	} {
+		prk := HKDF_extract(unhex(v.salt), unhex(v.ikm))
+		if hex.EncodeToString(prk) != v.prk {
+			t.Errorf("test case %d: PRK %x, want %s", i+1, prk, v.prk)
+		}
+		if okm := hkdf_expand(prk, unhex(v.info)); hex.EncodeToString(okm) != v.okm {
+			t.Errorf("test case %d: OKM %x, want %s", i+1, okm, v.okm)
+		}
+	}
+}
+
+// The key schedule of the simple 1-RTT handshake of RFC 8448, section 3
+func TestRFC8448(t *testing.T) {
+	check := func(name string, got []byte, want string) {
+		if hex.EncodeToString(got) != want {
+			t.Errorf("%s: got %x, want %s", name, got, want)
+		}
+	}
+	empty := sha256.Sum256(nil)
+
+	early := HKDF_extract(make([]byte, 32), make([]byte, 32))
+	check("early secret", early, "33ad0a1c607ec03b09e6cd9893680ce210adf300aa1f2660e1b22e10f170f92a")
+	derived := HKDF_expand_derive_secret(early, "derived", empty[:])
+	check("derived", derived, "6f2615a108c702c5678f54fc9dbab69716c076189c48250cebeac3576c3611ba")
+	hs := HKDF_extract(derived, unhex("8bd4054fb55b9d63fdfbacf9f04b9f0d35e6d63f537563efd46272900f89492d"))
+	check("handshake secret", hs, "1dc826e93606aa6fdc0aadc12f741b01046aa6b99f691ed221a9f0ca043fbeac")
+
+	h2 := unhex("860c06edc07858ee8e78f0e7428c58edd6b43f2ca3e6e95f02ed063cf0e1cad8")
+	shts := HKDF_expand_derive_secret(hs, "s hs traffic", h2)
+	check("server handshake traffic secret", shts, "b67b7d690cc16c4e75e54213cb2d37b4e9c912bcded9105d42befd59d391ad38")
+	check("server handshake key", HKDF_expand_derive_tk(shts, 16), "3fce516009c21727d0f2e4e86ee403bc")
+	check("server handshake iv", HKDF_expand_derive_iv(shts, 12), "5d313eb2671276ee13000b30")
+	chts := HKDF_expand_derive_secret(hs, "c hs traffic", h2)
+	check("client handshake traffic secret", chts, "b3eddb126e067f35a780b3abf45e2d8f3b1a950738f52e9600746a0e27a55a21")
+	check("client handshake key", HKDF_expand_derive_tk(chts, 16), "dbfaa693d1762c5b666af5d950258d01")
+	check("client handshake iv", HKDF_expand_derive_iv(chts, 12), "5bd3c71b836e0b76bb73265f")
+
+	dhs := HKDF_expand_derive_secret(hs, "derived", empty[:])
+	check("derived", dhs, "43de77e0c77713859a944db9db2590b53190a65b3ee2e4f12dd7a0bb7ce254b4")
+	ms := HKDF_extract(dhs, make([]byte, 32))
+	check("master secret", ms, "18df06843d13a08bf2a449844c5f8a478001bc4d4c627984d5a41da8d0402919")
+}
+
+func stdHMAC(key, msg []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
+
+func FuzzHMAC(f *testing.F) {
+	f.Add([]byte("Jefe"), []byte("what do ya want for nothing?"))
+	f.Add(bytes.Repeat([]byte{0xaa}, 131), []byte("Test Using Larger Than Block-Size Key - Hash Key First"))
+	f.Add(make([]byte, 64), []byte{})
+	f.Fuzz(func(t *testing.T, key, msg []byte) {
+		// spare capacity after the key, which HMAC must not write to
+		buffer := append(append([]byte{}, key...), bytes.Repeat([]byte{0x42}, 64)...)
+		key = buffer[:len(key)]
+
+		if got, want := HMAC(key, msg), stdHMAC(key, msg); !bytes.Equal(got, want) {
+			t.Fatalf("got %x, crypto/hmac gives %x", got, want)
+		}
+		if !bytes.Equal(buffer[len(key):], bytes.Repeat([]byte{0x42}, 64)) {
+			t.Fatal("HMAC wrote past the key")
+		}
+		if got, want := HKDF_extract(key, msg), stdHMAC(key, msg); !bytes.Equal(got, want) {
+			t.Fatalf("HKDF_extract: got %x, want %x", got, want)
+		}
+	})
+}
+
+func FuzzHKDFExpand(f *testing.F) {
+	f.Add(make([]byte, 32), "s hs traffic", make([]byte, 32), uint8(32))
+	f.Add([]byte("secret"), "key", []byte{}, uint8(16))
+	f.Fuzz(func(t *testing.T, secret []byte, label string, context []byte, length uint8) {
+		if len(label) > 249 || len(context) > 255 || length == 0 || length > 32 {
+			return
+		}
+		// HkdfLabel of RFC 8446 section 7.1, then the single block of HKDF-Expand
+		info := []byte{0, length, byte(6 + len(label))}
+		info = append(info, "tls13 "+label...)
+		info = append(info, byte(len(context)))
+		info = append(info, context...)
+		want := stdHMAC(secret, append(append([]byte{}, info...), 1))
+
+		// spare capacity after info, which hkdf_expand must not write to
+		buffer := append(append([]byte{}, info...), 0x42)
+		info = buffer[:len(info)]
+		if got := hkdf_expand(secret, info); !bytes.Equal(got, want) {
+			t.Fatalf("hkdf_expand: got %x, want %x", got, want)
+		}
+		if buffer[len(info)] != 0x42 {
+			t.Fatal("hkdf_expand wrote past info")
+		}
+		if got := HKDF_expand_derive_tk(secret, int(length)); !bytes.Equal(got, want[:length]) && label == "key" && len(context) == 0 {
+			t.Fatalf("HKDF_expand_derive_tk: got %x, want %x", got, want[:length])
+		}
+		if got := HKDF_expand_derive_iv(secret, int(length)); !bytes.Equal(got, want[:length]) && label == "iv" && len(context) == 0 {
+			t.Fatalf("HKDF_expand_derive_iv: got %x, want %x", got, want[:length])
+		}
+		if length == 32 {
+			if got := HKDF_expand_derive_secret(secret, label, context); !bytes.Equal(got, want) {
+				t.Fatalf("HKDF_expand_derive_secret: got %x, want %x", got, want)
+			}
+		}
+	})
+}
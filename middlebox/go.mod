@@ -0,0 +1,17 @@
+module anonpao/middlebox
+
+go 1.19
+
+require github.com/consensys/gnark v0.9.1
+
+require (
+	github.com/blang/semver/v4 v4.0.0 // indirect
+	github.com/consensys/bavard v0.1.13 // indirect
+	github.com/consensys/gnark-crypto v0.12.2-0.20231013160410-1f65e75b6dfb // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	github.com/mmcloughlin/addchain v0.4.0 // indirect
+	github.com/rs/zerolog v1.30.0 // indirect
+	golang.org/x/sys v0.11.0 // indirect
+	rsc.io/tmplfunc v0.0.3 // indirect
+)
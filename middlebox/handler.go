@@ -0,0 +1,54 @@
+package middlebox
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ProofRequest is the body of the requests served by ProofHandler
+type ProofRequest struct {
+	// ClientRandom is the hex encoded random of the ClientHello of the connection
+	ClientRandom string `json:"client_random"`
+	// Proof is the proof of the next application data record of the connection,
+	// in the format written by the zk command (encoded in base64 in JSON)
+	Proof []byte `json:"proof"`
+}
+
+// maxProofSize bounds the request bodies, proofs are a few kilobytes
+const maxProofSize = 1 << 20
+
+// ProofHandler returns the HTTP handler through which clients submit their proofs:
+// POST a ProofRequest, the response is 200 once the record is forwarded, 403 if the proof is rejected,
+// the record being still held, and 404 if the connection is unknown or has no record waiting.
+func (p *Proxy) ProofHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST with a proof request", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req ProofRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxProofSize)).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		random, err := hex.DecodeString(req.ClientRandom)
+		if err != nil {
+			http.Error(w, "client_random: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch err := p.Submit(random, req.Proof); {
+		case err == nil:
+			w.WriteHeader(http.StatusOK)
+		case errors.Is(err, ErrUnknownSession), errors.Is(err, ErrNotWaiting):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		}
+	})
+}
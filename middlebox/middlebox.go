@@ -0,0 +1,269 @@
+// Package middlebox is a TCP proxy that forwards the TLS traffic of a client to a server (e.g. a DoH resolver)
+// only if the client proves, in zero knowledge, that its encrypted requests comply with a policy.
+//
+// The proxy relays the handshake untouched and records the public part of the transcript:
+// the ClientHello and ServerHello, and the encrypted records of the server handshake.
+// The first encrypted record of the client is its Finished message and is relayed as well;
+// every later client record holds application data and is kept by the proxy until the client
+// submits, out of band, a proof about it (see Proxy.Submit and Proxy.ProofHandler).
+// The proof is checked against a Statement made of the transcript, the held ciphertext, its sequence number and the policy root,
+// and the record is forwarded only if the proof verifies. No valid proof within ProofTimeout closes the
+// connection without forwarding anything more.
+//
+// Connections are identified by the random of their ClientHello, which the client knows and the proxy sees in clear,
+// and so does anyone on-path: submissions are not authenticated. What binds a proof to the client is its statement,
+// the ciphertext of the connection, which only a party that knows the traffic secrets can prove anything about.
+// An invalid proof is therefore reported to its submitter and otherwise ignored, the record staying held for the
+// next submission until ProofTimeout, so that a third party cannot close the connection by submitting garbage first.
+// Handshakes with a HelloRetryRequest are not supported.
+package middlebox
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"anonpao/circuits"
+)
+
+// Statement is what a proof is checked against: the public values the proxy observed on a connection,
+// with the sequence number of the held record among the application data records of the client
+type Statement = circuits.Statement
+
+// Verifier checks a proof that the traffic of a statement complies with the policy, see SnarkVerifier
+type Verifier interface {
+	Verify(st Statement, proof []byte) error
+}
+
+// DefaultProofTimeout is the time a client has to submit the proof of an application data record
+const DefaultProofTimeout = 10 * time.Second
+
+// Proxy relays the connections it accepts to Upstream, see the package documentation
+type Proxy struct {
+	Upstream   string // address of the server, dialled over TCP
+	Verifier   Verifier
+	PolicyRoot []byte
+
+	// ProofTimeout bounds the time a record is held waiting for its proof, DefaultProofTimeout if zero
+	ProofTimeout time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// session is the state of one proxied connection
+type session struct {
+	proofs chan submission
+
+	mu              sync.Mutex
+	clientHello     []byte
+	serverHello     []byte
+	serverHandshake [][]byte
+	clientFinished  bool
+	// sequence is the number of application data records of the client forwarded so far
+	sequence uint64
+}
+
+// submission is a proof sent by the client, and where to report whether it verified
+type submission struct {
+	proof  []byte
+	result chan error
+}
+
+var (
+	// ErrUnknownSession is returned by Submit for a client random that matches no open connection
+	ErrUnknownSession = errors.New("no connection with this client random")
+	// ErrNotWaiting is returned by Submit when the connection has no record waiting for a proof
+	ErrNotWaiting = errors.New("no record is waiting for a proof")
+)
+
+// Serve accepts connections on l and relays each of them to Upstream, until l is closed
+func (p *Proxy) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := p.handle(conn); err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Println("middlebox:", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Submit hands the proof of the record held on the connection identified by clientRandom to the proxy,
+// and returns once the proof was checked: nil if the record is forwarded, the verification error otherwise,
+// the record then staying held for another submission. It waits up to ProofTimeout for the record to reach the proxy.
+func (p *Proxy) Submit(clientRandom []byte, proof []byte) error {
+	p.mu.Lock()
+	s := p.sessions[hex.EncodeToString(clientRandom)]
+	p.mu.Unlock()
+	if s == nil {
+		return ErrUnknownSession
+	}
+
+	sub := submission{proof, make(chan error, 1)}
+	select {
+	case s.proofs <- sub:
+	case <-time.After(p.proofTimeout()):
+		return ErrNotWaiting
+	}
+	return <-sub.result
+}
+
+func (p *Proxy) proofTimeout() time.Duration {
+	if p.ProofTimeout == 0 {
+		return DefaultProofTimeout
+	}
+	return p.ProofTimeout
+}
+
+func (p *Proxy) handle(client net.Conn) error {
+	defer client.Close()
+
+	// the connection is only registered once its ClientHello identifies it
+	record, err := readRecord(client)
+	if err != nil {
+		return err
+	}
+	if record[0] != recordHandshake {
+		return errors.New("connection does not start with a ClientHello")
+	}
+	random, err := clientRandom(record[recordHeaderLen:])
+	if err != nil {
+		return err
+	}
+
+	server, err := net.Dial("tcp", p.Upstream)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	if _, err := server.Write(record); err != nil {
+		return err
+	}
+
+	s := &session{proofs: make(chan submission), clientHello: record[recordHeaderLen:]}
+	id := hex.EncodeToString(random)
+	p.mu.Lock()
+	if p.sessions == nil {
+		p.sessions = make(map[string]*session)
+	}
+	if p.sessions[id] != nil {
+		p.mu.Unlock()
+		return fmt.Errorf("client random %s is already in use", id)
+	}
+	p.sessions[id] = s
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.sessions, id)
+		p.mu.Unlock()
+	}()
+
+	// closing both connections when either direction ends stops the other one
+	errs := make(chan error, 2)
+	go func() { errs <- p.relayServer(s, server, client) }()
+	go func() { errs <- p.relayClient(s, client, server) }()
+	err = <-errs
+	client.Close()
+	server.Close()
+	<-errs
+	return err
+}
+
+// relayServer copies the records of the server to the client, recording the server handshake
+func (p *Proxy) relayServer(s *session, server, client net.Conn) error {
+	for {
+		record, err := readRecord(server)
+		if err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		switch {
+		case record[0] == recordHandshake && s.serverHello == nil:
+			if record[recordHeaderLen] != handshakeServerHello {
+				s.mu.Unlock()
+				return errors.New("expected a ServerHello")
+			}
+			s.serverHello = record[recordHeaderLen:]
+		case record[0] == recordApplicationData && !s.clientFinished:
+			s.serverHandshake = append(s.serverHandshake, record)
+		}
+		s.mu.Unlock()
+
+		if _, err := client.Write(record); err != nil {
+			return err
+		}
+	}
+}
+
+// relayClient copies the records of the client to the server, holding each application data record until its proof verifies
+func (p *Proxy) relayClient(s *session, client, server net.Conn) error {
+	for {
+		record, err := readRecord(client)
+		if err != nil {
+			return err
+		}
+
+		if record[0] == recordApplicationData {
+			s.mu.Lock()
+			finished := s.clientFinished
+			s.clientFinished = true
+			s.mu.Unlock()
+
+			// the first encrypted record of the client is its Finished message
+			if finished {
+				if err := p.check(s, record); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := server.Write(record); err != nil {
+			return err
+		}
+	}
+}
+
+// check waits for a valid proof of an application data record. Invalid proofs are rejected to their submitter
+// without ending the wait, only the timeout does.
+func (p *Proxy) check(s *session, record []byte) error {
+	s.mu.Lock()
+	st := Statement{
+		ClientHello:     s.clientHello,
+		ServerHello:     s.serverHello,
+		ServerHandshake: s.serverHandshake,
+		Ciphertext:      record,
+		Sequence:        s.sequence,
+		PolicyRoot:      p.PolicyRoot,
+	}
+	s.mu.Unlock()
+
+	timeout := time.After(p.proofTimeout())
+	for {
+		var sub submission
+		select {
+		case sub = <-s.proofs:
+		case <-timeout:
+			return errors.New("no valid proof for the application data before the timeout")
+		}
+
+		err := p.Verifier.Verify(st, sub.proof)
+		if err == nil {
+			s.mu.Lock()
+			s.sequence++
+			s.mu.Unlock()
+			sub.result <- nil
+			return nil
+		}
+		sub.result <- fmt.Errorf("proof rejected: %w", err)
+	}
+}
@@ -0,0 +1,413 @@
+package middlebox
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"anonpao/circuits"
+	"anonpao/snark"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/frontend"
+)
+
+// digestCircuit stands in for the policy circuit: its only public input is a digest of the statement,
+// so that a proof only verifies against the exact traffic it was made for
+type digestCircuit struct {
+	Digest frontend.Variable `gnark:",public"`
+	Secret frontend.Variable
+}
+
+func (c *digestCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.Secret, c.Digest)
+	return nil
+}
+
+func (c *digestCircuit) AssignPublic(st Statement) error {
+	c.Digest = digest(st)
+	return nil
+}
+
+// digest hashes the length-prefixed fields of st, truncated to fit in the scalar field
+func digest(st Statement) *big.Int {
+	h := sha256.New()
+	for _, field := range append([][]byte{st.ClientHello, st.ServerHello, st.Ciphertext, st.PolicyRoot}, st.ServerHandshake...) {
+		binary.Write(h, binary.BigEndian, uint32(len(field)))
+		h.Write(field)
+	}
+	binary.Write(h, binary.BigEndian, st.Sequence)
+	return new(big.Int).SetBytes(h.Sum(nil)[:31])
+}
+
+var digestDefinition = circuits.Definition{
+	Name: "digest",
+	New:  func() frontend.Circuit { return new(digestCircuit) },
+}
+
+// fixture is the setup of digestCircuit, shared by the tests
+type fixture struct {
+	verifier *SnarkVerifier
+	prove    func(st Statement) []byte
+}
+
+var (
+	setupOnce sync.Once
+	setup     fixture
+)
+
+func newFixture(t *testing.T) fixture {
+	setupOnce.Do(func() {
+		ccs, err := snark.Compile(digestDefinition, backend.GROTH16, ecc.BN254)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := snark.NewHeader(digestDefinition, backend.GROTH16, ecc.BN254, ccs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pk, vk, err := snark.Setup(backend.GROTH16, ccs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.KeyHash, err = snark.KeyHash(vk); err != nil {
+			t.Fatal(err)
+		}
+		verifier, err := NewSnarkVerifier(digestDefinition, backend.GROTH16, vk, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		setup = fixture{verifier, func(st Statement) []byte {
+			d := digest(st)
+			w, err := frontend.NewWitness(&digestCircuit{Digest: d, Secret: d}, ecc.BN254.ScalarField())
+			if err != nil {
+				t.Fatal(err)
+			}
+			proof, err := snark.Prove(backend.GROTH16, ccs, pk, w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := snark.EncodeProof(&buf, h, proof); err != nil {
+				t.Fatal(err)
+			}
+			return buf.Bytes()
+		}}
+	})
+	if setup.verifier == nil {
+		t.Fatal("setup failed")
+	}
+	return setup
+}
+
+// startServer runs an in-process TLS 1.3 server standing in for the DoH resolver.
+// It answers every request of a connection with "ok " followed by the request, and sends the requests it received on the returned channel.
+func startServer(t *testing.T) (addr string, roots *x509.CertPool, received <-chan []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = x509.NewCertPool()
+	roots.AddCert(cert)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS13,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	requests := make(chan []byte, 16)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					buf := make([]byte, 1024)
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					requests <- buf[:n]
+					conn.Write(append([]byte("ok "), buf[:n]...))
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), roots, requests
+}
+
+// startProxy runs a proxy to upstream and returns it with the address clients connect to
+func startProxy(t *testing.T, upstream string, timeout time.Duration) (*Proxy, string) {
+	p := &Proxy{
+		Upstream:     upstream,
+		Verifier:     newFixture(t).verifier,
+		PolicyRoot:   []byte("policy root"),
+		ProofTimeout: timeout,
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go p.Serve(l)
+	return p, l.Addr().String()
+}
+
+// recordingConn keeps a copy of the bytes the TLS client reads and writes, from which it derives its statement
+type recordingConn struct {
+	net.Conn
+	read, written bytes.Buffer
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Write(b[:n])
+	return n, err
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.written.Write(b)
+	return c.Conn.Write(b)
+}
+
+// client is a DoH client behind the proxy that has sent one request, held by the proxy
+type client struct {
+	conn   *tls.Conn
+	rec    *recordingConn
+	random []byte
+	st     Statement
+}
+
+// sendRequest connects to the proxy, completes the handshake and sends request.
+// It returns the statement of the request as the client sees it, with the given policy root.
+func sendRequest(t *testing.T, proxy string, roots *x509.CertPool, request []byte, policyRoot []byte) *client {
+	raw, err := net.Dial("tcp", proxy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordingConn{Conn: raw}
+	conn := tls.Client(rec, &tls.Config{RootCAs: roots, ServerName: "localhost", MinVersion: tls.VersionTLS13})
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &client{conn: conn, rec: rec, st: Statement{PolicyRoot: policyRoot}}
+	hello, err := readRecord(&rec.written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.st.ClientHello = hello[recordHeaderLen:]
+	if c.random, err = clientRandom(c.st.ClientHello); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		record, err := readRecord(&rec.read)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch record[0] {
+		case recordHandshake:
+			c.st.ServerHello = record[recordHeaderLen:]
+		case recordApplicationData:
+			c.st.ServerHandshake = append(c.st.ServerHandshake, record)
+		}
+	}
+
+	c.send(t, request)
+	return c
+}
+
+// send sends request in a new application data record, which becomes the ciphertext of the statement
+func (c *client) send(t *testing.T, request []byte) {
+	if c.st.Ciphertext != nil {
+		c.st.Sequence++
+	}
+	c.rec.written.Reset()
+	if _, err := c.conn.Write(request); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	if c.st.Ciphertext, err = readRecord(&c.rec.written); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProxyForwardsProvenRequest(t *testing.T) {
+	addr, roots, received := startServer(t)
+	p, proxy := startProxy(t, addr, 5*time.Second)
+	fx := newFixture(t)
+	proofs := httptest.NewServer(p.ProofHandler())
+	defer proofs.Close()
+
+	request := []byte("GET /dns-query?dns=AAABAAABAAAAAAAAB2V4YW1wbGUDY29tAAABAAE HTTP/1.1\r\n\r\n")
+	c := sendRequest(t, proxy, roots, request, p.PolicyRoot)
+
+	body, _ := json.Marshal(ProofRequest{
+		ClientRandom: hex.EncodeToString(c.random),
+		Proof:        fx.prove(c.st),
+	})
+	resp, err := http.Post(proofs.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("proof submission: got status %d", resp.StatusCode)
+	}
+
+	select {
+	case got := <-received:
+		if !bytes.Equal(got, request) {
+			t.Fatalf("server received %q, want %q", got, request)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not forwarded")
+	}
+	answer := make([]byte, 1024)
+	n, err := c.conn.Read(answer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append([]byte("ok "), request...); !bytes.Equal(answer[:n], want) {
+		t.Fatalf("got answer %q, want %q", answer[:n], want)
+	}
+}
+
+func TestProxyRejectsInvalidProof(t *testing.T) {
+	addr, roots, received := startServer(t)
+	p, proxy := startProxy(t, addr, 5*time.Second)
+	fx := newFixture(t)
+
+	request := []byte("GET /dns-query HTTP/1.1\r\n\r\n")
+	c := sendRequest(t, proxy, roots, request, p.PolicyRoot)
+	// a valid proof, but for another policy than the one the proxy enforces
+	other := c.st
+	other.PolicyRoot = []byte("another policy")
+	if err := p.Submit(c.random, fx.prove(other)); err == nil {
+		t.Fatal("proof for another policy accepted")
+	}
+	// not a proof at all, as anyone who sees the client random can submit
+	if err := p.Submit(c.random, []byte("not a proof")); err == nil {
+		t.Fatal("garbage accepted as a proof")
+	}
+	select {
+	case got := <-received:
+		t.Fatalf("server received %q without a valid proof", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the record is still held, and the proof of the client goes through
+	if err := p.Submit(c.random, fx.prove(c.st)); err != nil {
+		t.Fatalf("valid proof after invalid ones: %v", err)
+	}
+	select {
+	case got := <-received:
+		if !bytes.Equal(got, request) {
+			t.Fatalf("server received %q, want %q", got, request)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not forwarded")
+	}
+}
+
+func TestProxyTimesOutWithoutProof(t *testing.T) {
+	addr, roots, received := startServer(t)
+	p, proxy := startProxy(t, addr, 200*time.Millisecond)
+
+	c := sendRequest(t, proxy, roots, []byte("GET /dns-query HTTP/1.1\r\n\r\n"), p.PolicyRoot)
+	if err := p.Submit(c.random, []byte("not a proof")); err == nil {
+		t.Fatal("garbage accepted as a proof")
+	}
+	if _, err := c.conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("connection still open without a valid proof")
+	}
+	select {
+	case got := <-received:
+		t.Fatalf("server received %q without a proof", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := p.Submit(c.random, nil); !errors.Is(err, ErrUnknownSession) {
+		t.Fatalf("submitting for a closed connection: got %v, want ErrUnknownSession", err)
+	}
+}
+
+// The records of a connection are proved one after the other, each with its sequence number
+func TestProxyCountsRecords(t *testing.T) {
+	addr, roots, received := startServer(t)
+	p, proxy := startProxy(t, addr, 5*time.Second)
+	fx := newFixture(t)
+
+	requests := [][]byte{[]byte("GET /dns-query?dns=1 HTTP/1.1\r\n\r\n"), []byte("GET /dns-query?dns=2 HTTP/1.1\r\n\r\n")}
+	c := sendRequest(t, proxy, roots, requests[0], p.PolicyRoot)
+	for i, request := range requests {
+		if i > 0 {
+			c.send(t, request)
+			// the same record proved as the first of the connection
+			first := c.st
+			first.Sequence = 0
+			if err := p.Submit(c.random, fx.prove(first)); err == nil {
+				t.Fatalf("record %d accepted with sequence number 0", i)
+			}
+		}
+		if err := p.Submit(c.random, fx.prove(c.st)); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		select {
+		case got := <-received:
+			if !bytes.Equal(got, request) {
+				t.Fatalf("server received %q, want %q", got, request)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("record %d not forwarded", i)
+		}
+		if _, err := c.conn.Read(make([]byte, 1024)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
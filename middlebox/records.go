@@ -0,0 +1,59 @@
+package middlebox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// TLS record content types, RFC 8446 section 5.1
+const (
+	recordChangeCipherSpec = 20
+	recordAlert            = 21
+	recordHandshake        = 22
+	recordApplicationData  = 23
+
+	recordHeaderLen = 5
+	// largest TLSCiphertext fragment, RFC 8446 section 5.2
+	maxRecordLen = 1<<14 + 256
+)
+
+// handshake message types, RFC 8446 section 4
+const (
+	handshakeClientHello = 1
+	handshakeServerHello = 2
+)
+
+// readRecord reads one TLS record and returns it with its header
+func readRecord(r io.Reader) ([]byte, error) {
+	header := make([]byte, recordHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	switch header[0] {
+	case recordChangeCipherSpec, recordAlert, recordHandshake, recordApplicationData:
+	default:
+		return nil, fmt.Errorf("not a TLS record (content type %d)", header[0])
+	}
+	n := int(binary.BigEndian.Uint16(header[3:]))
+	if n > maxRecordLen {
+		return nil, fmt.Errorf("TLS record of %d bytes exceeds the maximum of %d", n, maxRecordLen)
+	}
+
+	record := make([]byte, recordHeaderLen+n)
+	copy(record, header)
+	if _, err := io.ReadFull(r, record[recordHeaderLen:]); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// clientRandom returns the random of a ClientHello message, which identifies the connection
+func clientRandom(clientHello []byte) ([]byte, error) {
+	// msg_type (1) || length (3) || legacy_version (2) || random (32)
+	if len(clientHello) < 38 || clientHello[0] != handshakeClientHello {
+		return nil, errors.New("expected a ClientHello")
+	}
+	return clientHello[6:38], nil
+}
@@ -0,0 +1,71 @@
+package middlebox
+
+import (
+	"errors"
+	"fmt"
+
+	"anonpao/circuits"
+	"anonpao/snark"
+
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/frontend"
+)
+
+// Circuit is implemented by the circuits whose public inputs are derived from the traffic seen by the proxy,
+// such as the tls-key-schedule and doh-firewall circuits
+type Circuit interface {
+	frontend.Circuit
+
+	// AssignPublic sets the public inputs of the circuit from the statement, the secret ones are left unset
+	AssignPublic(st Statement) error
+}
+
+// the registered circuits the proxy verifies the requests of
+var (
+	_ Circuit = (*circuits.TLSKeyScheduleCircuit)(nil)
+	_ Circuit = (*circuits.DoHFirewallCircuit)(nil)
+)
+
+// SnarkVerifier checks the proofs of a registered circuit that implements Circuit, with one verifying key
+type SnarkVerifier struct {
+	def     circuits.Definition
+	backend backend.ID
+	vk      snark.VerifyingKey
+	header  snark.Header
+}
+
+// NewSnarkVerifier returns a verifier for the proofs of def, given its verifying key and the header it was read with.
+// PLONK keys must already have their KZG SRS attached.
+func NewSnarkVerifier(def circuits.Definition, b backend.ID, vk snark.VerifyingKey, h snark.Header) (*SnarkVerifier, error) {
+	if _, ok := def.New().(Circuit); !ok {
+		return nil, fmt.Errorf("circuit %s does not derive its public inputs from traffic", def.Name)
+	}
+	if err := h.CheckDefinition(def); err != nil {
+		return nil, err
+	}
+	return &SnarkVerifier{def, b, vk, h}, nil
+}
+
+// Verify checks a proof, in the format written by the zk command, against the public inputs derived from st
+func (v *SnarkVerifier) Verify(st Statement, data []byte) error {
+	proof, h, err := snark.DecodeProof(data, v.backend)
+	if err != nil {
+		return err
+	}
+	if err := v.header.CheckSame(h); err != nil {
+		return err
+	}
+
+	assignment := v.def.New().(Circuit)
+	if err := assignment.AssignPublic(st); err != nil {
+		return err
+	}
+	publicWitness, err := frontend.NewWitness(assignment, v.header.Curve.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return err
+	}
+	if err := snark.Verify(v.backend, proof, v.vk, publicWitness); err != nil {
+		return errors.New("invalid proof")
+	}
+	return nil
+}
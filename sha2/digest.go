@@ -0,0 +1,148 @@
+package sha2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Digest is a streaming SHA-256, built on sha2_compression and compression_with_words of sha2.go: it implements hash.Hash,
+// so the input no longer needs to be known up front as with SHA2, and encoding.BinaryMarshaler and
+// BinaryUnmarshaler with the state encoding of crypto/sha256, so states move between both.
+//
+// At a block boundary, the state of SHA-256 is only the H-state and the number of bytes processed:
+// Checkpoint returns it, which is what SHA2_of_tail and Double_SHA_from_checkpoint resume from,
+// and Resume carries on hashing from it.
+
+const (
+	Size      = 32
+	BlockSize = 64
+
+	// marshalMagic and marshaledSize are those of crypto/sha256
+	marshalMagic  = "sha\x03"
+	marshaledSize = len(marshalMagic) + 8*4 + BlockSize + 8
+)
+
+type Digest struct {
+	h   [8]uint32
+	x   [BlockSize]byte // the bytes of the current block not compressed yet
+	nx  int
+	len uint64
+}
+
+// Checkpoint is the state of SHA-256 after hashing Length bytes, Length being a multiple of the block size
+type Checkpoint struct {
+	H      [8]uint32
+	Length uint64
+}
+
+// New returns a SHA-256 digest
+func New() *Digest {
+	d := new(Digest)
+	d.Reset()
+	return d
+}
+
+// Resume returns a digest that has hashed the c.Length bytes that led to c.H
+func Resume(c Checkpoint) (*Digest, error) {
+	if c.Length%BlockSize != 0 {
+		return nil, fmt.Errorf("sha2: checkpoint after %d bytes, which is not a block boundary", c.Length)
+	}
+	return &Digest{h: c.H, len: c.Length}, nil
+}
+
+func (d *Digest) Reset() {
+	copy(d.h[:], H_CONST)
+	d.nx = 0
+	d.len = 0
+}
+
+func (d *Digest) Size() int { return Size }
+
+func (d *Digest) BlockSize() int { return BlockSize }
+
+func (d *Digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	if d.nx > 0 {
+		c := copy(d.x[d.nx:], p)
+		d.nx += c
+		p = p[c:]
+		if d.nx < BlockSize {
+			return n, nil
+		}
+		d.compress(d.x[:])
+		d.nx = 0
+	}
+	for ; len(p) >= BlockSize; p = p[BlockSize:] {
+		d.compress(p[:BlockSize])
+	}
+	d.nx = copy(d.x[:], p)
+	return n, nil
+}
+
+func (d *Digest) compress(block []byte) {
+	sha2_compression(i8to32(block), d.h[:])
+}
+
+// Sum appends the hash of the bytes written so far to b, without changing the state of d
+func (d *Digest) Sum(b []byte) []byte {
+	e := *d
+	// the pad is 0x80, zeros up to 8 bytes before a block boundary, and the length in bits
+	padLength := BlockSize - int(e.len%BlockSize)
+	if padLength < 9 {
+		padLength += BlockSize
+	}
+	pad := make([]byte, padLength)
+	pad[0] = 0x80
+	binary.BigEndian.PutUint64(pad[len(pad)-8:], e.len*8)
+	if block, words, ok := Pad_schedule(e.len); ok {
+		// the last block is the pad alone, whose message schedule is precomputed
+		e.Write(pad[:len(pad)-BlockSize])
+		compression_with_words(block, e.h[:], words)
+	} else {
+		e.Write(pad)
+	}
+
+	var out [Size]byte
+	for i, h := range e.h {
+		binary.BigEndian.PutUint32(out[4*i:], h)
+	}
+	return append(b, out[:]...)
+}
+
+// Checkpoint returns the state of d, which must be at a block boundary
+func (d *Digest) Checkpoint() (Checkpoint, error) {
+	if d.nx != 0 {
+		return Checkpoint{}, fmt.Errorf("sha2: %d bytes hashed, which is not a block boundary", d.len)
+	}
+	return Checkpoint{H: d.h, Length: d.len}, nil
+}
+
+// MarshalBinary encodes the state of d as crypto/sha256 does
+func (d *Digest) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, marshaledSize)
+	b = append(b, marshalMagic...)
+	for _, h := range d.h {
+		b = binary.BigEndian.AppendUint32(b, h)
+	}
+	b = append(b, d.x[:d.nx]...)
+	b = append(b, make([]byte, BlockSize-d.nx)...)
+	return binary.BigEndian.AppendUint64(b, d.len), nil
+}
+
+// UnmarshalBinary restores a state encoded by MarshalBinary, or by crypto/sha256
+func (d *Digest) UnmarshalBinary(b []byte) error {
+	if len(b) != marshaledSize || string(b[:len(marshalMagic)]) != marshalMagic {
+		return errors.New("sha2: invalid SHA-256 state")
+	}
+	b = b[len(marshalMagic):]
+	for i := range d.h {
+		d.h[i] = binary.BigEndian.Uint32(b[4*i:])
+	}
+	b = b[4*len(d.h):]
+	copy(d.x[:], b[:BlockSize])
+	d.len = binary.BigEndian.Uint64(b[BlockSize:])
+	d.nx = int(d.len % BlockSize)
+	return nil
+}
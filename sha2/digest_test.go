@@ -0,0 +1,128 @@
+package sha2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"hash"
+	"testing"
+)
+
+var _ hash.Hash = (*Digest)(nil)
+
+func testInput(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i*7 + 3)
+	}
+	return b
+}
+
+func TestDigestMatchesStdlib(t *testing.T) {
+	for n := 0; n < 300; n++ {
+		input := testInput(n)
+		want := sha256.Sum256(input)
+
+		// write in uneven chunks, to cross block boundaries in the middle of writes
+		d := New()
+		for i := 0; i < n; i += 1 + i%13 {
+			d.Write(input[i:min(i+1+i%13, n)])
+		}
+		if got := d.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Fatalf("%d bytes: got %x, want %x", n, got, want)
+		}
+		// Sum does not change the state
+		if got := d.Sum(nil); !bytes.Equal(got, want[:]) {
+			t.Fatalf("%d bytes: second Sum gives %x", n, got)
+		}
+		if got := SHA2(input); !bytes.Equal(got, want[:]) {
+			t.Fatalf("%d bytes: SHA2 gives %x, want %x", n, got, want)
+		}
+	}
+}
+
+func TestDigestMarshalMatchesStdlib(t *testing.T) {
+	input := testInput(200)
+	for _, n := range []int{0, 1, 63, 64, 65, 130} {
+		ours, std := New(), sha256.New()
+		ours.Write(input[:n])
+		std.Write(input[:n])
+
+		state, err := ours.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdState, err := std.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(state, stdState) {
+			t.Fatalf("after %d bytes: state %x, crypto/sha256 has %x", n, state, stdState)
+		}
+
+		// resume each from the other's state
+		resumed := new(Digest)
+		if err := resumed.UnmarshalBinary(stdState); err != nil {
+			t.Fatal(err)
+		}
+		if err := std.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+		resumed.Write(input[n:])
+		std.Write(input[n:])
+		if got, want := resumed.Sum(nil), std.Sum(nil); !bytes.Equal(got, want) {
+			t.Fatalf("resumed after %d bytes: got %x, want %x", n, got, want)
+		}
+	}
+
+	if err := new(Digest).UnmarshalBinary([]byte("sha\x03")); err == nil {
+		t.Fatal("truncated state accepted")
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	input := testInput(250)
+	want := sha256.Sum256(input)
+
+	d := New()
+	d.Write(input[:100])
+	if _, err := d.Checkpoint(); err == nil {
+		t.Fatal("checkpoint in the middle of a block")
+	}
+
+	d = New()
+	d.Write(input[:192])
+	c, err := d.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Length != 192 {
+		t.Fatalf("checkpoint after %d bytes, want 192", c.Length)
+	}
+	resumed, err := Resume(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Write(input[192:])
+	if got := resumed.Sum(nil); !bytes.Equal(got, want[:]) {
+		t.Fatalf("resumed: got %x, want %x", got, want)
+	}
+
+	// the H-state is what SHA2_of_tail resumes from, for tails that fit in two blocks with the pad
+	tail := make([]byte, 128)
+	copy(tail, input[192:])
+	if got, err := SHA2_of_tail(tail, byte(len(input)-192), uint64(len(input)), c.H[:]); err != nil || !bytes.Equal(got, want[:]) {
+		t.Fatalf("SHA2_of_tail from the checkpoint: got %x, want %x", got, want)
+	}
+
+	if _, err := Resume(Checkpoint{Length: 100}); err == nil {
+		t.Fatal("resumed in the middle of a block")
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,159 @@
+package sha2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// The examples of FIPS 180-4 (csrc.nist.gov/projects/cryptographic-standards-and-guidelines/example-values)
+var fipsVectors = []struct {
+	input  string
+	digest string
+}{
+	{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	{"abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq", "248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1"},
+	{"abcdefghbcdefghicdefghijdefghijkefghijklfghijklmghijklmnhijklmnoijklmnopjklmnopqklmnopqrlmnopqrsmnopqrstnopqrstu",
+		"cf5b16a778af8380036ce59e7b0492370b249b11e8f07a51afac45037afee9d1"},
+	{strings.Repeat("a", 1000000), "cdc76e5c9914fb9281a1c7e284d73e67f1809a48a497200e046d39ccc7112cd0"},
+}
+
+func TestFIPSVectors(t *testing.T) {
+	for _, v := range fipsVectors {
+		input := []byte(v.input)
+		d := New()
+		d.Write(input)
+		for name, got := range map[string][]byte{"SHA2": SHA2(input), "Digest": d.Sum(nil)} {
+			if hex.EncodeToString(got) != v.digest {
+				t.Errorf("%s of %.20q (%d bytes): got %x, want %s", name, v.input, len(input), got, v.digest)
+			}
+		}
+	}
+	if hex.EncodeToString(Hash_of_empty()) != fipsVectors[0].digest {
+		t.Errorf("Hash_of_empty: got %x", Hash_of_empty())
+	}
+}
+
+// checkpoint returns the checkpoint after the first n bytes of input, n being a multiple of the block size
+func checkpoint(t *testing.T, input []byte, n int) Checkpoint {
+	d := New()
+	d.Write(input[:n])
+	c, err := d.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func FuzzSHA2(f *testing.F) {
+	for _, n := range []int{0, 1, 55, 56, 63, 64, 65, 119, 120, 128, 300} {
+		f.Add(testInput(n), uint8(n%17))
+	}
+	f.Fuzz(func(t *testing.T, input []byte, chunk uint8) {
+		want := sha256.Sum256(input)
+		if got := SHA2(input); !bytes.Equal(got, want[:]) {
+			t.Fatalf("SHA2: got %x, want %x", got, want)
+		}
+		if len(input) == 64 {
+			if got := SHA2_512_length(input); !bytes.Equal(got, want[:]) {
+				t.Fatalf("SHA2_512_length: got %x, want %x", got, want)
+			}
+		}
+
+		// Digest written in chunks, with its state going through crypto/sha256 half way
+		d := New()
+		half := len(input) / 2
+		for i := 0; i < half; i += int(chunk) + 1 {
+			d.Write(input[i:min(i+int(chunk)+1, half)])
+		}
+		state, _ := d.MarshalBinary()
+		std := sha256.New()
+		if err := std.(interface{ UnmarshalBinary([]byte) error }).UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+		d.Write(input[half:])
+		std.Write(input[half:])
+		if got := d.Sum(nil); !bytes.Equal(got, want[:]) || !bytes.Equal(std.Sum(nil), want[:]) {
+			t.Fatalf("Digest: got %x, want %x", got, want)
+		}
+	})
+}
+
+func FuzzSHA2OfTail(f *testing.F) {
+	for _, n := range []int{0, 40, 64, 100, 130, 190, 500} {
+		f.Add(testInput(n), uint8(36))
+	}
+	f.Fuzz(func(t *testing.T, input []byte, cut uint8) {
+		// the longest tail after a checkpoint that fits in two blocks with its pad
+		tail_length := len(input) % 64
+		if len(input) >= 64 && tail_length < 56 {
+			tail_length += 64
+		}
+		n := len(input) - tail_length
+		c := checkpoint(t, input, n)
+		tail := make([]byte, 128)
+		copy(tail, input[n:])
+
+		want := sha256.Sum256(input)
+		got, err := SHA2_of_tail(tail, byte(tail_length), uint64(len(input)), c.H[:])
+		if err != nil || !bytes.Equal(got, want[:]) {
+			t.Fatalf("SHA2_of_tail after %d bytes: got %x, %v, want %x", n, got, err, want)
+		}
+
+		// a prefix ending in the same tail, as TR7 of TR3
+		prefix_tail_length := tail_length - min(int(cut), tail_length)
+		prefix := input[:n+prefix_tail_length]
+		wantPrefix := sha256.Sum256(prefix)
+		both, err := Double_SHA_from_checkpoint(c.H[:], uint64(len(input)), uint64(len(prefix)), tail, byte(tail_length), byte(prefix_tail_length))
+		if err != nil || !bytes.Equal(both[0], wantPrefix[:]) || !bytes.Equal(both[1], want[:]) {
+			t.Fatalf("Double_SHA_from_checkpoint: got %x, %v, want %x and %x", both, err, wantPrefix, want)
+		}
+		if c.H != checkpoint(t, input, n).H {
+			t.Fatal("the checkpoint was modified")
+		}
+	})
+}
+
+func FuzzSHA2OfPrefix(f *testing.F) {
+	for _, n := range []int{0, 55, 64, 100, 190} {
+		f.Add(testInput(200), uint8(n))
+	}
+	f.Fuzz(func(t *testing.T, input []byte, length uint8) {
+		if int(length) > len(input) {
+			return
+		}
+		// the last block is given apart, and the input must hold its whole blocks
+		last_block := make([]byte, 64)
+		copy(last_block, input[int(length)-int(length)%64:length])
+		padded := append(append([]byte{}, input...), make([]byte, 64)...)
+
+		want := sha256.Sum256(input[:length])
+		if got, err := SHA2_of_prefix(padded, uint64(length), last_block); err != nil || !bytes.Equal(got, want[:]) {
+			t.Fatalf("SHA2_of_prefix of %d bytes: got %x, %v, want %x", length, got, err, want)
+		}
+	})
+}
+
+func FuzzSumPrefixes(f *testing.F) {
+	f.Add(testInput(300), []byte{0, 1, 64, 100, 255}, uint8(1))
+	f.Fuzz(func(t *testing.T, input []byte, lengths []byte, blocks uint8) {
+		n := min(int(blocks), len(input)/64) * 64
+		c := checkpoint(t, input, n)
+		prefixes := make([]uint64, len(lengths))
+		for i, l := range lengths {
+			prefixes[i] = uint64(n + int(l)%(len(input)-n+1))
+		}
+		digests, err := SumPrefixes(c, input[n:], prefixes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, l := range prefixes {
+			if want := sha256.Sum256(input[:l]); !bytes.Equal(digests[i], want[:]) {
+				t.Fatalf("prefix of %d bytes: got %x, want %x", l, digests[i], want)
+			}
+		}
+	})
+}
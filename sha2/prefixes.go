@@ -0,0 +1,39 @@
+package sha2
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SumPrefixes generalises Double_SHA_from_checkpoint to any number of prefixes: it returns the SHA-256
+// digests of prefixes of a string, resuming from the checkpoint c taken after its first c.Length bytes.
+// tail holds the bytes that follow, and lengths the lengths of the prefixes, counted from the start of the
+// string as for Double_SHA_from_checkpoint. The digests are in the order of lengths; the whole blocks of
+// the tail are compressed once for all of them, and each prefix only adds its last block and pad.
+//
+// This gives in one pass the transcript hashes of the key schedule, all prefixes of one transcript
+// (e.g. up to ServerHello, CertificateVerify, server Finished and client Finished).
+func SumPrefixes(c Checkpoint, tail []byte, lengths []uint64) ([][]byte, error) {
+	order := make([]int, len(lengths))
+	for i, length := range lengths {
+		if length < c.Length || length-c.Length > uint64(len(tail)) {
+			return nil, fmt.Errorf("sha2: prefix of %d bytes, outside the %d bytes of the tail after the checkpoint at %d",
+				length, len(tail), c.Length)
+		}
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return lengths[order[i]] < lengths[order[j]] })
+
+	d, err := Resume(c)
+	if err != nil {
+		return nil, err
+	}
+	digests := make([][]byte, len(lengths))
+	written := c.Length
+	for _, i := range order {
+		d.Write(tail[written-c.Length : lengths[i]-c.Length])
+		written = lengths[i]
+		digests[i] = d.Sum(nil)
+	}
+	return digests, nil
+}
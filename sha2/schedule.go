@@ -0,0 +1,65 @@
+package sha2
+
+import (
+	"sync"
+)
+
+// The last block of a padded input holds no byte of the input when the input ends on a block boundary,
+// or when its last block leaves no room for the 9 bytes of 0x80 and the length: the block is then the
+// pad alone, which only depends on the length, and so does its message schedule. PAD_FOR_512 and
+// WORDS_FOR_512_PAD are that block and schedule for 64-byte inputs; Pad_schedule generates them for any
+// length, so that SHA2, SHA2_of_tail, Digest and the gadgets skip the message expansion of such blocks
+// with compression_with_words. HMAC-SHA256 with a 64-byte key over a 56 to 64 byte message, or over a
+// whole number of blocks, ends with one.
+//
+// The other HMAC shapes, the outer hash (64 + 32 bytes) and the inner hash of HKDF-Expand over a short
+// label, end with a block that holds bytes of the input: only the words after them are constant, and no
+// word of the schedule past the block depends on those alone. Their constant terms are not precomputed:
+// natively they are about 10 of the 192 terms of the expansion, and skipping them made the compression
+// slower, 395 ns against 369; in circuit gnark already folds xors with a constant, and skipping them saved
+// 6 of the 29815 constraints of the last block of the outer hash.
+
+// padScheduleCacheSize bounds the number of lengths whose schedules are cached: the cache is emptied when full
+const padScheduleCacheSize = 1024
+
+// padSchedules caches the schedules generated by Pad_schedule by input length in bits modulo 2^64,
+// which determines them
+var padSchedules = struct {
+	sync.Mutex
+	words map[uint64][]uint32
+}{words: make(map[uint64][]uint32)}
+
+// Pad_schedule returns the last block of the padded input of a given length in bytes and its
+// message schedule, if that block only holds padding. Both are shared and must not be modified.
+func Pad_schedule(length uint64) (block []uint32, words []uint32, ok bool) {
+	if length%64 != 0 && length%64 < 56 {
+		return nil, nil, false
+	}
+
+	padSchedules.Lock()
+	defer padSchedules.Unlock()
+	words, ok = padSchedules.words[length*8]
+	if !ok {
+		if len(padSchedules.words) >= padScheduleCacheSize {
+			padSchedules.words = make(map[uint64][]uint32)
+		}
+		words = make([]uint32, 64)
+		if length%64 == 0 {
+			words[0] = 0x80000000
+		}
+		words[14] = uint32(length * 8 >> 32)
+		words[15] = uint32(length * 8)
+		expand_message_schedule(words)
+		padSchedules.words[length*8] = words
+	}
+	return words[:16], words, true
+}
+
+// expand_message_schedule computes the words 16 to 63 of the message schedule from the first 16, the block
+func expand_message_schedule(words []uint32) {
+	for j := 16; j < 64; j++ {
+		s0 := rotateRight(words[j-15], 7) ^ rotateRight(words[j-15], 18) ^ (words[j-15] >> 3)
+		s1 := rotateRight(words[j-2], 17) ^ rotateRight(words[j-2], 19) ^ (words[j-2] >> 10)
+		words[j] = words[j-16] + s0 + words[j-7] + s1
+	}
+}
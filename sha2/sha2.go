@@ -2,6 +2,8 @@ package sha2
 
 import (
 	"anonpao/utils"
+	"fmt"
+	"math"
 )
 
 // The constant definitions and the compression function are taken from the xJsnark example
@@ -16,11 +18,8 @@ func rotateRight(x uint32, n uint32) uint32 {
 }
 
 // This is the main SHA calling function.
+// A last block holding only the pad is compressed with its precomputed message schedule (see schedule.go).
 func SHA2(input []uint8) []uint8 {
-	if len(input) == 64 {
-		return SHA2_512_length(input)
-	}
-
 	padded_input := padded_sha_input(input)
 	input_in_32 := utils.Convert_8_to_32(padded_input)
 
@@ -29,6 +28,10 @@ func SHA2(input []uint8) []uint8 {
 	}
 
 	num_blocks := len(input_in_32) / 16
+	pad_block, pad_words, pad_only := Pad_schedule(uint64(len(input)))
+	if pad_only {
+		num_blocks--
+	}
 
 	h_value := []uint32{H_CONST[0], H_CONST[1], H_CONST[2], H_CONST[3], H_CONST[4], H_CONST[5], H_CONST[6], H_CONST[7]}
 
@@ -39,12 +42,15 @@ func SHA2(input []uint8) []uint8 {
 		}
 		h_value = sha2_compression(block, h_value)
 	}
+	if pad_only {
+		h_value = compression_with_words(pad_block, h_value, pad_words)
+	}
 
 	return utils.Convert_32_to_8(h_value)
 }
 
 // The next two variables were used for a minor optimization for when the padded input is just one block length
-// which is 512 bits in SHA2
+// which is 512 bits in SHA2. Pad_schedule generalises them to any length.
 
 var PAD_FOR_512 = []uint32{2147483648, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 512}
 
@@ -150,11 +156,14 @@ func sha2_compression(input []uint32, H []uint32) []uint32 {
 	return H
 }
 
+// MaxLength is the length in bytes of the longest input of SHA-256, whose length in bits must fit in 64 bits
+const MaxLength = math.MaxUint64 / 8
+
 // Returns the length of the pad required for a given input length
 
-func get_pad_length(input_length uint16) uint8 {
+func get_pad_length(input_length uint64) uint8 {
 
-	last_block_length := uint8(input_length % uint16(64))
+	last_block_length := uint8(input_length % uint64(64))
 
 	var pad_length byte
 
@@ -169,10 +178,13 @@ func get_pad_length(input_length uint16) uint8 {
 
 // Returns the actual pad required for a given input length
 
-func get_pad_from_length_in_bytes(length uint16) []byte {
+func get_pad_from_length_in_bytes(length uint64) ([]byte, error) {
+	if length > MaxLength {
+		return nil, fmt.Errorf("sha2: input of %d bytes, longer than the %d bytes SHA-256 can hash", length, uint64(MaxLength))
+	}
 	pad_length := get_pad_length(length)
 
-	input_len_in_bits := utils.Convert_64_to_8(uint64(length) * uint64(8))
+	input_len_in_bits := utils.Convert_64_to_8(length * uint64(8))
 
 	// It'll be less than 72 but 128 makes it an even multiple of 64
 	pad := make([]byte, 128)
@@ -189,7 +201,7 @@ func get_pad_from_length_in_bytes(length uint16) []byte {
 		}
 	}
 
-	return pad
+	return pad, nil
 }
 
 // ///////////////////////// Functions for computing the hash of a string AND a prefix of that string
@@ -206,13 +218,22 @@ func get_pad_from_length_in_bytes(length uint16) []byte {
 // full_tail - the portion of the full string past the checkpoint block
 // full_tail_length
 // prefix_tail_length - the length of the prefix of full_tail that belongs to the prefix string
+//
+// The lengths are checked to describe a prefix of the full string sharing the checkpoint,
+// and an error is returned otherwise.
 
 func Double_SHA_from_checkpoint(
 	H_checkpoint []uint32,
-	full_length uint16, prefix_length uint16,
+	full_length uint64, prefix_length uint64,
 	full_tail_string []byte,
 	full_tail_length byte,
-	prefix_tail_length byte) [][]byte {
+	prefix_tail_length byte) ([][]byte, error) {
+
+	if prefix_length > full_length || prefix_tail_length > full_tail_length ||
+		full_length-prefix_length != uint64(full_tail_length-prefix_tail_length) {
+		return nil, fmt.Errorf("sha2: a prefix of %d bytes with a tail of %d does not share its checkpoint with a string of %d bytes with a tail of %d",
+			prefix_length, prefix_tail_length, full_length, full_tail_length)
+	}
 
 	H_checkpoint_copy_1 := make([]uint32, 8)
 	H_checkpoint_copy_2 := make([]uint32, 8)
@@ -220,10 +241,15 @@ func Double_SHA_from_checkpoint(
 	copy(H_checkpoint_copy_1, H_checkpoint)
 	copy(H_checkpoint_copy_2, H_checkpoint)
 
-	prefix_output := SHA2_of_tail(full_tail_string, prefix_tail_length, prefix_length, H_checkpoint_copy_1)
-	full_output := SHA2_of_tail(full_tail_string, full_tail_length, full_length, H_checkpoint_copy_2)
-	return [][]byte{prefix_output, full_output}
-
+	prefix_output, err := SHA2_of_tail(full_tail_string, prefix_tail_length, prefix_length, H_checkpoint_copy_1)
+	if err != nil {
+		return nil, err
+	}
+	full_output, err := SHA2_of_tail(full_tail_string, full_tail_length, full_length, H_checkpoint_copy_2)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{prefix_output, full_output}, nil
 }
 
 // This function takes as input a tail string that is of length less than 128 bytes
@@ -250,11 +276,21 @@ func i8to32(val []byte) []uint32 {
 	return r
 }
 
-func SHA2_of_tail(tail []byte, tail_length byte, full_length uint16, H_checkpoint []uint32) []byte {
+func SHA2_of_tail(tail []byte, tail_length byte, full_length uint64, H_checkpoint []uint32) ([]byte, error) {
+	if int(tail_length) > len(tail) || uint64(tail_length) > full_length || (full_length-uint64(tail_length))%64 != 0 {
+		return nil, fmt.Errorf("sha2: a tail of %d bytes (of %d given) does not start at a block boundary of a string of %d bytes",
+			tail_length, len(tail), full_length)
+	}
 
 	// Calculate the pad
 	pad_len_in_bytes := get_pad_length(full_length)
-	pad := get_pad_from_length_in_bytes(full_length)
+	pad, err := get_pad_from_length_in_bytes(full_length)
+	if err != nil {
+		return nil, err
+	}
+	if int(tail_length)+int(pad_len_in_bytes) > 128 {
+		return nil, fmt.Errorf("sha2: a tail of %d bytes does not fit in two blocks with its pad", tail_length)
+	}
 
 	// tail_with_pad = tail || pad
 	tail_with_pad := make([]byte, 128)
@@ -273,10 +309,17 @@ func SHA2_of_tail(tail []byte, tail_length byte, full_length uint16, H_checkpoin
 	}
 
 	var output []uint32
-	H_value := H_checkpoint
+	// the compressions update the H-state in place, which must not be the caller's checkpoint
+	H_value := append([]uint32{}, H_checkpoint...)
 
 	block := make([]byte, 64)
 
+	// The last block may be the pad alone, with a precomputed message schedule
+	pad_block, pad_words, pad_only := Pad_schedule(full_length)
+	if pad_only {
+		num_compressions--
+	}
+
 	// Iterate for the maximum possible times, which is 2.
 	// NOTE: input must be long enough to support maximum number of iterations
 	for i := 0; i < 2; i++ {
@@ -288,10 +331,13 @@ func SHA2_of_tail(tail []byte, tail_length byte, full_length uint16, H_checkpoin
 			H_value = sha2_compression(i8to32(block), H_value)
 		}
 	}
+	if pad_only {
+		H_value = compression_with_words(pad_block, H_value, pad_words)
+	}
 
 	output = H_value
 
-	return utils.Convert_32_to_8(output)
+	return utils.Convert_32_to_8(output), nil
 }
 
 // Function for when the input is of length 512 bits (one SHA block)
@@ -300,7 +346,8 @@ func SHA2_of_tail(tail []byte, tail_length byte, full_length uint16, H_checkpoin
 
 // convert the above function to go:
 func SHA2_512_length(input []byte) []byte {
-	h_value := H_CONST
+	// the compressions update the H-state in place, which must not be H_CONST
+	h_value := append([]uint32{}, H_CONST...)
 	h_value = sha2_compression(utils.Convert_8_to_32(input), h_value)
 	h_value = compression_with_words(PAD_FOR_512, h_value, WORDS_FOR_512_PAD)
 	return utils.Convert_32_to_8(h_value)
@@ -379,12 +426,12 @@ func compression_with_words(input []uint32, H []uint32, words []uint32) []uint32
 }
 
 // Performs the specified number of sha2 compression calls on the given input
-func perform_compressions(input []byte, num_compressions byte) []uint32 {
-	return perform_compressions_general(input, num_compressions, H_CONST)
+func perform_compressions(input []byte, num_compressions uint64) []uint32 {
+	return perform_compressions_general(input, num_compressions, append([]uint32{}, H_CONST...))
 }
 
 // The above, but with an arbitary H-state
-func perform_compressions_general(input []byte, num_compressions byte, H_checkpoint []uint32) []uint32 {
+func perform_compressions_general(input []byte, num_compressions uint64, H_checkpoint []uint32) []uint32 {
 	// rewrite above function here:
 	h_value := H_checkpoint
 	block := make([]uint8, 64)
@@ -394,7 +441,7 @@ func perform_compressions_general(input []byte, num_compressions byte, H_checkpo
 
 	max_compressions := len(input) / 64
 	for i := 0; i < max_compressions; i++ {
-		if byte(i) < num_compressions {
+		if uint64(i) < num_compressions {
 			for j := 0; j < 64; j++ {
 				block[j] = input[i*64+j]
 			}
@@ -408,14 +455,21 @@ func perform_compressions_general(input []byte, num_compressions byte, H_checkpo
 // Given an input string, a length and a final block
 // this function returns the hash of the first l bytes of the input
 // The final block is provided as auxiliary input to optimize the final circuit.
-func SHA2_of_prefix(input []byte, tr_len_in_bytes uint16, last_block []byte) []byte {
+// An error is returned if the whole blocks of the prefix are not all in input.
+func SHA2_of_prefix(input []byte, tr_len_in_bytes uint64, last_block []byte) ([]byte, error) {
 	output := make([]byte, 32)
 	pad_len_in_bytes := get_pad_length(tr_len_in_bytes)
-	pad := get_pad_from_length_in_bytes(tr_len_in_bytes)
+	pad, err := get_pad_from_length_in_bytes(tr_len_in_bytes)
+	if err != nil {
+		return nil, err
+	}
 
-	last_block_len := byte(tr_len_in_bytes % uint16(64))
+	last_block_len := byte(tr_len_in_bytes % uint64(64))
 
-	num_base_compressions := byte(tr_len_in_bytes / uint16(64))
+	num_base_compressions := tr_len_in_bytes / uint64(64)
+	if num_base_compressions > uint64(len(input)/64) {
+		return nil, fmt.Errorf("sha2: a prefix of %d bytes is longer than the %d whole blocks of the input", tr_len_in_bytes, len(input)/64)
+	}
 
 	H_value_base := perform_compressions(input, num_base_compressions)
 
@@ -449,6 +503,6 @@ func SHA2_of_prefix(input []byte, tr_len_in_bytes uint16, last_block []byte) []b
 		output = sha2_no_pad_with_checkpoint(last_block_padded, H_value_base)
 
 	}
-	return output
+	return output, nil
 
 }
@@ -0,0 +1,196 @@
+package sha2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+// Transcripts past 64 KB used to wrap the 16-bit lengths around
+func TestDoubleSHAFromCheckpointPast64KB(t *testing.T) {
+	input := testInput(70000 + 100)
+	prefix := input[:70000+100-36]
+	wantFull, wantPrefix := sha256.Sum256(input), sha256.Sum256(prefix)
+
+	checkpointLength := len(prefix) - len(prefix)%BlockSize
+	d := New()
+	d.Write(input[:checkpointLength])
+	c, err := d.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tail := make([]byte, 128)
+	copy(tail, input[checkpointLength:])
+	fullTail, prefixTail := byte(len(input)-checkpointLength), byte(len(prefix)-checkpointLength)
+
+	out, err := Double_SHA_from_checkpoint(c.H[:], uint64(len(input)), uint64(len(prefix)), tail, fullTail, prefixTail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out[0], wantPrefix[:]) || !bytes.Equal(out[1], wantFull[:]) {
+		t.Fatalf("got %x and %x, want %x and %x", out[0], out[1], wantPrefix, wantFull)
+	}
+
+	// lengths that do not describe a prefix sharing the checkpoint
+	if _, err := Double_SHA_from_checkpoint(c.H[:], uint64(len(input))+65536, uint64(len(prefix)), tail, fullTail, prefixTail); err == nil {
+		t.Fatal("full length off by 64 KB accepted")
+	}
+	if _, err := Double_SHA_from_checkpoint(c.H[:], uint64(len(prefix)), uint64(len(input)), tail, prefixTail, fullTail); err == nil {
+		t.Fatal("prefix longer than the full string accepted")
+	}
+}
+
+func TestLengthErrors(t *testing.T) {
+	if _, err := get_pad_from_length_in_bytes(MaxLength + 1); err == nil {
+		t.Fatal("pad of an input longer than 2^64 bits")
+	}
+	tail := make([]byte, 128)
+	for _, c := range []struct {
+		tailLength byte
+		fullLength uint64
+	}{
+		{10, 5},    // tail longer than the string
+		{10, 100},  // tail not starting at a block boundary
+		{120, 184}, // tail and pad over two blocks
+	} {
+		if _, err := SHA2_of_tail(tail, c.tailLength, c.fullLength, append([]uint32{}, H_CONST...)); err == nil {
+			t.Fatalf("tail of %d bytes of a string of %d accepted", c.tailLength, c.fullLength)
+		}
+	}
+
+	input := testInput(200)
+	if _, err := SHA2_of_prefix(input, 300, make([]byte, 64)); err == nil {
+		t.Fatal("prefix longer than the input accepted")
+	}
+	got, err := SHA2_of_prefix(input, 150, input[128:192])
+	if want := sha256.Sum256(input[:150]); err != nil || !bytes.Equal(got, want[:]) {
+		t.Fatalf("SHA2_of_prefix: got %x, %v, want %x", got, err, want)
+	}
+}
+
+func TestSumPrefixes(t *testing.T) {
+	input := testInput(1000)
+	d := New()
+	d.Write(input[:128])
+	c, err := d.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unordered, repeated, at the checkpoint and at block boundaries
+	lengths := []uint64{1000, 128, 500, 130, 500, 192, 999}
+	digests, err := SumPrefixes(c, input[128:], lengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, length := range lengths {
+		if want := sha256.Sum256(input[:length]); !bytes.Equal(digests[i], want[:]) {
+			t.Fatalf("prefix of %d bytes: got %x, want %x", length, digests[i], want)
+		}
+	}
+
+	for _, length := range []uint64{127, 1001} {
+		if _, err := SumPrefixes(c, input[128:], []uint64{length}); err == nil {
+			t.Fatalf("prefix of %d bytes accepted", length)
+		}
+	}
+}
+
+func TestPadSchedule(t *testing.T) {
+	block, words, ok := Pad_schedule(64)
+	if !ok || !equalWords(block, PAD_FOR_512) || !equalWords(words, WORDS_FOR_512_PAD) {
+		t.Fatal("the schedule for 64 bytes differs from WORDS_FOR_512_PAD")
+	}
+	for _, length := range []uint64{1, 55, 65, 119} {
+		if _, _, ok := Pad_schedule(length); ok {
+			t.Fatalf("%d bytes: the last block holds input bytes", length)
+		}
+	}
+	// the last block of every length with a pad-only block
+	for _, length := range []uint64{0, 56, 63, 128, 184, 70016} {
+		padded := i8to32(padded_sha_input(testInput(int(length))))
+		block, words, ok := Pad_schedule(length)
+		if !ok || !equalWords(block, padded[len(padded)-16:]) {
+			t.Fatalf("%d bytes: got block %x, want %x", length, block, padded[len(padded)-16:])
+		}
+		expanded := make([]uint32, 64)
+		copy(expanded, block)
+		expand_message_schedule(expanded)
+		if !equalWords(words, expanded) {
+			t.Fatalf("%d bytes: wrong schedule", length)
+		}
+	}
+}
+
+// SHA2, Digest and SHA2_of_tail compress a pad-only last block with its precomputed schedule, and any other
+// as the blocks before it: both give the digest of crypto/sha256 at every length of the last block
+func TestPadScheduleDigests(t *testing.T) {
+	lengths := []uint64{70016, 70040, 70072}
+	for length := uint64(0); length <= 200; length++ {
+		lengths = append(lengths, length)
+	}
+	for _, length := range lengths {
+		input := testInput(int(length))
+		want := sha256.Sum256(input)
+		d := New()
+		d.Write(input)
+		c := checkpoint(t, input, len(input)-len(input)%64)
+		tail := make([]byte, 128)
+		copy(tail, input[c.Length:])
+		ofTail, err := SHA2_of_tail(tail, byte(length-c.Length), length, c.H[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, got := range map[string][]byte{"SHA2": SHA2(input), "Digest": d.Sum(nil), "SHA2_of_tail": ofTail} {
+			if !bytes.Equal(got, want[:]) {
+				t.Fatalf("%s of %d bytes: got %x, want %x", name, length, got, want)
+			}
+		}
+	}
+}
+
+// The cache of Pad_schedule is emptied when it holds padScheduleCacheSize lengths
+func TestPadScheduleCacheBound(t *testing.T) {
+	for length := uint64(0); length < 3*padScheduleCacheSize*64; length += 64 {
+		Pad_schedule(length)
+		padSchedules.Lock()
+		n := len(padSchedules.words)
+		padSchedules.Unlock()
+		if n > padScheduleCacheSize {
+			t.Fatalf("%d schedules cached", n)
+		}
+	}
+	if _, words, _ := Pad_schedule(64); !equalWords(words, WORDS_FOR_512_PAD) {
+		t.Fatal("the schedule for 64 bytes changed after the cache was emptied")
+	}
+}
+
+func equalWords(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The saving of the precomputed schedule on a last block holding only the pad, as for the inner hash of
+// HMAC-SHA256 with a 64-byte key over a 56-byte message
+func BenchmarkPadBlock(b *testing.B) {
+	h := append([]uint32{}, H_CONST...)
+	b.Run("expanded", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			block, _, _ := Pad_schedule(120)
+			sha2_compression(block, h)
+		}
+	})
+	b.Run("precomputed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			block, words, _ := Pad_schedule(120)
+			compression_with_words(block, h, words)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package snark
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/algebra"
+	"github.com/consensys/gnark/std/algebra/native/sw_bls12377"
+	stdgroth16 "github.com/consensys/gnark/std/recursion/groth16"
+)
+
+// An aggregation circuit verifies K Groth16 proofs of the same inner circuit on BLS12-377 with gnark's
+// in-circuit verifier, and is itself proven with Groth16 on BW6-761, whose scalar field is the base field
+// of BLS12-377: the pairings are computed natively, for about 20k constraints per inner proof instead of
+// millions with field emulation. One aggregate proof then stands for a batch of connections.
+//
+// The inner verifying key is a constant of the circuit, so that an aggregate can only be made of proofs
+// of its setup, and the public inputs of the aggregate are those of the K inner proofs one after the other:
+// the verifier checks the aggregate against the public witnesses of the inner proofs (AggregatePublicWitness),
+// which it computes as it would to check them one by one.
+// The inner circuit must not use commitments, which the in-circuit verifier does not handle.
+
+const (
+	// InnerCurve is the curve of the aggregated proofs, and AggregationCurve the curve of the aggregate
+	InnerCurve       = ecc.BLS12_377
+	AggregationCurve = ecc.BW6_761
+)
+
+type (
+	innerProof        = stdgroth16.Proof[sw_bls12377.G1Affine, sw_bls12377.G2Affine]
+	innerWitness      = stdgroth16.Witness[sw_bls12377.Scalar]
+	innerVerifyingKey = stdgroth16.VerifyingKey[sw_bls12377.G1Affine, sw_bls12377.G2Affine, sw_bls12377.GT]
+)
+
+// aggregationCircuit verifies len(Proofs) proofs against vk
+type aggregationCircuit struct {
+	Proofs []innerProof
+	Public []innerWitness `gnark:",public"`
+
+	vk innerVerifyingKey `gnark:"-"`
+}
+
+func (circuit *aggregationCircuit) Define(api frontend.API) error {
+	curve, err := algebra.GetCurve[sw_bls12377.Scalar, sw_bls12377.G1Affine](api)
+	if err != nil {
+		return err
+	}
+	pairing, err := algebra.GetPairing[sw_bls12377.G1Affine, sw_bls12377.G2Affine, sw_bls12377.GT](api)
+	if err != nil {
+		return err
+	}
+	verifier := stdgroth16.NewVerifier(curve, pairing)
+	for i := range circuit.Proofs {
+		if err := verifier.AssertProof(circuit.vk, circuit.Proofs[i], circuit.Public[i]); err != nil {
+			return fmt.Errorf("proof %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// AggregateName is the name of the aggregation of k proofs of the circuit, which its artifacts are named after
+func AggregateName(circuit string, k int) string {
+	return fmt.Sprintf("%s-agg%d", circuit, k)
+}
+
+// checkInner returns an error unless inner is the header of a Groth16 verifying key on InnerCurve
+func checkInner(inner Header) error {
+	if inner.Backend != backend.GROTH16 || inner.Curve != InnerCurve {
+		return fmt.Errorf("only groth16 proofs on %s can be aggregated, got a %s", InnerCurve, inner)
+	}
+	return nil
+}
+
+// CompileAggregation compiles the circuit verifying k proofs of innerCCS against innerVK,
+// and returns it with the header of its artifacts, derived from the header of innerVK
+func CompileAggregation(innerCCS constraint.ConstraintSystem, innerVK VerifyingKey, inner Header, k int) (constraint.ConstraintSystem, Header, error) {
+	if err := checkInner(inner); err != nil {
+		return nil, Header{}, err
+	}
+	if k < 1 {
+		return nil, Header{}, fmt.Errorf("cannot aggregate %d proofs", k)
+	}
+	if commitments := innerCCS.GetCommitments(); commitments != nil && len(commitments.CommitmentIndexes()) != 0 {
+		return nil, Header{}, errors.New("proofs of circuits with commitments cannot be aggregated")
+	}
+	vk, err := stdgroth16.ValueOfVerifyingKey[sw_bls12377.G1Affine, sw_bls12377.G2Affine, sw_bls12377.GT](innerVK.(groth16.VerifyingKey))
+	if err != nil {
+		return nil, Header{}, err
+	}
+
+	circuit := &aggregationCircuit{
+		Proofs: make([]innerProof, k),
+		Public: make([]innerWitness, k),
+		vk:     vk,
+	}
+	for i := range circuit.Public {
+		circuit.Public[i] = stdgroth16.PlaceholderWitness[sw_bls12377.Scalar](innerCCS)
+	}
+	ccs, err := frontend.Compile(AggregationCurve.ScalarField(), r1cs.NewBuilder, circuit)
+	if err != nil {
+		return nil, Header{}, err
+	}
+
+	hash, err := CircuitHash(ccs)
+	if err != nil {
+		return nil, Header{}, err
+	}
+	h := Header{
+		Backend:      backend.GROTH16,
+		Curve:        AggregationCurve,
+		Circuit:      AggregateName(inner.Circuit, k),
+		CircuitHash:  hash,
+		InnerKeyHash: inner.KeyHash,
+	}
+	for i := 0; i < k; i++ {
+		for _, name := range inner.Public {
+			h.Public = append(h.Public, fmt.Sprintf("%d.%s", i, name))
+		}
+	}
+	return ccs, h, nil
+}
+
+// AggregateWitness returns the full witness of the aggregation of the proofs of the public witnesses,
+// made with the inner verifying key of h
+func AggregateWitness(h Header, proofs []Proof, publicWitnesses []witness.Witness) (witness.Witness, error) {
+	if len(proofs) != len(publicWitnesses) {
+		return nil, fmt.Errorf("got %d proofs for %d public witnesses", len(proofs), len(publicWitnesses))
+	}
+	assignment := &aggregationCircuit{Proofs: make([]innerProof, len(proofs))}
+	for i := range proofs {
+		var err error
+		if assignment.Proofs[i], err = stdgroth16.ValueOfProof[sw_bls12377.G1Affine, sw_bls12377.G2Affine](proofs[i].(groth16.Proof)); err != nil {
+			return nil, fmt.Errorf("proof %d: %w", i, err)
+		}
+	}
+	if err := assignPublic(assignment, h, publicWitnesses); err != nil {
+		return nil, err
+	}
+	return frontend.NewWitness(assignment, AggregationCurve.ScalarField())
+}
+
+// AggregatePublicWitness returns the public witness of an aggregate proof of the public witnesses of the
+// inner proofs, in the order they were aggregated in
+func AggregatePublicWitness(h Header, publicWitnesses []witness.Witness) (witness.Witness, error) {
+	assignment := &aggregationCircuit{}
+	if err := assignPublic(assignment, h, publicWitnesses); err != nil {
+		return nil, err
+	}
+	return frontend.NewWitness(assignment, AggregationCurve.ScalarField(), frontend.PublicOnly())
+}
+
+func assignPublic(assignment *aggregationCircuit, h Header, publicWitnesses []witness.Witness) error {
+	if len(publicWitnesses) == 0 || len(h.Public)%len(publicWitnesses) != 0 {
+		return fmt.Errorf("%s has %d public inputs, which do not split between %d public witnesses", h, len(h.Public), len(publicWitnesses))
+	}
+	assignment.Public = make([]innerWitness, len(publicWitnesses))
+	for i, w := range publicWitnesses {
+		var err error
+		if assignment.Public[i], err = stdgroth16.ValueOfWitness[sw_bls12377.Scalar, sw_bls12377.G1Affine](w); err != nil {
+			return fmt.Errorf("public witness %d: %w", i, err)
+		}
+		if n := len(assignment.Public[i].Public); n != len(h.Public)/len(publicWitnesses) {
+			return fmt.Errorf("public witness %d has %d inputs, expected %d", i, n, len(h.Public)/len(publicWitnesses))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package snark
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"anonpao/circuits"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+)
+
+// Groth16 needs a setup per circuit, while PLONK only needs a universal KZG SRS
+// that is shared by every circuit up to the size of the SRS.
+// The functions below hide the difference so that the zk command can treat both alike.
+
+// Backends lists the proof systems supported by the zk command
+var Backends = []backend.ID{backend.GROTH16, backend.PLONK}
+
+// ProvingKey, VerifyingKey and Proof are the common ground of the Groth16 and PLONK objects
+type ProvingKey interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+type VerifyingKey interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+type Proof interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// ParseBackend returns the backend with the given name, e.g. "groth16" or "plonk"
+func ParseBackend(name string) (backend.ID, error) {
+	for _, b := range Backends {
+		if b.String() == name {
+			return b, nil
+		}
+	}
+	return backend.UNKNOWN, fmt.Errorf("unsupported backend %q (supported: %v)", name, Backends)
+}
+
+func errUnsupported(b backend.ID) error {
+	return fmt.Errorf("unsupported backend %s", b)
+}
+
+// Compile compiles the registered circuit over the scalar field of curve,
+// into a R1CS for Groth16 or a SparseR1CS for PLONK
+func Compile(def circuits.Definition, b backend.ID, curve ecc.ID) (constraint.ConstraintSystem, error) {
+	if err := checkCurve(curve); err != nil {
+		return nil, err
+	}
+	switch b {
+	case backend.GROTH16:
+		return frontend.Compile(curve.ScalarField(), r1cs.NewBuilder, def.New())
+	case backend.PLONK:
+		return frontend.Compile(curve.ScalarField(), scs.NewBuilder, def.New())
+	}
+	return nil, errUnsupported(b)
+}
+
+// NewCS returns an empty constraint system of the type used by the backend on curve, ready to be read from disk
+func NewCS(b backend.ID, curve ecc.ID) constraint.ConstraintSystem {
+	switch b {
+	case backend.GROTH16:
+		return groth16.NewCS(curve)
+	case backend.PLONK:
+		return plonk.NewCS(curve)
+	}
+	panic(errUnsupported(b))
+}
+
+// NewProvingKey returns an empty proving key for the backend, ready to be read from disk
+func NewProvingKey(b backend.ID, curve ecc.ID) ProvingKey {
+	switch b {
+	case backend.GROTH16:
+		return groth16.NewProvingKey(curve)
+	case backend.PLONK:
+		return plonk.NewProvingKey(curve)
+	}
+	panic(errUnsupported(b))
+}
+
+// NewVerifyingKey returns an empty verifying key for the backend, ready to be read from disk
+func NewVerifyingKey(b backend.ID, curve ecc.ID) VerifyingKey {
+	switch b {
+	case backend.GROTH16:
+		return groth16.NewVerifyingKey(curve)
+	case backend.PLONK:
+		return plonk.NewVerifyingKey(curve)
+	}
+	panic(errUnsupported(b))
+}
+
+// NewProof returns an empty proof for the backend, ready to be read from disk
+func NewProof(b backend.ID, curve ecc.ID) Proof {
+	switch b {
+	case backend.GROTH16:
+		return groth16.NewProof(curve)
+	case backend.PLONK:
+		return plonk.NewProof(curve)
+	}
+	panic(errUnsupported(b))
+}
+
+// Setup generates the proving and verifying keys of ccs.
+// srs is only used by PLONK and may be nil for Groth16.
+func Setup(b backend.ID, ccs constraint.ConstraintSystem, srs kzg.SRS) (ProvingKey, VerifyingKey, error) {
+	switch b {
+	case backend.GROTH16:
+		return groth16.Setup(ccs)
+	case backend.PLONK:
+		if srs == nil {
+			return nil, nil, errors.New("plonk setup requires a KZG SRS")
+		}
+		return plonk.Setup(ccs, srs)
+	}
+	return nil, nil, errUnsupported(b)
+}
+
+// Prove computes a proof of the full witness
+func Prove(b backend.ID, ccs constraint.ConstraintSystem, pk ProvingKey, fullWitness witness.Witness) (Proof, error) {
+	switch b {
+	case backend.GROTH16:
+		return groth16.Prove(ccs, pk.(groth16.ProvingKey), fullWitness)
+	case backend.PLONK:
+		return plonk.Prove(ccs, pk.(plonk.ProvingKey), fullWitness)
+	}
+	return nil, errUnsupported(b)
+}
+
+// Verify checks the proof against the verifying key and the public witness
+func Verify(b backend.ID, proof Proof, vk VerifyingKey, publicWitness witness.Witness) error {
+	switch b {
+	case backend.GROTH16:
+		return groth16.Verify(proof.(groth16.Proof), vk.(groth16.VerifyingKey), publicWitness)
+	case backend.PLONK:
+		return plonk.Verify(proof.(plonk.Proof), vk.(plonk.VerifyingKey), publicWitness)
+	}
+	return errUnsupported(b)
+}
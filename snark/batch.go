@@ -0,0 +1,118 @@
+package snark
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/witness"
+)
+
+// A Groth16 proof (A, B, C) of public inputs x is valid when
+//
+//	e(A, B) = e(α, β) · e(L, γ) · e(C, δ)    with L = K₀ + Σᵢ xᵢ·Kᵢ₊₁
+//
+// Proofs against the same verifying key are checked together by raising the equation of proof j
+// to a random rⱼ and multiplying them, which only holds for all j at once except with negligible probability:
+//
+//	Πⱼ e(rⱼ·Aⱼ, Bⱼ) · e(-Σⱼ rⱼ·Lⱼ, γ) · e(-Σⱼ rⱼ·Cⱼ, δ) · e(-(Σⱼ rⱼ)·α, β) = 1
+//
+// That is N+3 Miller loops and a single final exponentiation for N proofs, instead of N full verifications.
+// When the batch fails, it is split in halves until the invalid proofs are isolated.
+
+//go:generate go run gen.go
+
+// groth16Batch is the curve specific part of the batch verification of Groth16 proofs, generated for each
+// curve from templates/batch.go.tmpl
+type groth16Batch interface {
+	// add appends a proof to the batch with its public witness, or returns why it cannot be valid:
+	// it is not a proof on the curve, its points are not in the subgroups or its public inputs do not fit the key
+	add(proof Proof, publicWitness witness.Witness) error
+	// check verifies the proofs at the given positions of the batch, in the order they were added, together
+	check(idx []int) (bool, error)
+}
+
+var newGroth16Batch = map[ecc.ID]func(vk groth16.VerifyingKey) (groth16Batch, error){
+	ecc.BN254:     newGroth16BatchBN254,
+	ecc.BLS12_381: newGroth16BatchBLS12381,
+	ecc.BLS12_377: newGroth16BatchBLS12377,
+	ecc.BW6_761:   newGroth16BatchBW6761,
+}
+
+// BatchVerify checks many proofs and their public witnesses against the same verifying key
+// and returns the indexes of the invalid ones, in increasing order.
+// Groth16 proofs are checked together with a random linear combination;
+// PLONK proofs are verified one after the other.
+// The error is only set when the inputs could not be checked at all.
+func BatchVerify(b backend.ID, vk VerifyingKey, proofs []Proof, publicWitnesses []witness.Witness) (invalid []int, err error) {
+	if len(proofs) != len(publicWitnesses) {
+		return nil, fmt.Errorf("got %d proofs for %d public witnesses", len(proofs), len(publicWitnesses))
+	}
+
+	switch b {
+	case backend.GROTH16:
+		gvk := vk.(groth16.VerifyingKey)
+		newBatch, ok := newGroth16Batch[gvk.CurveID()]
+		if !ok {
+			return nil, fmt.Errorf("batch verification is not implemented on %s", gvk.CurveID())
+		}
+		batch, err := newBatch(gvk)
+		if err != nil {
+			return nil, err
+		}
+		// a malformed proof is an invalid one, the others are checked without it
+		var added, positions []int
+		for i := range proofs {
+			if batch.add(proofs[i], publicWitnesses[i]) != nil {
+				invalid = append(invalid, i)
+				continue
+			}
+			positions = append(positions, len(added))
+			added = append(added, i)
+		}
+		failed, err := bisect(positions, batch.check)
+		if err != nil {
+			return nil, err
+		}
+		for _, j := range failed {
+			invalid = append(invalid, added[j])
+		}
+		sort.Ints(invalid)
+		return invalid, nil
+
+	case backend.PLONK:
+		for i := range proofs {
+			if Verify(b, proofs[i], vk, publicWitnesses[i]) != nil {
+				invalid = append(invalid, i)
+			}
+		}
+		return invalid, nil
+	}
+	return nil, errUnsupported(b)
+}
+
+// bisect returns the indexes that fail check, checking halves of idx only when idx fails as a whole
+func bisect(idx []int, check func(idx []int) (bool, error)) ([]int, error) {
+	if len(idx) == 0 {
+		return nil, nil
+	}
+	ok, err := check(idx)
+	if err != nil || ok {
+		return nil, err
+	}
+	if len(idx) == 1 {
+		return idx, nil
+	}
+
+	left, err := bisect(idx[:len(idx)/2], check)
+	if err != nil {
+		return nil, err
+	}
+	right, err := bisect(idx[len(idx)/2:], check)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
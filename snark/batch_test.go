@@ -0,0 +1,145 @@
+package snark_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"anonpao/circuits"
+	"anonpao/snark"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381"
+	"github.com/consensys/gnark-crypto/ecc/bls12-381/fp"
+	"github.com/consensys/gnark/backend"
+	groth16_bls12381 "github.com/consensys/gnark/backend/groth16/bls12-381"
+	"github.com/consensys/gnark/backend/witness"
+)
+
+// cubicProofs returns n Groth16 proofs of the cubic circuit on curve, for x = 1 to n, with their public witnesses
+func cubicProofs(t testing.TB, curve ecc.ID, n int) (snark.VerifyingKey, []snark.Proof, []witness.Witness) {
+	def, err := circuits.Lookup("cubic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ccs, err := snark.Compile(def, backend.GROTH16, curve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := snark.Setup(backend.GROTH16, ccs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proofs, publics := make([]snark.Proof, n), make([]witness.Witness, n)
+	for i := range proofs {
+		x := i + 1
+		full, err := snark.ParseWitness(def, []byte(fmt.Sprintf(`{"x": %d, "Y": %d}`, x, x*x*x+x+5)), curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proofs[i], err = snark.Prove(backend.GROTH16, ccs, pk, full); err != nil {
+			t.Fatal(err)
+		}
+		if publics[i], err = full.Public(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return vk, proofs, publics
+}
+
+// wrongPublic returns a public witness, y = 1, that none of the proofs of cubicProofs proves
+func wrongPublic(t *testing.T, curve ecc.ID) witness.Witness {
+	def, _ := circuits.Lookup("cubic")
+	w, err := snark.ParseWitness(def, []byte(`{"x": 0, "Y": 1}`), curve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := w.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public
+}
+
+func TestBatchVerify(t *testing.T) {
+	for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+		vk, proofs, publics := cubicProofs(t, curve, 8)
+		other := wrongPublic(t, curve)
+
+		for _, bad := range [][]int{nil, {2}, {0, 3, 7}, {0, 1, 2, 3, 4, 5, 6, 7}} {
+			ws := append([]witness.Witness{}, publics...)
+			for _, i := range bad {
+				ws[i] = other
+			}
+			invalid, err := snark.BatchVerify(backend.GROTH16, vk, proofs, ws)
+			if err != nil {
+				t.Fatalf("%s: %v", curve, err)
+			}
+			if len(invalid) != 0 || len(bad) != 0 {
+				if !reflect.DeepEqual(invalid, bad) {
+					t.Errorf("%s: invalid proofs %v, want %v", curve, invalid, bad)
+				}
+			}
+		}
+	}
+}
+
+// notInSubgroup returns a point of the bls12_381 curve outside of its prime order subgroup
+func notInSubgroup(t *testing.T) bls12381.G1Affine {
+	var four fp.Element
+	four.SetUint64(4)
+	for x := uint64(1); x < 1000; x++ {
+		// y² = x³ + 4
+		var p bls12381.G1Affine
+		var rhs fp.Element
+		p.X.SetUint64(x)
+		rhs.Square(&p.X).Mul(&rhs, &p.X).Add(&rhs, &four)
+		if p.Y.Sqrt(&rhs) == nil {
+			continue
+		}
+		if p.IsOnCurve() && !p.IsInSubGroup() {
+			return p
+		}
+	}
+	t.Fatal("no point found outside of the subgroup")
+	return bls12381.G1Affine{}
+}
+
+// A proof with a point outside of the subgroup is reported as invalid, the others are still checked
+func TestBatchVerifyNotInSubgroup(t *testing.T) {
+	vk, proofs, publics := cubicProofs(t, ecc.BLS12_381, 4)
+	p := *proofs[1].(*groth16_bls12381.Proof)
+	p.Ar = notInSubgroup(t)
+	proofs[1] = &p
+	publics[3] = wrongPublic(t, ecc.BLS12_381)
+
+	invalid, err := snark.BatchVerify(backend.GROTH16, vk, proofs, publics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 3}; !reflect.DeepEqual(invalid, want) {
+		t.Fatalf("invalid proofs %v, want %v", invalid, want)
+	}
+}
+
+func BenchmarkBatchVerify(b *testing.B) {
+	const n = 32
+	vk, proofs, publics := cubicProofs(b, ecc.BN254, n)
+	b.Run(fmt.Sprintf("batch of %d", n), func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if invalid, err := snark.BatchVerify(backend.GROTH16, vk, proofs, publics); err != nil || len(invalid) != 0 {
+				b.Fatal(invalid, err)
+			}
+		}
+	})
+	b.Run(fmt.Sprintf("%d one by one", n), func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range proofs {
+				if err := snark.Verify(backend.GROTH16, proofs[j], vk, publics[j]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
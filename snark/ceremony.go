@@ -0,0 +1,256 @@
+package snark
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/constraint"
+)
+
+// The Groth16 keys of setup come from secrets drawn by whoever runs it, who could forge proofs by keeping them.
+// A ceremony replaces them with contributions from many parties, and the keys are sound as long as one of them
+// forgot its randomness (https://eprint.iacr.org/2017/1050, with gnark's mpcsetup):
+//
+//	phase 1, powers of τ (any circuit of up to 2^power constraints):
+//	  InitPhase1 -> Contribute -> Contribute -> ...
+//	phase 2, for one circuit, from the last phase 1:
+//	  InitPhase2 -> Contribute -> Contribute -> ...
+//	Finalize verifies both chains and extracts the keys.
+//
+// Every contribution builds on the hash of the one before, so each party only has to check that the final
+// transcript contains the hash its contribution printed. The parameters of the initial states are recomputed
+// by the verifier rather than trusted (their public keys are random and differ from run to run),
+// and the SHA-256 of all the hashes in order is the transcript recorded in the keys.
+
+// Phase1 and Phase2 are the states of the two phases of a ceremony, after a contribution
+type Phase1 interface {
+	io.WriterTo
+	io.ReaderFrom
+	// Contribute draws fresh randomness, updates the state with it and forgets it
+	Contribute()
+	// ContributionHash is the hash of the state, which the next contribution builds on
+	ContributionHash() []byte
+
+	// verify checks that next is a valid contribution on top of this state
+	verify(next Phase1) error
+	// sameParameters reports whether both states have the same parameters, whatever their public keys
+	sameParameters(other Phase1) bool
+	// power is the log2 of the number of constraints supported
+	power() int
+}
+
+type Phase2 interface {
+	io.WriterTo
+	io.ReaderFrom
+	Contribute()
+	ContributionHash() []byte
+
+	verify(next Phase2) error
+	sameParameters(other Phase2) bool
+}
+
+// ceremonyCurve is the curve specific part of a ceremony, generated for each curve from templates/ceremony.go.tmpl
+type ceremonyCurve interface {
+	initPhase1(power int) Phase1
+	newPhase1() Phase1
+	newPhase2() Phase2
+	// initPhase2 returns the initial phase 2 of the R1CS on top of the last phase 1,
+	// and a function extracting the keys from the last phase 2
+	initPhase2(r1cs constraint.ConstraintSystem, last Phase1) (Phase2, func(last Phase2) (ProvingKey, VerifyingKey), error)
+}
+
+var ceremonies = map[ecc.ID]ceremonyCurve{
+	ecc.BN254:     mpcBN254{},
+	ecc.BLS12_381: mpcBLS12381{},
+	ecc.BLS12_377: mpcBLS12377{},
+	ecc.BW6_761:   mpcBW6761{},
+}
+
+func ceremonyOn(curve ecc.ID) (ceremonyCurve, error) {
+	c, ok := ceremonies[curve]
+	if !ok {
+		return nil, fmt.Errorf("ceremonies are not implemented on %s", curve)
+	}
+	return c, nil
+}
+
+// CeremonyPower returns the power of the phase 1 a ceremony for ccs needs: the keys are extracted
+// on a domain of 2^power points, which must be the smallest that holds all the constraints
+func CeremonyPower(ccs constraint.ConstraintSystem) int {
+	return bits.Len64(ecc.NextPowerOfTwo(uint64(ccs.GetNbConstraints()))) - 1
+}
+
+// InitPhase1 returns the initial state of phase 1 on curve, for circuits of 2^(power-1)+1 to 2^power constraints.
+// It holds no secret, anyone can recompute it.
+func InitPhase1(curve ecc.ID, power int) (Phase1, error) {
+	c, err := ceremonyOn(curve)
+	if err != nil {
+		return nil, err
+	}
+	if power < 0 || power > 28 {
+		return nil, fmt.Errorf("power %d out of range [0, 28]", power)
+	}
+	return c.initPhase1(power), nil
+}
+
+// VerifyPhase1 checks every contribution of a phase 1, chain[0] being its initial state
+func VerifyPhase1(curve ecc.ID, chain []Phase1) error {
+	if len(chain) < 2 {
+		return errors.New("phase 1 has no contribution")
+	}
+	init, err := InitPhase1(curve, chain[0].power())
+	if err != nil {
+		return err
+	}
+	if !init.sameParameters(chain[0]) {
+		return errors.New("phase 1 does not start from the initial state")
+	}
+	if err := checkHash(chain[0]); err != nil {
+		return fmt.Errorf("phase 1 initial state: %w", err)
+	}
+	prev := chain[0]
+	for i, next := range chain[1:] {
+		if err := prev.verify(next); err != nil {
+			return fmt.Errorf("phase 1 contribution %d: %w", i+1, err)
+		}
+		prev = next
+	}
+	return nil
+}
+
+// InitPhase2 returns the initial state of phase 2 for the R1CS ccs, on top of the last state of phase 1.
+// It holds no secret, anyone can recompute it.
+func InitPhase2(ccs constraint.ConstraintSystem, last Phase1) (Phase2, error) {
+	p2, _, err := initPhase2(ccs, last)
+	return p2, err
+}
+
+func initPhase2(ccs constraint.ConstraintSystem, last Phase1) (Phase2, func(Phase2) (ProvingKey, VerifyingKey), error) {
+	curve, err := curveOf(ccs)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := ceremonyOn(curve)
+	if err != nil {
+		return nil, nil, err
+	}
+	if power := CeremonyPower(ccs); last.power() != power {
+		return nil, nil, fmt.Errorf("phase 1 is for 2^%d constraints, the circuit needs exactly 2^%d", last.power(), power)
+	}
+	if commitments := ccs.GetCommitments(); commitments != nil && len(commitments.CommitmentIndexes()) != 0 {
+		return nil, nil, errors.New("ceremonies for circuits with commitments are not supported")
+	}
+	return c.initPhase2(ccs, last)
+}
+
+// Finalize checks both phases of the ceremony for the R1CS ccs, the first state of each being its initial state,
+// and returns the keys along with the transcript of the ceremony, see Transcript
+func Finalize(ccs constraint.ConstraintSystem, phase1 []Phase1, phase2 []Phase2) (ProvingKey, VerifyingKey, []byte, error) {
+	curve, err := curveOf(ccs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := VerifyPhase1(curve, phase1); err != nil {
+		return nil, nil, nil, err
+	}
+	if len(phase2) < 2 {
+		return nil, nil, nil, errors.New("phase 2 has no contribution")
+	}
+	init, keys, err := initPhase2(ccs, phase1[len(phase1)-1])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !init.sameParameters(phase2[0]) {
+		return nil, nil, nil, errors.New("phase 2 does not start from the initial state of this circuit and phase 1")
+	}
+	if err := checkHash(phase2[0]); err != nil {
+		return nil, nil, nil, fmt.Errorf("phase 2 initial state: %w", err)
+	}
+	prev := phase2[0]
+	for i, next := range phase2[1:] {
+		if err := prev.verify(next); err != nil {
+			return nil, nil, nil, fmt.Errorf("phase 2 contribution %d: %w", i+1, err)
+		}
+		prev = next
+	}
+
+	pk, vk := keys(phase2[len(phase2)-1])
+	return pk, vk, Transcript(phase1, phase2), nil
+}
+
+// checkHash returns an error unless the hash of the state is the SHA-256 of its encoding,
+// which mpcsetup writes followed by the hash. Contributions are checked by verify, initial states are not.
+func checkHash(p io.WriterTo) error {
+	var b bytes.Buffer
+	if _, err := p.WriteTo(&b); err != nil {
+		return err
+	}
+	if b.Len() < sha256.Size {
+		return errors.New("truncated state")
+	}
+	encoding, hash := b.Bytes()[:b.Len()-sha256.Size], b.Bytes()[b.Len()-sha256.Size:]
+	if h := sha256.Sum256(encoding); !bytes.Equal(h[:], hash) {
+		return errors.New("hash does not match the state")
+	}
+	return nil
+}
+
+// Transcript returns the SHA-256 of the number of states of each phase (8 bytes each)
+// followed by the hashes of all states in order
+func Transcript(phase1 []Phase1, phase2 []Phase2) []byte {
+	h := sha256.New()
+	var n [16]byte
+	binary.BigEndian.PutUint64(n[:8], uint64(len(phase1)))
+	binary.BigEndian.PutUint64(n[8:], uint64(len(phase2)))
+	h.Write(n[:])
+	for _, p := range phase1 {
+		h.Write(p.ContributionHash())
+	}
+	for _, p := range phase2 {
+		h.Write(p.ContributionHash())
+	}
+	return h.Sum(nil)
+}
+
+// WritePhase1 writes a state of phase 1 on curve to path
+func WritePhase1(path string, curve ecc.ID, p Phase1) error {
+	return writeArtifact(path, Header{Backend: backend.GROTH16, Curve: curve}, KindPhase1, p)
+}
+
+// WritePhase2 writes a state of phase 2 for the circuit h describes to path
+func WritePhase2(path string, h Header, p Phase2) error {
+	return writeArtifact(path, h, KindPhase2, p)
+}
+
+// ReadPhase1 reads a state of phase 1, on the curve recorded in the file
+func ReadPhase1(path string) (p Phase1, h Header, err error) {
+	h, err = readArtifact(path, KindPhase1, backend.GROTH16, func(h Header) (io.ReaderFrom, error) {
+		c, err := ceremonyOn(h.Curve)
+		if err != nil {
+			return nil, err
+		}
+		p = c.newPhase1()
+		return p, nil
+	})
+	return p, h, err
+}
+
+// ReadPhase2 reads a state of phase 2, on the curve recorded in the file
+func ReadPhase2(path string) (p Phase2, h Header, err error) {
+	h, err = readArtifact(path, KindPhase2, backend.GROTH16, func(h Header) (io.ReaderFrom, error) {
+		c, err := ceremonyOn(h.Curve)
+		if err != nil {
+			return nil, err
+		}
+		p = c.newPhase2()
+		return p, nil
+	})
+	return p, h, err
+}
@@ -0,0 +1,96 @@
+package snark
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/constraint"
+
+	kzg_bls12377 "github.com/consensys/gnark-crypto/ecc/bls12-377/kzg"
+	kzg_bls12381 "github.com/consensys/gnark-crypto/ecc/bls12-381/kzg"
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	kzg_bw6761 "github.com/consensys/gnark-crypto/ecc/bw6-761/kzg"
+)
+
+// BN254 is the default and the only curve with an EVM precompile.
+// BLS12-381 is used by verifiers on other chains, and BLS12-377/BW6-761 form the 2-chain used for recursion.
+
+// Curves lists the curves supported by the zk command
+var Curves = []ecc.ID{ecc.BN254, ecc.BLS12_381, ecc.BLS12_377, ecc.BW6_761}
+
+// DefaultCurve is the curve used when none is given
+const DefaultCurve = ecc.BN254
+
+// ParseCurve returns the curve with the given name, e.g. "bn254" or "bls12-381" (or "bls12_381")
+func ParseCurve(name string) (ecc.ID, error) {
+	name = strings.ReplaceAll(strings.ToLower(name), "-", "_")
+	for _, c := range Curves {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return ecc.UNKNOWN, fmt.Errorf("unsupported curve %q (supported: %v)", name, Curves)
+}
+
+func checkCurve(curve ecc.ID) error {
+	for _, c := range Curves {
+		if c == curve {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported curve id %d", uint16(curve))
+}
+
+// NewSRS generates a KZG SRS on the curve of ccs, large enough for it.
+//
+// /!\ the secret is drawn locally and discarded, so whoever runs this could have kept it:
+// this is fine for tests, but a deployment should use an SRS from a public ceremony.
+func NewSRS(ccs constraint.ConstraintSystem, curve ecc.ID) (kzg.SRS, error) {
+	size := ecc.NextPowerOfTwo(uint64(ccs.GetNbConstraints()+ccs.GetNbPublicVariables())) + 3
+
+	alpha, err := rand.Int(rand.Reader, curve.ScalarField())
+	if err != nil {
+		return nil, err
+	}
+	return newSRS(curve, size, alpha)
+}
+
+func newSRS(curve ecc.ID, size uint64, alpha *big.Int) (kzg.SRS, error) {
+	switch curve {
+	case ecc.BN254:
+		return kzg_bn254.NewSRS(size, alpha)
+	case ecc.BLS12_381:
+		return kzg_bls12381.NewSRS(size, alpha)
+	case ecc.BLS12_377:
+		return kzg_bls12377.NewSRS(size, alpha)
+	case ecc.BW6_761:
+		return kzg_bw6761.NewSRS(size, alpha)
+	}
+	return nil, checkCurve(curve)
+}
+
+// ReadSRS reads a KZG SRS on the given curve from disk
+func ReadSRS(path string, curve ecc.ID) (kzg.SRS, error) {
+	if err := checkCurve(curve); err != nil {
+		return nil, err
+	}
+	srs := kzg.NewSRS(curve)
+	if err := ReadFile(path, srs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return srs, nil
+}
+
+// curveOf returns the curve whose scalar field ccs is defined over
+func curveOf(ccs constraint.ConstraintSystem) (ecc.ID, error) {
+	for _, c := range Curves {
+		if c.ScalarField().Cmp(ccs.Field()) == 0 {
+			return c, nil
+		}
+	}
+	return ecc.UNKNOWN, fmt.Errorf("unsupported scalar field %s", ccs.Field())
+}
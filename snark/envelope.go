@@ -0,0 +1,378 @@
+package snark
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"anonpao/circuits"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// Every artifact (constraint system, keys, proofs and public witnesses) is stored in a self-describing envelope:
+//
+//	magic "gnka" (4 bytes) || format version (1 byte) || header length (4 bytes) || header (JSON)
+//	|| payload (gnark encoding) || payload length (8 bytes) || SHA-256 checksum of everything before it (32 bytes)
+//
+// The JSON header records what the payload is, which backend, curve and circuit produced it,
+// a hash of the circuit's constraint system, the names of its public inputs and, for keys and proofs,
+// a hash of the verifying key of the setup they belong to, e.g.
+//
+//	{"kind":"proof","backend":"groth16","curve":"bn254","circuit":"cubic","circuit_hash":"496d…","public":["Y"],"key_hash":"a1b2…"}
+//
+// Readers first check the checksum and the length, so a truncated or corrupted file is reported as such,
+// and then refuse any combination of artifacts that were not produced for the same circuit.
+// All integers are big endian.
+
+var envelopeMagic = []byte("gnka")
+
+const (
+	formatVersion = 1
+
+	prefixSize  = 4 + 1 + 4
+	trailerSize = 8 + sha256.Size
+
+	// headers are small, anything larger is a corrupted length
+	maxHeaderSize = 1 << 20
+)
+
+// Kind is the type of object stored in an artifact
+type Kind string
+
+const (
+	KindConstraintSystem Kind = "constraint-system"
+	KindProvingKey       Kind = "proving-key"
+	KindVerifyingKey     Kind = "verifying-key"
+	KindProof            Kind = "proof"
+	KindPublicWitness    Kind = "public-witness"
+
+	// the states of a Groth16 ceremony, see ceremony.go: phase 1 is shared by all circuits and records no circuit
+	KindPhase1 Kind = "mpc-phase1"
+	KindPhase2 Kind = "mpc-phase2"
+)
+
+// Header describes how an artifact was produced
+type Header struct {
+	Kind    Kind
+	Backend backend.ID
+	Curve   ecc.ID
+
+	// Circuit is the name the circuit was registered under
+	Circuit string
+
+	// CircuitHash is the SHA-256 of the serialised constraint system, see CircuitHash
+	CircuitHash []byte
+
+	// Public lists the names of the public inputs, in the order of the public witness
+	Public []string
+
+	// KeyHash is the SHA-256 of the verifying key the artifact belongs to, see KeyHash.
+	// It is set on keys and proofs only, and tells keys (and proofs made with them) from different setups apart.
+	KeyHash []byte
+
+	// Transcript is the hash of the ceremony the keys were extracted from, see Transcript.
+	// It is set on the keys of a ceremony, and on the proofs made with them, only.
+	Transcript []byte
+
+	// InnerKeyHash is the KeyHash of the verifying key an aggregation circuit verifies proofs against,
+	// see aggregate.go. It is set on the artifacts of aggregation circuits only.
+	InnerKeyHash []byte
+}
+
+// headerJSON is the encoding of a Header, with the IDs spelled out
+type headerJSON struct {
+	Kind         Kind     `json:"kind"`
+	Backend      string   `json:"backend"`
+	Curve        string   `json:"curve"`
+	Circuit      string   `json:"circuit"`
+	CircuitHash  string   `json:"circuit_hash"`
+	Public       []string `json:"public"`
+	KeyHash      string   `json:"key_hash,omitempty"`
+	Transcript   string   `json:"transcript,omitempty"`
+	InnerKeyHash string   `json:"inner_key_hash,omitempty"`
+}
+
+// NewHeader returns the header shared by all artifacts of a circuit compiled into ccs
+func NewHeader(def circuits.Definition, b backend.ID, curve ecc.ID, ccs constraint.ConstraintSystem) (Header, error) {
+	hash, err := CircuitHash(ccs)
+	if err != nil {
+		return Header{}, err
+	}
+	public, err := PublicInputs(def)
+	if err != nil {
+		return Header{}, err
+	}
+	return Header{
+		Backend:     b,
+		Curve:       curve,
+		Circuit:     def.Name,
+		CircuitHash: hash,
+		Public:      public,
+	}, nil
+}
+
+// CircuitHash returns the SHA-256 of the serialised constraint system.
+// gnark encodes constraint systems deterministically, so the same circuit compiled with the same backend
+// and curve always has the same hash, and any change to its constraints changes it.
+func CircuitHash(ccs constraint.ConstraintSystem) ([]byte, error) {
+	h := sha256.New()
+	if _, err := ccs.WriteTo(h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// KeyHash returns the SHA-256 of the serialised verifying key
+func KeyHash(vk VerifyingKey) ([]byte, error) {
+	h := sha256.New()
+	if _, err := vk.WriteTo(h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// PublicInputs returns the names of the public inputs of the circuit, in the order of the public witness
+func PublicInputs(def circuits.Definition) ([]string, error) {
+	public := []string{}
+	_, err := schema.Walk(def.New(), tVariable, func(leaf schema.LeafInfo, _ reflect.Value) error {
+		if leaf.Visibility == schema.Public {
+			public = append(public, leaf.FullName())
+		}
+		return nil
+	})
+	return public, err
+}
+
+func (h Header) String() string {
+	if h.Circuit == "" {
+		return fmt.Sprintf("%s (%s on %s)", h.Kind, h.Backend, h.Curve)
+	}
+	return fmt.Sprintf("%s of circuit %s (%s, %s on %s)", h.Kind, h.Circuit, shortHash(h.CircuitHash), h.Backend, h.Curve)
+}
+
+func shortHash(hash []byte) string {
+	if len(hash) > 4 {
+		hash = hash[:4]
+	}
+	return hex.EncodeToString(hash)
+}
+
+// check returns an error if the artifact is not of the expected kind or was not produced by the expected backend
+func (h Header) check(kind Kind, b backend.ID) error {
+	if h.Kind != kind {
+		return fmt.Errorf("expected a %s, got a %s", kind, h)
+	}
+	if h.Backend != b {
+		return fmt.Errorf("expected a %s produced by %s, got a %s", kind, b, h)
+	}
+	return nil
+}
+
+// CheckSame returns an error unless both artifacts were produced for the same circuit, backend and curve,
+// and by the same setup if both are tied to one, which is required of all the artifacts used together
+// by the prover or the verifier
+func (h Header) CheckSame(other Header) error {
+	if h.Backend != other.Backend || h.Curve != other.Curve ||
+		!bytes.Equal(h.CircuitHash, other.CircuitHash) || !equalNames(h.Public, other.Public) {
+		return fmt.Errorf("%s does not match %s", h, other)
+	}
+	if h.KeyHash != nil && other.KeyHash != nil && !bytes.Equal(h.KeyHash, other.KeyHash) {
+		return fmt.Errorf("%s and %s come from different setups (keys %s and %s)", h, other, shortHash(h.KeyHash), shortHash(other.KeyHash))
+	}
+	return nil
+}
+
+// CheckDefinition returns an error if the artifact was not produced for the registered circuit,
+// or if the circuit's public inputs have changed since
+func (h Header) CheckDefinition(def circuits.Definition) error {
+	if h.Circuit != def.Name {
+		return fmt.Errorf("%s, not of circuit %s", h, def.Name)
+	}
+	public, err := PublicInputs(def)
+	if err != nil {
+		return err
+	}
+	if !equalNames(h.Public, public) {
+		return fmt.Errorf("circuit %s has changed since setup: public inputs are now %v, the artifacts expect %v", def.Name, public, h.Public)
+	}
+	return nil
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (h Header) encode() ([]byte, error) {
+	return json.Marshal(h.toJSON())
+}
+
+func (h *Header) decode(data []byte) error {
+	var j headerJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return fmt.Errorf("invalid header: %w", err)
+	}
+	return h.fromJSON(j)
+}
+
+func (h Header) toJSON() headerJSON {
+	return headerJSON{
+		Kind:         h.Kind,
+		Backend:      h.Backend.String(),
+		Curve:        h.Curve.String(),
+		Circuit:      h.Circuit,
+		CircuitHash:  hex.EncodeToString(h.CircuitHash),
+		Public:       h.Public,
+		KeyHash:      hex.EncodeToString(h.KeyHash),
+		Transcript:   hex.EncodeToString(h.Transcript),
+		InnerKeyHash: hex.EncodeToString(h.InnerKeyHash),
+	}
+}
+
+func (h *Header) fromJSON(j headerJSON) error {
+	b, err := ParseBackend(j.Backend)
+	if err != nil {
+		return err
+	}
+	curve, err := ParseCurve(j.Curve)
+	if err != nil {
+		return err
+	}
+	hash, err := hex.DecodeString(j.CircuitHash)
+	if err != nil {
+		return fmt.Errorf("invalid circuit hash: %w", err)
+	}
+	var keyHash []byte
+	if j.KeyHash != "" {
+		if keyHash, err = hex.DecodeString(j.KeyHash); err != nil {
+			return fmt.Errorf("invalid key hash: %w", err)
+		}
+	}
+	var transcript []byte
+	if j.Transcript != "" {
+		if transcript, err = hex.DecodeString(j.Transcript); err != nil {
+			return fmt.Errorf("invalid transcript: %w", err)
+		}
+	}
+	var innerKeyHash []byte
+	if j.InnerKeyHash != "" {
+		if innerKeyHash, err = hex.DecodeString(j.InnerKeyHash); err != nil {
+			return fmt.Errorf("invalid inner key hash: %w", err)
+		}
+	}
+	*h = Header{
+		Kind:         j.Kind,
+		Backend:      b,
+		Curve:        curve,
+		Circuit:      j.Circuit,
+		CircuitHash:  hash,
+		Public:       j.Public,
+		KeyHash:      keyHash,
+		Transcript:   transcript,
+		InnerKeyHash: innerKeyHash,
+	}
+	return nil
+}
+
+// writeEnvelope writes h and the payload produced by writePayload to w
+func writeEnvelope(w io.Writer, h Header, writePayload func(io.Writer) (int64, error)) error {
+	header, err := h.encode()
+	if err != nil {
+		return err
+	}
+
+	checksum := sha256.New()
+	mw := io.MultiWriter(w, checksum)
+
+	prefix := make([]byte, prefixSize)
+	copy(prefix, envelopeMagic)
+	prefix[4] = formatVersion
+	binary.BigEndian.PutUint32(prefix[5:], uint32(len(header)))
+	if _, err := mw.Write(prefix); err != nil {
+		return err
+	}
+	if _, err := mw.Write(header); err != nil {
+		return err
+	}
+
+	n, err := writePayload(mw)
+	if err != nil {
+		return err
+	}
+
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(n))
+	if _, err := mw.Write(length[:]); err != nil {
+		return err
+	}
+	_, err = w.Write(checksum.Sum(nil))
+	return err
+}
+
+// readEnvelope validates the envelope of the size bytes in r, and returns its header and a reader over its payload
+func readEnvelope(r io.ReaderAt, size int64) (Header, *io.SectionReader, error) {
+	var h Header
+
+	if size < prefixSize+trailerSize {
+		return h, nil, errors.New("truncated artifact")
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := r.ReadAt(prefix, 0); err != nil {
+		return h, nil, err
+	}
+	if !bytes.Equal(prefix[:4], envelopeMagic) {
+		return h, nil, errors.New("not a zk artifact (bad magic), it may predate the artifact envelope")
+	}
+	if prefix[4] != formatVersion {
+		return h, nil, fmt.Errorf("artifact format version %d is not supported (expected %d)", prefix[4], formatVersion)
+	}
+	headerSize := int64(binary.BigEndian.Uint32(prefix[5:]))
+	if headerSize > maxHeaderSize || prefixSize+headerSize+trailerSize > size {
+		return h, nil, errors.New("truncated or corrupted artifact (bad header length)")
+	}
+
+	trailer := make([]byte, trailerSize)
+	if _, err := r.ReadAt(trailer, size-trailerSize); err != nil {
+		return h, nil, err
+	}
+	payloadOffset := prefixSize + headerSize
+	payloadSize := int64(binary.BigEndian.Uint64(trailer[:8]))
+	if payloadOffset+payloadSize+trailerSize != size {
+		return h, nil, errors.New("truncated or corrupted artifact (payload length does not match the file size)")
+	}
+
+	// everything but the checksum itself is covered by the checksum
+	checksum := sha256.New()
+	if _, err := io.Copy(checksum, io.NewSectionReader(r, 0, size-sha256.Size)); err != nil {
+		return h, nil, err
+	}
+	if !bytes.Equal(checksum.Sum(nil), trailer[8:]) {
+		return h, nil, errors.New("corrupted artifact (checksum mismatch)")
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, prefixSize); err != nil {
+		return h, nil, err
+	}
+	if err := h.decode(header); err != nil {
+		return h, nil, err
+	}
+	return h, io.NewSectionReader(r, payloadOffset, payloadSize), nil
+}
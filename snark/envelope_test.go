@@ -0,0 +1,129 @@
+package snark_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"anonpao/snark"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+)
+
+// reseal rewrites the checksum at the end of an artifact, so that an edit reaches the checks past it
+func reseal(data []byte) []byte {
+	sum := sha256.Sum256(data[:len(data)-sha256.Size])
+	copy(data[len(data)-sha256.Size:], sum[:])
+	return data
+}
+
+// Every check of the envelope is reached by an edit of a valid proof, read from a file with ReadProof
+// and from memory with DecodeProof
+func TestEnvelopeRejects(t *testing.T) {
+	dir := t.TempDir()
+	paths := pipeline(t, backend.GROTH16, ecc.BN254, dir)
+	valid, err := os.ReadFile(paths.Proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headerSize := int(binary.BigEndian.Uint32(valid[5:9]))
+	header := string(valid[9 : 9+headerSize])
+	if !strings.Contains(header, `"groth16"`) {
+		t.Fatalf("the header %s does not name the backend", header)
+	}
+
+	for _, c := range []struct {
+		name string
+		edit func(data []byte) []byte
+		want string
+	}{
+		{"an empty file", func(data []byte) []byte { return nil }, "truncated artifact"},
+		{"a file shorter than the prefix and trailer", func(data []byte) []byte { return data[:9+40-1] }, "truncated artifact"},
+		{"a bad magic", func(data []byte) []byte {
+			data[0] = 'G'
+			return data
+		}, "not a zk artifact (bad magic)"},
+		{"an unknown version", func(data []byte) []byte {
+			data[4] = 2
+			return data
+		}, "artifact format version 2 is not supported (expected 1)"},
+		{"a header length past the end of the file", func(data []byte) []byte {
+			binary.BigEndian.PutUint32(data[5:9], uint32(len(data)))
+			return data
+		}, "bad header length"},
+		{"a header length over the maximum", func(data []byte) []byte {
+			binary.BigEndian.PutUint32(data[5:9], 1<<20+1)
+			return append(data, make([]byte, 1<<20)...)
+		}, "bad header length"},
+		{"a payload length past the end of the file", func(data []byte) []byte {
+			at := len(data) - 40
+			binary.BigEndian.PutUint64(data[at:], binary.BigEndian.Uint64(data[at:])+1)
+			return data
+		}, "payload length does not match the file size"},
+		{"a byte cut from the payload", func(data []byte) []byte {
+			at := 9 + headerSize + 1
+			return append(data[:at], data[at+1:]...)
+		}, "payload length does not match the file size"},
+		{"a file cut at the trailer", func(data []byte) []byte { return data[:len(data)-20] }, "corrupted artifact"},
+		{"a flipped payload byte", func(data []byte) []byte {
+			data[9+headerSize] ^= 1
+			return data
+		}, "checksum mismatch"},
+		{"a flipped header byte", func(data []byte) []byte {
+			data[9] ^= 1
+			return data
+		}, "checksum mismatch"},
+		{"a flipped checksum byte", func(data []byte) []byte {
+			data[len(data)-1] ^= 1
+			return data
+		}, "checksum mismatch"},
+		{"a header that is not JSON", func(data []byte) []byte {
+			data[9] = '['
+			return reseal(data)
+		}, "invalid header"},
+		{"an unknown backend", func(data []byte) []byte {
+			copy(data[9+strings.Index(header, `"groth16"`):], `"groth17"`)
+			return reseal(data)
+		}, "groth17"},
+	} {
+		data := c.edit(append([]byte(nil), valid...))
+		edited := filepath.Join(dir, "edited.proof")
+		if err := os.WriteFile(edited, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := snark.ReadProof(edited, backend.GROTH16); err == nil {
+			t.Errorf("%s is accepted", c.name)
+		} else if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %q, want %q", c.name, err, c.want)
+		}
+		if _, _, err := snark.DecodeProof(data, backend.GROTH16); err == nil {
+			t.Errorf("%s is accepted by DecodeProof", c.name)
+		} else if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: DecodeProof: got error %q, want %q", c.name, err, c.want)
+		}
+	}
+
+	// a valid envelope of another kind, or from another backend
+	if _, _, err := snark.ReadVerifyingKey(paths.Proof, backend.GROTH16); err == nil ||
+		!strings.Contains(err.Error(), "expected a verifying-key, got a proof") {
+		t.Errorf("a proof read as a verifying key: %v", err)
+	}
+	if _, _, err := snark.ReadProof(paths.Proof, backend.PLONK); err == nil ||
+		!strings.Contains(err.Error(), "expected a proof produced by plonk, got a proof") {
+		t.Errorf("a Groth16 proof read as a PLONK proof: %v", err)
+	}
+	if _, _, err := snark.DecodeProof(valid, backend.PLONK); err == nil || !strings.Contains(err.Error(), "produced by plonk") {
+		t.Errorf("a Groth16 proof decoded as a PLONK proof: %v", err)
+	}
+	if _, _, err := snark.ReadProof(paths.Proof, backend.GROTH16); err != nil {
+		t.Errorf("the unedited proof is rejected: %v", err)
+	}
+	if !bytes.Equal(reseal(append([]byte(nil), valid...)), valid) {
+		t.Error("reseal changes a valid artifact")
+	}
+}
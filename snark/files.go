@@ -0,0 +1,213 @@
+package snark
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	gnarkio "github.com/consensys/gnark/io"
+)
+
+// WriteFile creates (or truncates) the file at path and writes obj to it
+func WriteFile(path string, obj io.WriterTo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = obj.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadFile reads obj from the file at path
+func ReadFile(path string, obj io.ReaderFrom) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = obj.ReadFrom(f)
+	return err
+}
+
+// writeArtifact writes obj to path in an envelope with the header h, see envelope.go.
+func writeArtifact(path string, h Header, kind Kind, obj io.WriterTo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encodeArtifact(f, h, kind, obj); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// encodeArtifact writes obj to w in an envelope with the header h.
+// Keys and proofs use their uncompressed encoding, which is larger on disk but faster to read back.
+func encodeArtifact(w io.Writer, h Header, kind Kind, obj io.WriterTo) error {
+	h.Kind = kind
+	return writeEnvelope(w, h, func(w io.Writer) (int64, error) {
+		if raw, ok := obj.(gnarkio.WriterRawTo); ok {
+			return raw.WriteRawTo(w)
+		}
+		return obj.WriteTo(w)
+	})
+}
+
+// WriteConstraintSystem writes the constraint system the header h describes to path
+func WriteConstraintSystem(path string, h Header, ccs constraint.ConstraintSystem) error {
+	return writeArtifact(path, h, KindConstraintSystem, ccs)
+}
+
+// WriteProvingKey writes a proving key of the circuit h describes to path
+func WriteProvingKey(path string, h Header, pk ProvingKey) error {
+	return writeArtifact(path, h, KindProvingKey, pk)
+}
+
+// WriteVerifyingKey writes a verifying key of the circuit h describes to path
+func WriteVerifyingKey(path string, h Header, vk VerifyingKey) error {
+	return writeArtifact(path, h, KindVerifyingKey, vk)
+}
+
+// WriteProof writes a proof for the circuit h describes to path
+func WriteProof(path string, h Header, proof Proof) error {
+	return writeArtifact(path, h, KindProof, proof)
+}
+
+// WritePublicWitness writes a public witness of the circuit h describes to path
+func WritePublicWitness(path string, h Header, w witness.Witness) error {
+	return writeArtifact(path, h, KindPublicWitness, w)
+}
+
+// EncodeProof writes a proof for the circuit h describes to w, in the format of the files written by WriteProof
+func EncodeProof(w io.Writer, h Header, proof Proof) error {
+	return encodeArtifact(w, h, KindProof, proof)
+}
+
+// EncodePublicWitness writes a public witness of the circuit h describes to w,
+// in the format of the files written by WritePublicWitness
+func EncodePublicWitness(w io.Writer, h Header, publicWitness witness.Witness) error {
+	return encodeArtifact(w, h, KindPublicWitness, publicWitness)
+}
+
+// DecodeProof reads a proof produced by the backend b from data, in the format written by WriteProof and EncodeProof,
+// or as JSON
+func DecodeProof(data []byte, b backend.ID) (proof Proof, h Header, err error) {
+	if isJSON(data) {
+		h, err = decodeJSON(data, KindProof, b, func(h Header) (io.ReaderFrom, error) {
+			proof = NewProof(b, h.Curve)
+			return proof, nil
+		})
+		return proof, h, err
+	}
+	h, payload, err := readEnvelope(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, h, err
+	}
+	if err := h.check(KindProof, b); err != nil {
+		return nil, h, err
+	}
+	proof = NewProof(b, h.Curve)
+	if _, err := proof.ReadFrom(payload); err != nil {
+		return nil, h, err
+	}
+	return proof, h, nil
+}
+
+// readArtifact validates the envelope of the file at path, checks that it holds a kind object produced by
+// the backend b, then decodes the payload into the object newObj returns for the curve recorded in the header.
+// Proofs, verifying keys and public witnesses may also be JSON artifacts, see json.go.
+func readArtifact(path string, kind Kind, b backend.ID, newObj func(h Header) (io.ReaderFrom, error)) (Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Header{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Header{}, err
+	}
+
+	start := make([]byte, 64)
+	n, _ := f.ReadAt(start, 0)
+	if isJSON(start[:n]) {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return Header{}, err
+		}
+		h, err := decodeJSON(data, kind, b, newObj)
+		if err != nil {
+			return h, fmt.Errorf("%s: %w", path, err)
+		}
+		return h, nil
+	}
+
+	h, payload, err := readEnvelope(f, info.Size())
+	if err != nil {
+		return h, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := h.check(kind, b); err != nil {
+		return h, fmt.Errorf("%s: %w", path, err)
+	}
+	obj, err := newObj(h)
+	if err != nil {
+		return h, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, err := obj.ReadFrom(payload); err != nil {
+		return h, fmt.Errorf("%s: %w", path, err)
+	}
+	return h, nil
+}
+
+// ReadConstraintSystem reads a constraint system produced by the backend b, on the curve recorded in the file
+func ReadConstraintSystem(path string, b backend.ID) (ccs constraint.ConstraintSystem, h Header, err error) {
+	h, err = readArtifact(path, KindConstraintSystem, b, func(h Header) (io.ReaderFrom, error) {
+		ccs = NewCS(b, h.Curve)
+		return ccs, nil
+	})
+	return ccs, h, err
+}
+
+// ReadProvingKey reads a proving key produced by the backend b, on the curve recorded in the file
+func ReadProvingKey(path string, b backend.ID) (pk ProvingKey, h Header, err error) {
+	h, err = readArtifact(path, KindProvingKey, b, func(h Header) (io.ReaderFrom, error) {
+		pk = NewProvingKey(b, h.Curve)
+		return pk, nil
+	})
+	return pk, h, err
+}
+
+// ReadVerifyingKey reads a verifying key produced by the backend b, on the curve recorded in the file
+func ReadVerifyingKey(path string, b backend.ID) (vk VerifyingKey, h Header, err error) {
+	h, err = readArtifact(path, KindVerifyingKey, b, func(h Header) (io.ReaderFrom, error) {
+		vk = NewVerifyingKey(b, h.Curve)
+		return vk, nil
+	})
+	return vk, h, err
+}
+
+// ReadProof reads a proof produced by the backend b, on the curve recorded in the file
+func ReadProof(path string, b backend.ID) (proof Proof, h Header, err error) {
+	h, err = readArtifact(path, KindProof, b, func(h Header) (io.ReaderFrom, error) {
+		proof = NewProof(b, h.Curve)
+		return proof, nil
+	})
+	return proof, h, err
+}
+
+// ReadPublicWitness reads a public witness written by a prover using the backend b,
+// over the scalar field of the curve recorded in the file
+func ReadPublicWitness(path string, b backend.ID) (w witness.Witness, h Header, err error) {
+	h, err = readArtifact(path, KindPublicWitness, b, func(h Header) (io.ReaderFrom, error) {
+		w, err = witness.New(h.Curve.ScalarField())
+		return w, err
+	})
+	return w, h, err
+}
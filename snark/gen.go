@@ -0,0 +1,55 @@
+//go:build ignore
+
+// gen.go writes the curve specific files of the package from the templates in templates/, one per curve:
+// run go generate after changing a template.
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// curve is what the templates are executed with
+type curve struct {
+	Type    string // suffix of the Go identifiers, e.g. BLS12381
+	Name    string // suffix of the import aliases, e.g. bls12381
+	Package string // directory of the curve in gnark and gnark-crypto, e.g. bls12-381
+	Curve   string // name of the curve in messages, as ecc.ID prints it, e.g. bls12_381
+}
+
+var curves = []curve{
+	{"BN254", "bn254", "bn254", "bn254"},
+	{"BLS12381", "bls12381", "bls12-381", "bls12_381"},
+	{"BLS12377", "bls12377", "bls12-377", "bls12_377"},
+	{"BW6761", "bw6761", "bw6-761", "bw6_761"},
+}
+
+func main() {
+	templates, err := filepath.Glob("templates/*.go.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, path := range templates {
+		tmpl := template.Must(template.ParseFiles(path))
+		prefix := strings.TrimSuffix(filepath.Base(path), ".go.tmpl")
+		for _, c := range curves {
+			var b bytes.Buffer
+			b.WriteString("// Code generated by gen.go from " + filepath.ToSlash(path) + ". DO NOT EDIT.\n\n")
+			if err := tmpl.Execute(&b, c); err != nil {
+				log.Fatal(err)
+			}
+			src, err := format.Source(b.Bytes())
+			if err != nil {
+				log.Fatalf("%s on %s: %v", path, c.Curve, err)
+			}
+			if err := os.WriteFile(prefix+"_"+c.Name+".go", src, 0o644); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,448 @@
+package snark
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/witness"
+	gnarkio "github.com/consensys/gnark/io"
+)
+
+// Proofs, verifying keys and public witnesses can also be stored as JSON, for verifiers written in other languages
+// and for dashboards. A JSON artifact is the header of the envelope (see envelope.go) with the object under "value":
+//
+//	{"kind":"proof","backend":"groth16","curve":"bn254","circuit":"cubic",…,"value":{"Ar":{"X":"0x2a…","Y":"0x1f…"},"Krs":…,"Bs":{"X":{"A0":"0x…","A1":"0x…"},…},…}}
+//	{"kind":"public-witness",…,"public":["Y"],"value":{"Y":"35"}}
+//
+// Proofs and keys are the gnark structures of their backend and curve, keyed by their exported Go field names:
+// field elements (coordinates and scalars) are strings, in decimal or 0x-prefixed hex (the writer chooses,
+// readers accept both), elements of extension fields are objects {"A0", "A1"}, and integers are numbers.
+// Structures without exported fields, such as the commitment key of a groth16 verifying key,
+// are strings holding the hex of their gnark encoding.
+// Public witnesses map the names of the public inputs to their values.
+//
+// Readers tell JSON from the binary envelope by its first byte, so every command takes either.
+// JSON has no checksum: the points are checked to be on the curve and in the right subgroup when read,
+// and a verifying key must match the key hash of its header.
+
+// JSONFormat is the encoding of the field elements of JSON artifacts
+type JSONFormat int
+
+const (
+	Decimal JSONFormat = iota
+	Hex
+)
+
+// artifactJSON is the JSON encoding of an artifact
+type artifactJSON struct {
+	headerJSON
+	Value json.RawMessage `json:"value"`
+}
+
+// WriteProofJSON writes a proof for the circuit h describes to path, as JSON
+func WriteProofJSON(path string, h Header, proof Proof, f JSONFormat) error {
+	return writeJSON(path, h, KindProof, proof, f)
+}
+
+// WriteVerifyingKeyJSON writes a verifying key of the circuit h describes to path, as JSON
+func WriteVerifyingKeyJSON(path string, h Header, vk VerifyingKey, f JSONFormat) error {
+	return writeJSON(path, h, KindVerifyingKey, vk, f)
+}
+
+// WritePublicWitnessJSON writes a public witness of the circuit h describes to path, as JSON
+func WritePublicWitnessJSON(path string, h Header, w witness.Witness, f JSONFormat) error {
+	return writeJSON(path, h, KindPublicWitness, w, f)
+}
+
+func writeJSON(path string, h Header, kind Kind, obj io.WriterTo, f JSONFormat) error {
+	data, err := encodeJSON(h, kind, obj, f)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// encodeJSON returns the JSON artifact of obj. The object is decoded back and compared with the original,
+// so that objects with state the JSON encoding misses are refused rather than silently altered.
+func encodeJSON(h Header, kind Kind, obj io.WriterTo, f JSONFormat) ([]byte, error) {
+	h.Kind = kind
+	var value bytes.Buffer
+	if w, ok := obj.(witness.Witness); ok {
+		if err := writeWitnessJSON(&value, h.Public, w, f); err != nil {
+			return nil, err
+		}
+	} else if err := writeValueJSON(&value, reflect.ValueOf(obj), f); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(artifactJSON{h.toJSON(), value.Bytes()}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if kind != KindPublicWitness {
+		var back io.ReaderFrom
+		newBack := func(Header) (io.ReaderFrom, error) {
+			back = reflect.New(reflect.TypeOf(obj).Elem()).Interface().(io.ReaderFrom)
+			return back, nil
+		}
+		if _, err := decodeJSON(data, kind, h.Backend, newBack); err != nil {
+			return nil, fmt.Errorf("%s has no JSON encoding: %w", h, err)
+		}
+		if !sameEncoding(obj, back.(io.WriterTo)) {
+			return nil, fmt.Errorf("%s has no lossless JSON encoding", h)
+		}
+	}
+	return append(data, '\n'), nil
+}
+
+func sameEncoding(a, b io.WriterTo) bool {
+	var ba, bb bytes.Buffer
+	if _, err := a.WriteTo(&ba); err != nil {
+		return false
+	}
+	if _, err := b.WriteTo(&bb); err != nil {
+		return false
+	}
+	return bytes.Equal(ba.Bytes(), bb.Bytes())
+}
+
+// isJSON reports whether data starts like a JSON artifact rather than an envelope
+func isJSON(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '{'
+}
+
+// decodeJSON checks that the JSON artifact in data holds a kind object produced by the backend b,
+// then decodes its value into the object newObj returns for the curve recorded in the header
+func decodeJSON(data []byte, kind Kind, b backend.ID, newObj func(h Header) (io.ReaderFrom, error)) (Header, error) {
+	var h Header
+	var a artifactJSON
+	if err := json.Unmarshal(data, &a); err != nil {
+		return h, fmt.Errorf("invalid JSON artifact: %w", err)
+	}
+	if err := h.fromJSON(a.headerJSON); err != nil {
+		return h, err
+	}
+	if err := h.check(kind, b); err != nil {
+		return h, err
+	}
+	if a.Value == nil {
+		return h, errors.New("JSON artifact without value")
+	}
+
+	switch kind {
+	case KindPublicWitness:
+		obj, err := newObj(h)
+		if err != nil {
+			return h, err
+		}
+		return h, readWitnessJSON(a.Value, h, obj.(witness.Witness))
+
+	case KindProof, KindVerifyingKey:
+		// the value is decoded into a first object, which is encoded and read back into the returned one:
+		// reading checks the points, and computes what keys do not store.
+		// The uncompressed encoding is used, as compressing would recompute the y coordinates rather than check them.
+		obj, err := newObj(h)
+		if err != nil {
+			return h, err
+		}
+		v := reflect.ValueOf(obj)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			return h, fmt.Errorf("%T has no JSON encoding", obj)
+		}
+		if err := readValueJSON(a.Value, v.Elem(), "value"); err != nil {
+			return h, err
+		}
+		var buf bytes.Buffer
+		if raw, ok := obj.(gnarkio.WriterRawTo); ok {
+			_, err = raw.WriteRawTo(&buf)
+		} else {
+			_, err = obj.(io.WriterTo).WriteTo(&buf)
+		}
+		if err != nil {
+			return h, err
+		}
+		if obj, err = newObj(h); err != nil {
+			return h, err
+		}
+		if _, err := obj.ReadFrom(&buf); err != nil {
+			return h, err
+		}
+
+		if kind == KindVerifyingKey && h.KeyHash != nil {
+			hash, err := KeyHash(obj.(VerifyingKey))
+			if err != nil {
+				return h, err
+			}
+			if !bytes.Equal(hash, h.KeyHash) {
+				return h, fmt.Errorf("verifying key does not match its key hash %s", shortHash(h.KeyHash))
+			}
+		}
+		return h, nil
+	}
+	return h, fmt.Errorf("a %s has no JSON encoding", kind)
+}
+
+// isElement reports whether t is a field element of gnark-crypto, which converts to and from big.Int
+func isElement(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	_, hasGet := pt.MethodByName("BigInt")
+	_, hasSet := pt.MethodByName("SetBigInt")
+	return hasGet && hasSet
+}
+
+// isOpaque reports whether t is a structure without exported fields that gnark can encode, see json.go
+func isOpaque(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return false
+		}
+	}
+	pt := reflect.PtrTo(t)
+	return pt.Implements(reflect.TypeOf((*io.WriterTo)(nil)).Elem()) && pt.Implements(reflect.TypeOf((*io.ReaderFrom)(nil)).Elem())
+}
+
+func formatInt(n *big.Int, f JSONFormat) string {
+	if f == Hex {
+		return "0x" + n.Text(16)
+	}
+	return n.Text(10)
+}
+
+// parseIntString reads a decimal or 0x-prefixed hex string
+func parseIntString(s string) (*big.Int, bool) {
+	if digits := strings.TrimPrefix(s, "0x"); digits != s {
+		return new(big.Int).SetString(digits, 16)
+	}
+	return new(big.Int).SetString(s, 10)
+}
+
+// writeValueJSON writes v, which must be addressable or a pointer, in the order of its fields
+func writeValueJSON(buf *bytes.Buffer, v reflect.Value, f JSONFormat) error {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	switch {
+	case isElement(v.Type()):
+		n := new(big.Int)
+		v.Addr().MethodByName("BigInt").Call([]reflect.Value{reflect.ValueOf(n)})
+		buf.WriteString(strconv.Quote(formatInt(n, f)))
+		return nil
+
+	case isOpaque(v.Type()):
+		var b bytes.Buffer
+		if _, err := v.Addr().Interface().(io.WriterTo).WriteTo(&b); err != nil {
+			return err
+		}
+		buf.WriteString(strconv.Quote("0x" + hex.EncodeToString(b.Bytes())))
+		return nil
+
+	case v.Kind() == reflect.Struct:
+		buf.WriteByte('{')
+		first := true
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			buf.WriteString(strconv.Quote(field.Name))
+			buf.WriteByte(':')
+			if err := writeValueJSON(buf, v.Field(i), f); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+
+	case v.Kind() == reflect.Array || v.Kind() == reflect.Slice:
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeValueJSON(buf, v.Index(i), f); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+
+	case v.CanInt():
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+		return nil
+	case v.CanUint():
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+		return nil
+	case v.Kind() == reflect.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+		return nil
+	}
+	return fmt.Errorf("%s has no JSON encoding", v.Type())
+}
+
+// readValueJSON decodes raw into v, which is addressable and reachable through path.
+// Every exported field must be given, and nothing else.
+func readValueJSON(raw json.RawMessage, v reflect.Value, path string) error {
+	switch {
+	case isElement(v.Type()):
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("%s: expected a decimal or hex string", path)
+		}
+		n, ok := parseIntString(s)
+		if !ok || n.Sign() < 0 {
+			return fmt.Errorf("%s: invalid field element %q", path, s)
+		}
+		// SetBigInt reduces modulo the field, which is refused rather than done silently
+		v.Addr().MethodByName("SetBigInt").Call([]reflect.Value{reflect.ValueOf(n)})
+		back := new(big.Int)
+		v.Addr().MethodByName("BigInt").Call([]reflect.Value{reflect.ValueOf(back)})
+		if back.Cmp(n) != 0 {
+			return fmt.Errorf("%s: %s is not smaller than the field modulus", path, s)
+		}
+		return nil
+
+	case isOpaque(v.Type()):
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("%s: expected a hex string", path)
+		}
+		b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if _, err := v.Addr().Interface().(io.ReaderFrom).ReadFrom(bytes.NewReader(b)); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+
+	case v.Kind() == reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			value, ok := fields[field.Name]
+			if !ok {
+				return fmt.Errorf("%s: missing %s", path, field.Name)
+			}
+			if err := readValueJSON(value, v.Field(i), path+"."+field.Name); err != nil {
+				return err
+			}
+			delete(fields, field.Name)
+		}
+		for name := range fields {
+			return fmt.Errorf("%s: unknown field %s", path, name)
+		}
+		return nil
+
+	case v.Kind() == reflect.Array || v.Kind() == reflect.Slice:
+		var values []json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return fmt.Errorf("%s: expected an array", path)
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(values), len(values)))
+		} else if len(values) != v.Len() {
+			return fmt.Errorf("%s: got %d elements, expected %d", path, len(values), v.Len())
+		}
+		for i := range values {
+			if err := readValueJSON(values[i], v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case v.CanInt():
+		var n int64
+		if err := json.Unmarshal(raw, &n); err != nil || v.OverflowInt(n) {
+			return fmt.Errorf("%s: expected an integer", path)
+		}
+		v.SetInt(n)
+		return nil
+	case v.CanUint():
+		var n uint64
+		if err := json.Unmarshal(raw, &n); err != nil || v.OverflowUint(n) {
+			return fmt.Errorf("%s: expected a non-negative integer", path)
+		}
+		v.SetUint(n)
+		return nil
+	case v.Kind() == reflect.Bool:
+		var b bool
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return fmt.Errorf("%s: expected a boolean", path)
+		}
+		v.SetBool(b)
+		return nil
+	}
+	return fmt.Errorf("%s: %s has no JSON encoding", path, v.Type())
+}
+
+// writeWitnessJSON writes the values of a public witness as an object keyed by the names of the public inputs
+func writeWitnessJSON(buf *bytes.Buffer, public []string, w witness.Witness, f JSONFormat) error {
+	values := reflect.ValueOf(w.Vector())
+	if values.Kind() != reflect.Slice || values.Len() != len(public) {
+		return fmt.Errorf("public witness has %d values for %d public inputs", values.Len(), len(public))
+	}
+	buf.WriteByte('{')
+	for i, name := range public {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.Quote(name))
+		buf.WriteByte(':')
+		if err := writeValueJSON(buf, values.Index(i), f); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// readWitnessJSON fills w with the values of the public inputs named in h, in their order
+func readWitnessJSON(raw json.RawMessage, h Header, w witness.Witness) error {
+	var named map[string]string
+	if err := json.Unmarshal(raw, &named); err != nil {
+		return errors.New("value: expected an object of decimal or hex strings")
+	}
+	modulus := h.Curve.ScalarField()
+	values := make(chan any, len(h.Public))
+	for _, name := range h.Public {
+		s, ok := named[name]
+		if !ok {
+			return fmt.Errorf("value: missing public input %s", name)
+		}
+		n, ok := parseIntString(s)
+		if !ok || n.Sign() < 0 || n.Cmp(modulus) >= 0 {
+			return fmt.Errorf("value: %s: invalid field element %q", name, s)
+		}
+		values <- n
+		delete(named, name)
+	}
+	close(values)
+	for name := range named {
+		return fmt.Errorf("value: unknown public input %s", name)
+	}
+	return w.Fill(len(h.Public), 0, values)
+}
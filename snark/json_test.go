@@ -0,0 +1,205 @@
+package snark_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"anonpao/snark"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fp"
+	"github.com/consensys/gnark/backend"
+)
+
+// encoding returns the gnark encoding of obj, to compare objects read back with the originals
+func encoding(t *testing.T, obj io.WriterTo) []byte {
+	var buf bytes.Buffer
+	if _, err := obj.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// The proof, verifying key and public witness of the pipeline are written as JSON in both formats,
+// and read back identical to the binary artifacts
+func TestJSONRoundTrip(t *testing.T) {
+	for _, b := range []backend.ID{backend.GROTH16, backend.PLONK} {
+		for _, curve := range []ecc.ID{ecc.BN254, ecc.BLS12_381} {
+			dir := t.TempDir()
+			paths := pipeline(t, b, curve, dir)
+			vk, h, err := snark.ReadVerifyingKey(paths.VerifyingKey, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proof, _, err := snark.ReadProof(paths.Proof, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			public, _, err := snark.ReadPublicWitness(paths.PublicWitness, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, f := range []snark.JSONFormat{snark.Decimal, snark.Hex} {
+				vkPath, proofPath, publicPath := filepath.Join(dir, "vk.json"), filepath.Join(dir, "proof.json"), filepath.Join(dir, "public.json")
+				if err := snark.WriteVerifyingKeyJSON(vkPath, h, vk, f); err != nil {
+					t.Fatalf("%s on %s: %v", b, curve, err)
+				}
+				if err := snark.WriteProofJSON(proofPath, h, proof, f); err != nil {
+					t.Fatalf("%s on %s: %v", b, curve, err)
+				}
+				if err := snark.WritePublicWitnessJSON(publicPath, h, public, f); err != nil {
+					t.Fatalf("%s on %s: %v", b, curve, err)
+				}
+				if data, _ := os.ReadFile(proofPath); strings.Contains(string(data), `"0x`) != (f == snark.Hex) {
+					t.Errorf("%s on %s: format %d not followed", b, curve, f)
+				}
+
+				vkBack, vkHeader, err := snark.ReadVerifyingKey(vkPath, b)
+				if err != nil {
+					t.Fatalf("%s on %s: %v", b, curve, err)
+				}
+				proofBack, _, err := snark.ReadProof(proofPath, b)
+				if err != nil {
+					t.Fatalf("%s on %s: %v", b, curve, err)
+				}
+				publicBack, _, err := snark.ReadPublicWitness(publicPath, b)
+				if err != nil {
+					t.Fatalf("%s on %s: %v", b, curve, err)
+				}
+				if err := h.CheckSame(vkHeader); err != nil {
+					t.Errorf("%s on %s: %v", b, curve, err)
+				}
+				if !bytes.Equal(encoding(t, vkBack), encoding(t, vk)) {
+					t.Errorf("%s on %s: the verifying key changed through JSON", b, curve)
+				}
+				if !bytes.Equal(encoding(t, proofBack), encoding(t, proof)) {
+					t.Errorf("%s on %s: the proof changed through JSON", b, curve)
+				}
+				if !bytes.Equal(encoding(t, publicBack), encoding(t, public)) {
+					t.Errorf("%s on %s: the public witness changed through JSON", b, curve)
+				}
+				if err := snark.Verify(b, proofBack, vkBack, publicBack); err != nil {
+					t.Errorf("%s on %s: %v", b, curve, err)
+				}
+			}
+		}
+	}
+}
+
+// editJSON writes the JSON artifact in path to edited, after edit changed it
+func editJSON(t *testing.T, path, edited string, edit func(a map[string]any)) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a map[string]any
+	if err := json.Unmarshal(data, &a); err != nil {
+		t.Fatal(err)
+	}
+	edit(a)
+	if data, err = json.Marshal(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(edited, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// object returns the object at the path of keys in a
+func object(a map[string]any, keys ...string) map[string]any {
+	for _, k := range keys {
+		a = a[k].(map[string]any)
+	}
+	return a
+}
+
+func TestJSONRejects(t *testing.T) {
+	dir := t.TempDir()
+	paths := pipeline(t, backend.GROTH16, ecc.BN254, dir)
+	vk, h, err := snark.ReadVerifyingKey(paths.VerifyingKey, backend.GROTH16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, _, err := snark.ReadProof(paths.Proof, backend.GROTH16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, _, err := snark.ReadPublicWitness(paths.PublicWitness, backend.GROTH16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vkPath, proofPath, publicPath := filepath.Join(dir, "vk.json"), filepath.Join(dir, "proof.json"), filepath.Join(dir, "public.json")
+	if err := snark.WriteVerifyingKeyJSON(vkPath, h, vk, snark.Decimal); err != nil {
+		t.Fatal(err)
+	}
+	if err := snark.WriteProofJSON(proofPath, h, proof, snark.Decimal); err != nil {
+		t.Fatal(err)
+	}
+	if err := snark.WritePublicWitnessJSON(publicPath, h, public, snark.Decimal); err != nil {
+		t.Fatal(err)
+	}
+
+	modulus := fp.Modulus()
+	read := map[string]func(path string) error{
+		vkPath: func(path string) error {
+			_, _, err := snark.ReadVerifyingKey(path, backend.GROTH16)
+			return err
+		},
+		proofPath: func(path string) error {
+			_, _, err := snark.ReadProof(path, backend.GROTH16)
+			return err
+		},
+		publicPath: func(path string) error {
+			_, _, err := snark.ReadPublicWitness(path, backend.GROTH16)
+			return err
+		},
+	}
+	for _, c := range []struct {
+		name, path string
+		edit       func(a map[string]any)
+		want       string
+	}{
+		{"an unknown field", proofPath, func(a map[string]any) { object(a, "value")["Cs"] = "1" }, "unknown field Cs"},
+		{"a missing field", proofPath, func(a map[string]any) { delete(object(a, "value"), "Krs") }, "missing Krs"},
+		{"the modulus", proofPath, func(a map[string]any) { object(a, "value", "Ar")["X"] = modulus.String() },
+			"not smaller than the field modulus"},
+		{"the modulus in hex", proofPath, func(a map[string]any) { object(a, "value", "Ar")["X"] = "0x" + modulus.Text(16) },
+			"not smaller than the field modulus"},
+		{"a point off the curve", proofPath, func(a map[string]any) { object(a, "value", "Ar")["X"] = "1" }, ""},
+		{"an element that is not a number", proofPath, func(a map[string]any) { object(a, "value", "Ar")["X"] = "x" },
+			"invalid field element"},
+		{"another key hash", vkPath, func(a map[string]any) { a["key_hash"] = strings.Repeat("00", 32) },
+			"does not match its key hash"},
+		{"another alpha", vkPath, func(a map[string]any) {
+			g1 := object(a, "value", "G1")
+			g1["Alpha"] = g1["Delta"]
+		}, "does not match its key hash"},
+		{"an unknown public input", publicPath, func(a map[string]any) { object(a, "value")["Z"] = "1" }, "unknown public input Z"},
+		{"a missing public input", publicPath, func(a map[string]any) { delete(object(a, "value"), "Y") }, "missing public input Y"},
+		{"a public input equal to the modulus", publicPath,
+			func(a map[string]any) { object(a, "value")["Y"] = ecc.BN254.ScalarField().String() }, "invalid field element"},
+	} {
+		edited := filepath.Join(dir, "edited.json")
+		editJSON(t, c.path, edited, c.edit)
+		err := read[c.path](edited)
+		if err == nil {
+			t.Errorf("%s is accepted", c.name)
+		} else if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %q, want %q", c.name, err, c.want)
+		}
+	}
+
+	// the unchanged artifacts are read back after going through editJSON
+	for path, r := range read {
+		editJSON(t, path, filepath.Join(dir, "edited.json"), func(map[string]any) {})
+		if err := r(filepath.Join(dir, "edited.json")); err != nil {
+			t.Errorf("%s: %v", filepath.Base(path), err)
+		}
+	}
+}
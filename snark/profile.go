@@ -0,0 +1,90 @@
+package snark
+
+import (
+	"fmt"
+	"os"
+
+	"anonpao/circuits"
+	"anonpao/gadgets"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/profile"
+	pprof "github.com/google/pprof/profile"
+)
+
+// Report is the size of a compiled circuit and where its constraints come from, see Profile
+type Report struct {
+	Constraints int
+	// Public and Secret are the number of public and secret inputs
+	Public, Secret int
+
+	// Gadgets holds the number of constraints recorded in each gadget of gadgets.Profiled, in the same order.
+	// Nested gadgets count in both, so the counts may add up to more than Recorded.
+	Gadgets []int
+	// Other is the number of constraints recorded outside of all gadgets
+	Other int
+	// Recorded is the number of constraints seen by the profiler. It can differ slightly from Constraints,
+	// which are counted after the builder of the backend is done.
+	Recorded int
+}
+
+// Profile compiles the circuit with gnark's profiler, which records the stack of every constraint added,
+// writes the profile to pprofPath in the pprof format (go tool pprof -top <path>) and sums it up by gadget
+func Profile(def circuits.Definition, b backend.ID, curve ecc.ID, pprofPath string) (constraint.ConstraintSystem, Report, error) {
+	p := profile.Start(profile.WithPath(pprofPath))
+	ccs, err := Compile(def, b, curve)
+	p.Stop()
+	if err != nil {
+		return nil, Report{}, err
+	}
+
+	r := Report{
+		Constraints: ccs.GetNbConstraints(),
+		Public:      ccs.GetNbPublicVariables(),
+		Secret:      ccs.GetNbSecretVariables(),
+		Gadgets:     make([]int, len(gadgets.Profiled)),
+	}
+	if b == backend.GROTH16 {
+		// the first public variable of an R1CS is the constant 1
+		r.Public--
+	}
+	f, err := os.Open(pprofPath)
+	if err != nil {
+		return nil, r, err
+	}
+	defer f.Close()
+	prof, err := pprof.Parse(f)
+	if err != nil {
+		return nil, r, fmt.Errorf("%s: %w", pprofPath, err)
+	}
+
+	for _, s := range prof.Sample {
+		n := int(s.Value[0])
+		r.Recorded += n
+		inGadget := false
+		for i, g := range gadgets.Profiled {
+			if inStack(s, g.Function) {
+				r.Gadgets[i] += n
+				inGadget = true
+			}
+		}
+		if !inGadget {
+			r.Other += n
+		}
+	}
+	return ccs, r, nil
+}
+
+// inStack reports whether the function is in the stack of the sample, gnark recording the full name as the system name
+func inStack(s *pprof.Sample, function string) bool {
+	for _, loc := range s.Location {
+		for _, line := range loc.Line {
+			if line.Function.SystemName == function {
+				return true
+			}
+		}
+	}
+	return false
+}
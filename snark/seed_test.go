@@ -0,0 +1,56 @@
+package snark_test
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// gnark and gnark-crypto draw the secrets of setups, SRSs and the blinding of proofs from crypto/rand.Reader.
+// withSeed replaces it with a stream derived from a seed, so that the artifacts of a circuit are the same
+// on every run and can be compared with golden files. It swaps a process-wide global and anyone who knows
+// the seed can forge proofs, so it only lives in the tests.
+
+// seedMu serialises the calls to withSeed
+var seedMu sync.Mutex
+
+// withSeed calls f with crypto/rand.Reader replaced by a deterministic stream derived from seed, and restores it
+// when f returns. Every read of crypto/rand.Reader in the meantime, from any goroutine, comes from the stream,
+// so the tests calling it must not run in parallel.
+func withSeed(seed []byte, f func() error) error {
+	seedMu.Lock()
+	defer seedMu.Unlock()
+
+	saved := rand.Reader
+	rand.Reader = &seededReader{key: sha256.Sum256(seed)}
+	defer func() { rand.Reader = saved }()
+	return f()
+}
+
+// seededReader is SHA-256 in counter mode: block i of the stream is SHA-256(key || i)
+type seededReader struct {
+	mu      sync.Mutex
+	key     [sha256.Size]byte
+	counter uint64
+	block   []byte
+}
+
+func (r *seededReader) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for n := 0; n < len(b); {
+		if len(r.block) == 0 {
+			var counter [8]byte
+			binary.BigEndian.PutUint64(counter[:], r.counter)
+			r.counter++
+			block := sha256.Sum256(append(r.key[:], counter[:]...))
+			r.block = block[:]
+		}
+		c := copy(b[n:], r.block)
+		r.block = r.block[c:]
+		n += c
+	}
+	return len(b), nil
+}
@@ -0,0 +1,67 @@
+// Package snark compiles the circuits registered in anonpao/circuits with the Groth16 or PLONK backend
+// on one of the supported curves, and moves the resulting artifacts (constraint system, keys, proofs and witnesses) to and from disk.
+package snark
+
+import (
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+)
+
+// Paths lists the files shared between the setup, prove and verify stages
+type Paths struct {
+	ConstraintSystem string
+	ProvingKey       string
+	VerifyingKey     string
+	Proof            string
+	PublicWitness    string
+
+	// SRS is the universal KZG setup used by PLONK, shared by all circuits on a curve.
+	// When empty, SRSPath picks a file named after the curve.
+	SRS string
+}
+
+// DefaultPaths returns the file names historically used for the cubic circuit,
+// with the circuit name as prefix: name.r1cs, name.g16.pk, name.g16.vk, name.g16.proof and name.public.wtns.
+// PLONK artifacts are named name.scs, name.plonk.pk, name.plonk.vk and name.plonk.proof instead.
+func DefaultPaths(name string, b backend.ID) Paths {
+	cs, ext := ".r1cs", ".g16"
+	if b == backend.PLONK {
+		cs, ext = ".scs", ".plonk"
+	}
+	return Paths{
+		ConstraintSystem: name + cs,
+		ProvingKey:       name + ext + ".pk",
+		VerifyingKey:     name + ext + ".vk",
+		Proof:            name + ext + ".proof",
+		PublicWitness:    name + ".public.wtns",
+	}
+}
+
+// WithDefaults fills every empty path with its default for the given circuit name and backend
+func (p Paths) WithDefaults(name string, b backend.ID) Paths {
+	d := DefaultPaths(name, b)
+	if p.ConstraintSystem == "" {
+		p.ConstraintSystem = d.ConstraintSystem
+	}
+	if p.ProvingKey == "" {
+		p.ProvingKey = d.ProvingKey
+	}
+	if p.VerifyingKey == "" {
+		p.VerifyingKey = d.VerifyingKey
+	}
+	if p.Proof == "" {
+		p.Proof = d.Proof
+	}
+	if p.PublicWitness == "" {
+		p.PublicWitness = d.PublicWitness
+	}
+	return p
+}
+
+// SRSPath returns the KZG SRS file for the curve, curve.kzg.srs (e.g. bn254.kzg.srs) unless set explicitly
+func (p Paths) SRSPath(curve ecc.ID) string {
+	if p.SRS != "" {
+		return p.SRS
+	}
+	return curve.String() + ".kzg.srs"
+}
@@ -0,0 +1,258 @@
+package snark_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"anonpao/circuits"
+	"anonpao/snark"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/witness"
+)
+
+// The artifacts of the cubic circuit are generated afresh with a fixed seed, run through setup, prove and
+// verify as the zk command does, and their SHA-256 compared with testdata/cubic.golden, so that any change
+// to the circuit, to gnark or to the envelope shows up here.
+// After an intended change, regenerate the golden file with go test -run TestGolden -update.
+
+var update = flag.Bool("update", false, "rewrite the golden files instead of comparing with them")
+
+const (
+	goldenPath = "testdata/cubic.golden"
+	testSeed   = "anonpao snark tests"
+)
+
+// pipeline runs setup, prove and verify of the cubic circuit with the seeded randomness, writing every
+// artifact to dir and reading it back before the next step, and returns the paths of the artifacts
+func pipeline(t *testing.T, b backend.ID, curve ecc.ID, dir string) snark.Paths {
+	def, err := circuits.Lookup("cubic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := snark.DefaultPaths(def.Name, b)
+	for _, p := range []*string{&paths.ConstraintSystem, &paths.ProvingKey, &paths.VerifyingKey, &paths.Proof, &paths.PublicWitness} {
+		*p = filepath.Join(dir, *p)
+	}
+
+	err = withSeed([]byte(testSeed), func() error {
+		ccs, err := snark.Compile(def, b, curve)
+		if err != nil {
+			return err
+		}
+		h, err := snark.NewHeader(def, b, curve, ccs)
+		if err != nil {
+			return err
+		}
+		var srs kzg.SRS
+		if b == backend.PLONK {
+			if srs, err = snark.NewSRS(ccs, curve); err != nil {
+				return err
+			}
+		}
+		pk, vk, err := snark.Setup(b, ccs, srs)
+		if err != nil {
+			return err
+		}
+		if h.KeyHash, err = snark.KeyHash(vk); err != nil {
+			return err
+		}
+		if err := snark.WriteConstraintSystem(paths.ConstraintSystem, h, ccs); err != nil {
+			return err
+		}
+		if err := snark.WriteVerifyingKey(paths.VerifyingKey, h, vk); err != nil {
+			return err
+		}
+		if err := snark.WriteProvingKey(paths.ProvingKey, h, pk); err != nil {
+			return err
+		}
+
+		ccs, csHeader, err := snark.ReadConstraintSystem(paths.ConstraintSystem, b)
+		if err != nil {
+			return err
+		}
+		if err := csHeader.CheckDefinition(def); err != nil {
+			return err
+		}
+		pk, pkHeader, err := snark.ReadProvingKey(paths.ProvingKey, b)
+		if err != nil {
+			return err
+		}
+		full, err := snark.ReadWitness(def, "testdata/cubic.json", curve)
+		if err != nil {
+			return err
+		}
+		public, err := full.Public()
+		if err != nil {
+			return err
+		}
+		proof, err := snark.Prove(b, ccs, pk, full)
+		if err != nil {
+			return err
+		}
+		if err := snark.WriteProof(paths.Proof, pkHeader, proof); err != nil {
+			return err
+		}
+		return snark.WritePublicWitness(paths.PublicWitness, csHeader, public)
+	})
+	if err != nil {
+		t.Fatalf("%s on %s: %v", b, curve, err)
+	}
+
+	vk, vkHeader, err := snark.ReadVerifyingKey(paths.VerifyingKey, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, proofHeader, err := snark.ReadProof(paths.Proof, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, publicHeader, err := snark.ReadPublicWitness(paths.PublicWitness, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vkHeader.CheckSame(proofHeader); err != nil {
+		t.Fatal(err)
+	}
+	if err := vkHeader.CheckSame(publicHeader); err != nil {
+		t.Fatal(err)
+	}
+	if err := snark.Verify(b, proof, vk, public); err != nil {
+		t.Fatalf("%s on %s: valid proof rejected: %v", b, curve, err)
+	}
+	if err := snark.Verify(b, proof, vk, otherPublic(t, curve)); err == nil {
+		t.Fatalf("%s on %s: proof accepted for another public input", b, curve)
+	}
+	return paths
+}
+
+// otherPublic returns a public witness of the cubic circuit that the test proof does not prove
+func otherPublic(t *testing.T, curve ecc.ID) witness.Witness {
+	def, _ := circuits.Lookup("cubic")
+	w, err := snark.ParseWitness(def, []byte(`{"x": 3, "Y": 35}`), curve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := w.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public
+}
+
+func TestGolden(t *testing.T) {
+	got := map[string]string{}
+	for _, b := range snark.Backends {
+		for _, curve := range snark.Curves {
+			paths := pipeline(t, b, curve, t.TempDir())
+			for _, path := range []string{paths.ConstraintSystem, paths.ProvingKey, paths.VerifyingKey, paths.Proof, paths.PublicWitness} {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				hash := sha256.Sum256(data)
+				got[fmt.Sprintf("%s %s %s", b, curve, filepath.Base(path))] = hex.EncodeToString(hash[:])
+			}
+		}
+	}
+
+	if *update {
+		writeGolden(t, got)
+		return
+	}
+	want := readGolden(t)
+	for key, hash := range got {
+		if want[key] != hash {
+			t.Errorf("%s: got %s, the golden file has %q", key, hash, want[key])
+		}
+	}
+	for key := range want {
+		if _, ok := got[key]; !ok {
+			t.Errorf("%s is in the golden file but was not generated", key)
+		}
+	}
+}
+
+// TestSeedIsDeterministic checks that the golden file does not depend on the run: the same seed gives
+// the same artifacts, and another seed other keys
+func TestSeedIsDeterministic(t *testing.T) {
+	a, b := pipeline(t, backend.GROTH16, ecc.BN254, t.TempDir()), pipeline(t, backend.GROTH16, ecc.BN254, t.TempDir())
+	for _, path := range [][2]string{{a.ProvingKey, b.ProvingKey}, {a.Proof, b.Proof}} {
+		x, _ := os.ReadFile(path[0])
+		y, _ := os.ReadFile(path[1])
+		if !bytes.Equal(x, y) {
+			t.Fatalf("%s differs between two runs with the same seed", filepath.Base(path[0]))
+		}
+	}
+
+	stream := func(seed string) []byte {
+		b := make([]byte, 64)
+		err := withSeed([]byte(seed), func() error {
+			_, err := io.ReadFull(rand.Reader, b)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	if bytes.Equal(stream(testSeed), stream(testSeed+"'")) {
+		t.Fatal("different seeds give the same stream")
+	}
+}
+
+func readGolden(t *testing.T) map[string]string {
+	f, err := os.Open(goldenPath)
+	if err != nil {
+		t.Fatalf("%v, run go test -run TestGolden -update to create it", err)
+	}
+	defer f.Close()
+
+	want := map[string]string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("%s: invalid line %q", goldenPath, line)
+		}
+		want[line[:i]] = line[i+1:]
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return want
+}
+
+func writeGolden(t *testing.T, got map[string]string) {
+	keys := make([]string, 0, len(got))
+	for key := range got {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString("# SHA-256 of the artifacts of the cubic circuit generated by TestGolden, see snark_test.go\n")
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "%s %s\n", key, got[key])
+	}
+	if err := os.WriteFile(goldenPath, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
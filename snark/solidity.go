@@ -0,0 +1,104 @@
+package snark
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/witness"
+	"golang.org/x/crypto/sha3"
+)
+
+// On-chain verification uses the Solidity contract generated by gnark for Groth16 verifying keys,
+// which relies on the BN254 pairing precompiles of the EVM. Its entry point is
+//
+//	function verifyProof(uint256[8] proof, uint256[n] input) view
+//
+// where proof holds the points a, b and c and input the n public inputs in the order of the circuit.
+// It returns nothing and reverts with ProofInvalid() if the proof does not verify.
+// G2 coordinates are elements of Fp2 and, as the precompiles expect, are given imaginary part first.
+
+// checkSolidity refuses artifacts that the generated contract cannot verify
+func checkSolidity(h Header) error {
+	if h.Backend != backend.GROTH16 || h.Curve != ecc.BN254 {
+		return fmt.Errorf("solidity verifiers need a groth16 proof on bn254, the curve of the EVM precompiles, got %s on %s", h.Backend, h.Curve)
+	}
+	return nil
+}
+
+// ExportSolidity writes the Solidity verifier contract of a Groth16 verifying key on BN254
+func ExportSolidity(w io.Writer, vk VerifyingKey, h Header) error {
+	if err := checkSolidity(h); err != nil {
+		return err
+	}
+	return vk.(groth16.VerifyingKey).ExportSolidity(w)
+}
+
+// Calldata holds the arguments of verifyProof for one proof and its public inputs, as 0x-prefixed hex words.
+// Data is the complete ABI-encoded call (function selector followed by the arguments),
+// ready to be sent to the contract with eth_call.
+type Calldata struct {
+	Signature string    `json:"function"`
+	Proof     [8]string `json:"proof"`
+	Input     []string  `json:"input"`
+	Data      string    `json:"calldata"`
+}
+
+// NewCalldata formats a Groth16 proof on BN254 and its public witness as arguments of the verifyProof function
+func NewCalldata(proof Proof, publicWitness witness.Witness, h Header) (Calldata, error) {
+	if err := checkSolidity(h); err != nil {
+		return Calldata{}, err
+	}
+	p, ok := proof.(*groth16_bn254.Proof)
+	if !ok {
+		return Calldata{}, fmt.Errorf("not a groth16 proof on bn254")
+	}
+	inputs, ok := publicWitness.Vector().(fr.Vector)
+	if !ok {
+		return Calldata{}, fmt.Errorf("public witness is not over the bn254 scalar field")
+	}
+
+	// the contract takes the points Ar, Bs and Krs of the proof as a, b and c
+	var words []*big.Int
+	for _, e := range []interface{ BigInt(*big.Int) *big.Int }{
+		&p.Ar.X, &p.Ar.Y,
+		&p.Bs.X.A1, &p.Bs.X.A0, &p.Bs.Y.A1, &p.Bs.Y.A0,
+		&p.Krs.X, &p.Krs.Y,
+	} {
+		words = append(words, e.BigInt(new(big.Int)))
+	}
+	for i := range inputs {
+		words = append(words, inputs[i].BigInt(new(big.Int)))
+	}
+
+	cd := Calldata{Signature: fmt.Sprintf("verifyProof(uint256[8],uint256[%d])", len(inputs))}
+	for i := range cd.Proof {
+		cd.Proof[i] = word(words[i])
+	}
+	for _, w := range words[8:] {
+		cd.Input = append(cd.Input, word(w))
+	}
+
+	// both arguments are static arrays, so the ABI encoding is the selector followed by one 32-byte word per element
+	keccak := sha3.NewLegacyKeccak256()
+	keccak.Write([]byte(cd.Signature))
+	data := keccak.Sum(nil)[:4]
+	for _, w := range words {
+		var b [32]byte
+		data = append(data, w.FillBytes(b[:])...)
+	}
+	cd.Data = "0x" + hex.EncodeToString(data)
+	return cd, nil
+}
+
+// word formats a field element as a 0x-prefixed, zero-padded uint256
+func word(n *big.Int) string {
+	var b [32]byte
+	return "0x" + hex.EncodeToString(n.FillBytes(b[:]))
+}
@@ -0,0 +1,115 @@
+package snark_test
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"anonpao/circuits"
+	"anonpao/snark"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark/backend"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+)
+
+// The calldata of a proof made of the generators of EIP-197 and its negation, for y = 15:
+// the selector is the first 4 bytes of keccak256("verifyProof(uint256[8],uint256[1])"),
+// then a, b with the imaginary parts first, c, and the public input
+func TestCalldata(t *testing.T) {
+	_, _, g1, g2 := bn254.Generators()
+	var proof groth16_bn254.Proof
+	proof.Ar, proof.Bs = g1, g2
+	proof.Krs.Neg(&g1)
+
+	def, _ := circuits.Lookup("cubic")
+	full, err := snark.ParseWitness(def, []byte(`{"x": 2, "Y": 15}`), ecc.BN254)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := full.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd, err := snark.NewCalldata(&proof, public, snark.Header{Backend: backend.GROTH16, Curve: ecc.BN254})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	words := []string{
+		"1", "2",
+		"11559732032986387107991004021392285783925812861821192530917403151452391805634",
+		"10857046999023057135944570762232829481370756359578518086990519993285655852781",
+		"4082367875863433681332203403145435568316851327593401208105741076214120093531",
+		"8495653923123431417604973247489272438418190587263600148770280649306958101930",
+		"1", "21888242871839275222246405745257275088696311157297823662689037894645226208581",
+		"15",
+	}
+	want := "0x1b81f829"
+	for i, w := range words {
+		n, _ := new(big.Int).SetString(w, 10)
+		word := "0x" + strings.Repeat("0", 64-len(n.Text(16))) + n.Text(16)
+		if i < 8 && cd.Proof[i] != word {
+			t.Errorf("proof[%d] = %s, want %s", i, cd.Proof[i], word)
+		}
+		want += word[2:]
+	}
+	if cd.Signature != "verifyProof(uint256[8],uint256[1])" {
+		t.Errorf("signature %s", cd.Signature)
+	}
+	if len(cd.Input) != 1 || cd.Input[0] != "0x"+strings.Repeat("0", 62)+"0f" {
+		t.Errorf("input %v, want [0x...0f]", cd.Input)
+	}
+	if cd.Data != want {
+		t.Errorf("calldata\n%s\nwant\n%s", cd.Data, want)
+	}
+}
+
+// The contract of the pipeline's key takes the arguments of the calldata, which is only made for groth16 on bn254
+func TestSolidity(t *testing.T) {
+	paths := pipeline(t, backend.GROTH16, ecc.BN254, t.TempDir())
+	vk, h, err := snark.ReadVerifyingKey(paths.VerifyingKey, backend.GROTH16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contract bytes.Buffer
+	if err := snark.ExportSolidity(&contract, vk, h); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"function verifyProof(", "uint256[8] calldata proof", "uint256[1] calldata input"} {
+		if !strings.Contains(contract.String(), s) {
+			t.Errorf("the contract has no %q", s)
+		}
+	}
+
+	proof, _, err := snark.ReadProof(paths.Proof, backend.GROTH16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, _, err := snark.ReadPublicWitness(paths.PublicWitness, backend.GROTH16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd, err := snark.NewCalldata(proof, public, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := "0x1b81f829"
+	for _, w := range append(cd.Proof[:], cd.Input...) {
+		data += strings.TrimPrefix(w, "0x")
+	}
+	if cd.Data != data {
+		t.Errorf("the calldata is not the selector followed by the proof and input words")
+	}
+
+	other := h
+	other.Curve = ecc.BLS12_381
+	if _, err := snark.NewCalldata(proof, public, other); err == nil {
+		t.Error("calldata made for bls12_381")
+	}
+	if err := snark.ExportSolidity(&contract, vk, other); err == nil {
+		t.Error("contract exported for bls12_381")
+	}
+}
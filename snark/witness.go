@@ -0,0 +1,345 @@
+package snark
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+
+	"anonpao/circuits"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// Witness files are JSON or YAML objects keyed by the gnark names of the circuit fields,
+// that is the name in the `gnark:"name"` tag or the Go field name when the tag has none.
+// Nested structs are nested objects and arrays are JSON arrays. Every leaf value is one of
+//
+//	15                    a JSON number
+//	"15"                  a decimal string
+//	"0x0f"                a hex string
+//	{"value": 15, "visibility": "public"}
+//
+// The last form annotates the value with the visibility the producer expects it to have;
+// the witness is rejected if the circuit declares the field with the other visibility.
+// An array of variables may also be given as one hex string, one byte per element:
+//
+//	{"ct": "17030300", "key": {"value": "000102...0f", "visibility": "secret"}}
+//
+// Every field of the circuit must be assigned exactly once and unknown keys are rejected.
+// A witness that is not valid JSON is read as YAML, whose scalars are all taken as the strings they are
+// written as: an integer has every digit, whatever its size, and a byte array may be written unquoted.
+//
+//	ct: 17030300
+//	key: {value: 000102...0f, visibility: secret}
+
+var tVariable = reflect.TypeOf((*frontend.Variable)(nil)).Elem()
+
+// ReadWitness reads a full (public and secret) witness for the registered circuit from a JSON or YAML file,
+// over the scalar field of curve
+func ReadWitness(def circuits.Definition, path string, curve ecc.ID) (witness.Witness, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := ParseWitness(def, data, curve)
+	if err != nil {
+		return nil, fmt.Errorf("witness %s: %w", path, err)
+	}
+	return w, nil
+}
+
+// ParseWitness reads a full witness for the registered circuit from JSON or YAML data, over the scalar field of curve
+func ParseWitness(def circuits.Definition, data []byte, curve ecc.ID) (witness.Witness, error) {
+	assignment, err := ParseAssignment(def, data)
+	if err != nil {
+		return nil, err
+	}
+	return frontend.NewWitness(assignment, curve.ScalarField())
+}
+
+// ParseAssignment fills a fresh instance of the registered circuit with the values of a JSON or YAML witness
+func ParseAssignment(def circuits.Definition, data []byte) (frontend.Circuit, error) {
+	if !json.Valid(data) {
+		converted, err := yamlToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("circuit %s: the witness is neither JSON nor YAML: %w", def.Name, err)
+		}
+		data = converted
+	}
+	assignment := def.New()
+
+	v := reflect.ValueOf(assignment)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("circuit %s: constructor must return a pointer to a struct", def.Name)
+	}
+	if err := assign(v.Elem(), json.RawMessage(data), "", schema.Unset); err != nil {
+		return nil, fmt.Errorf("circuit %s: %w", def.Name, err)
+	}
+	return assignment, nil
+}
+
+// assign decodes raw into v, which is a field of the circuit reachable through path.
+// vis is the visibility inherited from the enclosing struct fields.
+func assign(v reflect.Value, raw json.RawMessage, path string, vis schema.Visibility) error {
+	switch {
+	case v.Type() == tVariable:
+		value, annotated, err := unwrap(raw, path)
+		if err != nil {
+			return err
+		}
+		if err := checkVisibility(annotated, vis, path); err != nil {
+			return err
+		}
+		n, err := parseInt(value, path)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(n))
+		return nil
+
+	case v.Kind() == reflect.Array || v.Kind() == reflect.Slice:
+		value, annotated, err := unwrap(raw, path)
+		if err != nil {
+			return err
+		}
+		if annotated != schema.Unset {
+			if err := checkVisibility(annotated, vis, path); err != nil {
+				return err
+			}
+		}
+
+		// a hex string is accepted for arrays of variables, one byte per element
+		var s string
+		if v.Type().Elem() == tVariable && json.Unmarshal(value, &s) == nil {
+			b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			if len(b) != v.Len() {
+				return fmt.Errorf("%s: got %d bytes, the circuit expects %d", path, len(b), v.Len())
+			}
+			for i := range b {
+				v.Index(i).Set(reflect.ValueOf(new(big.Int).SetUint64(uint64(b[i]))))
+			}
+			return nil
+		}
+
+		var elems []json.RawMessage
+		if err := json.Unmarshal(value, &elems); err != nil {
+			return fmt.Errorf("%s: expected an array: %w", path, err)
+		}
+		if len(elems) != v.Len() {
+			return fmt.Errorf("%s: got %d elements, the circuit expects %d", path, len(elems), v.Len())
+		}
+		for i := range elems {
+			if err := assign(v.Index(i), elems[i], fmt.Sprintf("%s[%d]", path, i), vis); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case v.Kind() == reflect.Ptr && !v.IsNil():
+		return assign(v.Elem(), raw, path, vis)
+
+	case v.Kind() == reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return fmt.Errorf("%s: expected an object: %w", path, err)
+		}
+
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name, fieldVis, skip := parseTag(sf)
+			if skip {
+				continue
+			}
+			if vis != schema.Unset {
+				fieldVis = vis
+			}
+
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			value, ok := fields[name]
+			if !ok {
+				return fmt.Errorf("missing assignment for %s", fieldPath)
+			}
+			delete(fields, name)
+
+			if err := assign(v.Field(i), value, fieldPath, fieldVis); err != nil {
+				return err
+			}
+		}
+		for name := range fields {
+			if path != "" {
+				name = path + "." + name
+			}
+			return fmt.Errorf("%s is not an input of the circuit", name)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("%s: unsupported field type %s", path, v.Type())
+}
+
+// yamlToJSON converts a YAML witness to JSON, with its scalars as strings
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	v, err := yamlValue(&doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// yamlValue returns the value of a YAML node as encoding/json marshals it
+func yamlValue(n *yaml.Node) (interface{}, error) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, fmt.Errorf("empty document")
+		}
+		return yamlValue(n.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(n.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: keys must be names", n.Content[i].Line)
+			}
+			v, err := yamlValue(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[n.Content[i].Value] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		a := make([]interface{}, len(n.Content))
+		for i := range n.Content {
+			v, err := yamlValue(n.Content[i])
+			if err != nil {
+				return nil, err
+			}
+			a[i] = v
+		}
+		return a, nil
+	}
+	if n.ShortTag() == "!!null" {
+		return nil, nil
+	}
+	return n.Value, nil
+}
+
+// parseTag returns the witness name and the visibility of a struct field from its gnark tag.
+// skip is set for fields tagged "-", which are not part of the witness.
+func parseTag(sf reflect.StructField) (name string, vis schema.Visibility, skip bool) {
+	name, vis = sf.Name, schema.Unset
+
+	tag, ok := sf.Tag.Lookup("gnark")
+	if !ok || tag == "" {
+		return name, vis, false
+	}
+	if tag == string(schema.TagOptOmit) {
+		return "", vis, true
+	}
+
+	opts := strings.Split(tag, ",")
+	if n := strings.TrimSpace(opts[0]); n != "" {
+		name = n
+	}
+	for _, opt := range opts[1:] {
+		switch schema.TagOpt(strings.TrimSpace(opt)) {
+		case schema.TagOptPublic:
+			vis = schema.Public
+		case schema.TagOptSecret:
+			vis = schema.Secret
+		}
+	}
+	return name, vis, false
+}
+
+// unwrap returns the value of an annotated leaf {"value": v, "visibility": "public"}
+// together with the annotation, or raw itself when it is not annotated
+func unwrap(raw json.RawMessage, path string) (json.RawMessage, schema.Visibility, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		return raw, schema.Unset, nil
+	}
+
+	var annotated struct {
+		Value      json.RawMessage `json:"value"`
+		Visibility string          `json:"visibility"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&annotated); err != nil {
+		return nil, schema.Unset, fmt.Errorf("%s: %w", path, err)
+	}
+	if annotated.Value == nil {
+		return nil, schema.Unset, fmt.Errorf("%s: annotated input without a value", path)
+	}
+
+	switch annotated.Visibility {
+	case "":
+		return annotated.Value, schema.Unset, nil
+	case "public":
+		return annotated.Value, schema.Public, nil
+	case "secret":
+		return annotated.Value, schema.Secret, nil
+	}
+	return nil, schema.Unset, fmt.Errorf("%s: visibility must be public or secret, got %q", path, annotated.Visibility)
+}
+
+// checkVisibility compares the annotation of a witness value with the visibility declared in the circuit
+func checkVisibility(annotated, declared schema.Visibility, path string) error {
+	if declared == schema.Unset {
+		// gnark defaults untagged inputs to secret
+		declared = schema.Secret
+	}
+	if annotated != schema.Unset && annotated != declared {
+		return fmt.Errorf("%s is annotated %s but the circuit declares it %s", path, annotated, declared)
+	}
+	return nil
+}
+
+// parseInt reads a JSON number, a decimal string or a 0x-prefixed hex string
+func parseInt(raw json.RawMessage, path string) (*big.Int, error) {
+	s := string(bytes.TrimSpace(raw))
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	n := new(big.Int)
+	var ok bool
+	if h := strings.TrimPrefix(s, "0x"); h != s {
+		_, ok = n.SetString(h, 16)
+	} else {
+		_, ok = n.SetString(s, 10)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s: %s is not a decimal or 0x-prefixed hex integer", path, s)
+	}
+	return n, nil
+}
@@ -0,0 +1,194 @@
+package snark_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"anonpao/circuits"
+	"anonpao/snark"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+)
+
+type point struct {
+	X, Y frontend.Variable
+}
+
+// witnessCircuit has a field of each kind ParseAssignment fills: a byte array, nested arrays, a nested
+// struct, public and secret inputs and a field left out of the witness
+type witnessCircuit struct {
+	Key    [4]frontend.Variable    `gnark:"key"`
+	Matrix [2][3]frontend.Variable `gnark:"matrix"`
+	Big    frontend.Variable       `gnark:"big,public"`
+	P      point                   `gnark:"p,public"`
+	Skip   frontend.Variable       `gnark:"-"`
+}
+
+func (c *witnessCircuit) Define(api frontend.API) error {
+	return nil
+}
+
+var witnessDef = circuits.Definition{Name: "witness", New: func() frontend.Circuit { return new(witnessCircuit) }}
+
+// the witness of witnessDef that the cases below edit, with the numbers of the matrix written in each form
+const validWitness = `{
+	"key": {"value": "0x0001ff10", "visibility": "secret"},
+	"matrix": [[1, "2", "0x03"], [4, 5, {"value": 6, "visibility": "secret"}]],
+	"big": "1606938044258990275541962092341162602522202993782792835301377",
+	"p": {"X": 9007199254740993, "Y": {"value": "0xffffffffffffffffff", "visibility": "public"}}
+}`
+
+// The same witness in YAML: unquoted integers past 2^64 and byte arrays are kept as written
+const validYAML = `
+key: {value: 0x0001ff10, visibility: secret}
+matrix:
+  - [1, "2", 0x03]
+  - [4, 5, {value: 6, visibility: secret}]
+big: 1606938044258990275541962092341162602522202993782792835301377
+p:
+  X: 9007199254740993
+  Y: {value: 0xffffffffffffffffff, visibility: public}
+`
+
+func bigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		panic(s)
+	}
+	return n
+}
+
+// checkAssignment checks the values of the assignment of validWitness, which are big.Int
+func checkAssignment(t *testing.T, name string, assignment frontend.Circuit) {
+	t.Helper()
+	c := assignment.(*witnessCircuit)
+	want := []struct {
+		got  frontend.Variable
+		want string
+	}{
+		{c.Key[0], "0"}, {c.Key[1], "1"}, {c.Key[2], "255"}, {c.Key[3], "16"},
+		{c.Matrix[0][0], "1"}, {c.Matrix[0][1], "2"}, {c.Matrix[0][2], "3"},
+		{c.Matrix[1][0], "4"}, {c.Matrix[1][1], "5"}, {c.Matrix[1][2], "6"},
+		{c.Big, "1606938044258990275541962092341162602522202993782792835301377"},
+		// 2^53 + 1, which a float64 rounds to 2^53
+		{c.P.X, "9007199254740993"},
+		{c.P.Y, "0xffffffffffffffffff"},
+	}
+	for i, w := range want {
+		n, ok := w.got.(*big.Int)
+		if !ok || n.Cmp(bigInt(w.want)) != 0 {
+			t.Errorf("%s: value %d is %v, want %s", name, i, w.got, w.want)
+		}
+	}
+	if c.Skip != nil {
+		t.Errorf("%s: the field tagged - is assigned %v", name, c.Skip)
+	}
+}
+
+func TestParseAssignment(t *testing.T) {
+	for name, data := range map[string]string{"JSON": validWitness, "YAML": validYAML} {
+		assignment, err := snark.ParseAssignment(witnessDef, []byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		checkAssignment(t, name, assignment)
+	}
+}
+
+// The public witness holds the public inputs in the order of the fields, past 2^53 and 2^64 alike
+func TestParseWitnessPublic(t *testing.T) {
+	full, err := snark.ParseWitness(witnessDef, []byte(validWitness), ecc.BN254)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := full.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := public.Vector().(fr.Vector)
+	want := []string{"1606938044258990275541962092341162602522202993782792835301377", "9007199254740993", "0xffffffffffffffffff"}
+	if len(got) != len(want) {
+		t.Fatalf("%d public inputs, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if n := got[i].BigInt(new(big.Int)); n.Cmp(bigInt(want[i])) != 0 {
+			t.Errorf("public input %d is %s, want %s", i, n, want[i])
+		}
+	}
+	if n := len(full.Vector().(fr.Vector)); n != 4+6+3 {
+		t.Errorf("%d inputs in the full witness, want 13", n)
+	}
+}
+
+func TestParseAssignmentRejects(t *testing.T) {
+	for _, c := range []struct {
+		name, old, new, want string
+	}{
+		{"a byte array of the wrong length", `"0x0001ff10"`, `"0x0001ff"`, "key: got 3 bytes, the circuit expects 4"},
+		{"a byte array that is not hex", `"0x0001ff10"`, `"0x0001ffzz"`, "key: encoding/hex: invalid byte"},
+		{"a row too short", `[1, "2", "0x03"]`, `[1, "2"]`, "matrix[0]: got 2 elements, the circuit expects 3"},
+		{"a row too many", `[[1, "2", "0x03"], `, `[[1, "2", "0x03"], [7, 8, 9], `, "matrix: got 3 elements, the circuit expects 2"},
+		{"a number for a row", `[1, "2", "0x03"]`, `1`, "matrix[0]: expected an array"},
+		{"a fraction", `"2"`, `2.5`, "matrix[0][1]: 2.5 is not a decimal or 0x-prefixed hex integer"},
+		{"a bad hex number", `"0x03"`, `"0x0g"`, "matrix[0][2]: 0x0g is not"},
+		{"a missing key", `"big": "1606938044258990275541962092341162602522202993782792835301377",`, ``, "missing assignment for big"},
+		{"a missing nested key", `"Y": {"value": "0xffffffffffffffffff", "visibility": "public"}`, `"Z": 1`, "missing assignment for p.Y"},
+		{"an unknown key", `"key":`, `"iv": 1, "key":`, "iv is not an input of the circuit"},
+		{"an unknown nested key", `"X": 9007199254740993`, `"X": 1, "Z": 1`, "p.Z is not an input of the circuit"},
+		{"the field tagged -", `"key":`, `"Skip": 1, "key":`, "Skip is not an input of the circuit"},
+		{"a secret annotated public", `{"value": 6, "visibility": "secret"}`, `{"value": 6, "visibility": "public"}`,
+			"matrix[1][2] is annotated public but the circuit declares it secret"},
+		{"a byte array annotated public", `"0x0001ff10", "visibility": "secret"`, `"0x0001ff10", "visibility": "public"`,
+			"key is annotated public but the circuit declares it secret"},
+		{"a nested public input annotated secret", `"0xffffffffffffffffff", "visibility": "public"`,
+			`"0xffffffffffffffffff", "visibility": "secret"`, "p.Y is annotated secret but the circuit declares it public"},
+		{"a public input annotated secret", `"big": "1606938044258990275541962092341162602522202993782792835301377"`,
+			`"big": {"value": 1, "visibility": "secret"}`, "big is annotated secret but the circuit declares it public"},
+		{"an unknown visibility", `"visibility": "secret"`, `"visibility": "private"`, `visibility must be public or secret, got "private"`},
+		{"an annotation without a value", `{"value": 6, "visibility": "secret"}`, `{"visibility": "secret"}`, "annotated input without a value"},
+		{"an annotation with another key", `{"value": 6, "visibility": "secret"}`, `{"value": 6, "vis": "secret"}`, `unknown field "vis"`},
+	} {
+		data := strings.Replace(validWitness, c.old, c.new, 1)
+		if data == validWitness {
+			t.Fatalf("%s: %s is not in the witness", c.name, c.old)
+		}
+		_, err := snark.ParseAssignment(witnessDef, []byte(data))
+		if err == nil {
+			t.Errorf("%s is accepted", c.name)
+		} else if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %q, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestParseAssignmentRejectsYAML(t *testing.T) {
+	for _, c := range []struct {
+		name, old, new, want string
+	}{
+		{"a missing key", "big: 1606938044258990275541962092341162602522202993782792835301377\n", "", "missing assignment for big"},
+		{"an unknown key", "key:", "iv: 1\nkey:", "iv is not an input of the circuit"},
+		{"a row too short", `[1, "2", 0x03]`, `[1, "2"]`, "matrix[0]: got 2 elements, the circuit expects 3"},
+		{"a secret annotated public", "6, visibility: secret", "6, visibility: public", "matrix[1][2] is annotated public"},
+		{"a null", "X: 9007199254740993", "X:", "p.X: null is not a decimal"},
+		{"a boolean", "X: 9007199254740993", "X: true", "p.X: true is not a decimal"},
+		{"a tab in the indentation", "  - [4", "\t- [4", "neither JSON nor YAML"},
+	} {
+		data := strings.Replace(validYAML, c.old, c.new, 1)
+		if data == validYAML {
+			t.Fatalf("%s: %s is not in the witness", c.name, c.old)
+		}
+		_, err := snark.ParseAssignment(witnessDef, []byte(data))
+		if err == nil {
+			t.Errorf("%s is accepted", c.name)
+		} else if !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %q, want %q", c.name, err, c.want)
+		}
+	}
+	if _, err := snark.ParseAssignment(witnessDef, []byte(`{"key": "0x0001ff10"`)); err == nil ||
+		!strings.Contains(err.Error(), "neither JSON nor YAML") {
+		t.Errorf("truncated JSON: %v", err)
+	}
+}
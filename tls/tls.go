@@ -2,11 +2,14 @@ package tls
 
 import (
 	"anonpao/aesgcm"
+	"anonpao/chacha20poly1305"
 	"anonpao/hkdf"
 	"anonpao/sha2"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"log"
+	"math"
 )
 
 // NOTATION is from https://eprint.iacr.org/2020/1044.pdf
@@ -40,16 +43,35 @@ import (
 
 func Get1RTT_HS_new(
 	HS, H2, H7 []byte,
-	CH_SH_len uint16, CH_SH []byte,
-	ServExt_len uint16, ServExt_ct []byte,
+	CH_SH_len uint64, CH_SH []byte,
+	ServExt_len uint64, ServExt_ct []byte,
 	ServExt_ct_tail []byte, ServExt_tail_len uint8,
 	SHA_H_Checkpoint []uint32,
 	appl_ct []byte) ([][]byte, error) {
 
+	// The lengths are checked up front, so that none of them wraps around below
+	if ServExt_tail_len < 36 || uint64(ServExt_tail_len) > ServExt_len {
+		return nil, fmt.Errorf("ServExt tail of %d bytes, which must hold the 36 bytes of the ServerFinished extension and be within the %d bytes of ServExt",
+			ServExt_tail_len, ServExt_len)
+	}
+	if CH_SH_len > math.MaxUint64-ServExt_len || CH_SH_len+ServExt_len > sha2.MaxLength {
+		return nil, fmt.Errorf("transcript of %d + %d bytes, longer than SHA-256 can hash", CH_SH_len, ServExt_len)
+	}
+
+	// The AEAD of the records is that of the cipher suite in the ServerHello
+	suite, err := Negotiated_suite(CH_SH)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := suite_aead(suite)
+	if err != nil {
+		return nil, err
+	}
+
 	SHTS := hkdf.HKDF_expand_derive_secret(HS, "s hs traffic", H2)
 
 	// traffic key and iv for "server handshake" messages
-	tk_shs := hkdf.HKDF_expand_derive_tk(SHTS, 16)
+	tk_shs := hkdf.HKDF_expand_derive_tk(SHTS, aead.key_length)
 	iv_shs := hkdf.HKDF_expand_derive_iv(SHTS, 12)
 
 	// log.Println("tk_shs: ", hex.EncodeToString(tk_shs))
@@ -58,31 +80,36 @@ func Get1RTT_HS_new(
 	// ServExt := aesgcm.AES_GCM_decrypt(tk_shs, iv_shs, ServExt_ct, byte(0))
 
 	// ServExt = ServExt_head || ServExt_tail
-	ServExt_head_length := ServExt_len - uint16(ServExt_tail_len)
+	ServExt_head_length := ServExt_len - uint64(ServExt_tail_len)
 
-	// To decrypt the ServExt_tail, we need to calculate the GCM counter block number
-	gcm_block_number := byte(ServExt_head_length / uint16(16))
+	// To decrypt the ServExt_tail, we need to calculate the counter block number,
+	// which aesgcm and chacha20poly1305 both take as 32 bits
+	if ServExt_head_length/aead.block_size > aead.max_block {
+		return nil, fmt.Errorf("ServExt tail starts in %s block %d, past the %d blocks it can count",
+			aead.name, ServExt_head_length/aead.block_size, aead.max_block)
+	}
+	block_number := ServExt_head_length / aead.block_size
 
 	// Now, we need to decrypt the ServExt_tail.
-	// As we are using AES GCM, we need to find the exact block number that the tail starts at.
-	// One AES block = 16 bytes
+	// We need to find the exact block number that the tail starts at.
+	// One AES block = 16 bytes, one ChaCha20 block = 64 bytes
 	// gcm_block_number := byte(ServExt_len/uint16(64)) * byte(4)
 	// log.Println("gcm_block_number: ", gcm_block_number, ServExt_len)
 
 	// Additionally, the ServExt_tail might not start perfectly at the start of a block
-	// That is, the length of ServExt_head may not be a multiple of 16
-	offset := byte(ServExt_head_length % uint16(16))
+	// That is, the length of ServExt_head may not be a multiple of the block size
+	offset := byte(ServExt_head_length % aead.block_size)
 
 	// This function decrypts the tail with the specific GCM block number and offset within the block
 	// ServExt_tail := aesgcm.AES_GCM_decrypt(tk_shs, iv_shs, ServExt_ct_tail, gcm_block_number)
 
-	ServExt_tail := aesgcm.AES_GCM_decrypt_128bytes_middle(tk_shs, iv_shs, ServExt_ct_tail, gcm_block_number, offset)
+	ServExt_tail := aead.decrypt_128bytes_middle(tk_shs, iv_shs, ServExt_ct_tail, block_number, offset)
 	// log.Println("ServExt_tail: ", hex.EncodeToString(ServExt_tail))
 
 	// This transcript is CH || SH || ServExt
 	// TR3 := utils.Concat(CH_SH, ServExt)
 	TR3_len := CH_SH_len + ServExt_len
-	TR7_len := TR3_len - uint16(36)
+	TR7_len := TR3_len - uint64(36)
 
 	// As we don't know the true length of ServExt, the variable's size is a fixed upper bound
 	// However, we only require a hash of the true transcript, which is a prefix of the variable
@@ -100,7 +127,10 @@ func Get1RTT_HS_new(
 	// - the tail of TR3 (the suffix after the checkpoint)
 	// - the length of the tail of TR3
 	// - the length of the tail of TR7
-	H7_H3 := sha2.Double_SHA_from_checkpoint(SHA_H_Checkpoint, TR3_len, TR7_len, ServExt_tail, ServExt_tail_len, ServExt_tail_len-byte(36))
+	H7_H3, err := sha2.Double_SHA_from_checkpoint(SHA_H_Checkpoint, TR3_len, TR7_len, ServExt_tail, ServExt_tail_len, ServExt_tail_len-byte(36))
+	if err != nil {
+		return nil, err
+	}
 
 	H_7 := H7_H3[0]
 	H_3 := H7_H3[1]
@@ -142,13 +172,13 @@ func Get1RTT_HS_new(
 	// log.Println("CATS_newer: ", CATS_newer)
 
 	// client application traffic key, iv
-	tk_capp := hkdf.HKDF_expand_derive_tk(CATS, 16)
+	tk_capp := hkdf.HKDF_expand_derive_tk(CATS, aead.key_length)
 	iv_capp := hkdf.HKDF_expand_derive_iv(CATS, 12)
 
 	log.Println("tk_capp: ", hex.EncodeToString(tk_capp))
 	log.Println("iv_capp: ", hex.EncodeToString(iv_capp))
 
-	dns_plaintext := aesgcm.AES_GCM_decrypt(tk_capp, iv_capp, appl_ct, byte(0))
+	dns_plaintext := aead.decrypt(tk_capp, iv_capp, appl_ct, 0)
 
 	// testing aesgcm
 	// dummy_data := []byte("hello world")
@@ -165,3 +195,113 @@ func Get1RTT_HS_new(
 
 	return [][]byte{dns_plaintext, tk_shs, iv_shs, tk_capp, iv_capp, H_3, SF_calculated}, nil
 }
+
+// TR7_checkpoint returns the SHA_H_Checkpoint taken by Get1RTT_HS_new: the H-state of SHA-256 after the
+// whole blocks of TR7, with the number of bytes they hold. The client computes it from its handshake secret,
+// which gives the server handshake key, and so the server extensions of the transcript.
+func TR7_checkpoint(HS, H2, CH_SH, ServExt_ct []byte) (sha2.Checkpoint, error) {
+	if len(CH_SH)+len(ServExt_ct) < 36 {
+		return sha2.Checkpoint{}, fmt.Errorf("transcript of %d bytes, shorter than the ServerFinished extension", len(CH_SH)+len(ServExt_ct))
+	}
+	suite, err := Negotiated_suite(CH_SH)
+	if err != nil {
+		return sha2.Checkpoint{}, err
+	}
+	aead, err := suite_aead(suite)
+	if err != nil {
+		return sha2.Checkpoint{}, err
+	}
+	SHTS := hkdf.HKDF_expand_derive_secret(HS, "s hs traffic", H2)
+	tk_shs := hkdf.HKDF_expand_derive_tk(SHTS, aead.key_length)
+	iv_shs := hkdf.HKDF_expand_derive_iv(SHTS, 12)
+	ServExt := aead.decrypt(tk_shs, iv_shs, ServExt_ct, 0)
+
+	TR7_len := len(CH_SH) + len(ServExt) - 36
+	checkpoint_len := TR7_len - TR7_len%sha2.BlockSize
+
+	d := sha2.New()
+	if checkpoint_len <= len(CH_SH) {
+		d.Write(CH_SH[:checkpoint_len])
+	} else {
+		d.Write(CH_SH)
+		d.Write(ServExt[:checkpoint_len-len(CH_SH)])
+	}
+	return d.Checkpoint()
+}
+
+// CipherSuite is the code point of a TLS 1.3 cipher suite (RFC 8446 appendix B.4)
+type CipherSuite uint16
+
+const (
+	TLS_AES_128_GCM_SHA256       CipherSuite = 0x1301
+	TLS_CHACHA20_POLY1305_SHA256 CipherSuite = 0x1303
+	TLS_AES_128_CCM_SHA256       CipherSuite = 0x1304
+	TLS_AES_128_CCM_8_SHA256     CipherSuite = 0x1305
+)
+
+// Negotiated_suite returns the cipher suite the server picked: the one of the ServerHello,
+// the handshake message that follows the ClientHello in CH_SH
+func Negotiated_suite(CH_SH []byte) (CipherSuite, error) {
+	// a handshake message is its type, its 24-bit length and its body
+	if len(CH_SH) < 4 || CH_SH[0] != 1 {
+		return 0, errors.New("CH_SH does not start with a ClientHello")
+	}
+	CH_len := int(CH_SH[1])<<16 | int(CH_SH[2])<<8 | int(CH_SH[3])
+	if len(CH_SH) < 4+CH_len+4 || CH_SH[4+CH_len] != 2 {
+		return 0, errors.New("the ClientHello of CH_SH is not followed by a ServerHello")
+	}
+	// legacy_version, random, then legacy_session_id_echo, a byte of length and at most 32 bytes
+	body := CH_SH[4+CH_len+4:]
+	if len(body) < 2+32+1 || len(body) < 2+32+1+int(body[34])+2 {
+		return 0, errors.New("the ServerHello of CH_SH is truncated")
+	}
+	i := 2 + 32 + 1 + int(body[34])
+	return CipherSuite(body[i])<<8 | CipherSuite(body[i+1]), nil
+}
+
+// aead holds the record protection of a cipher suite, as the key schedule uses it
+type aead struct {
+	name       string
+	key_length int
+	// block_size is the number of bytes of keystream of a block, the unit of the block numbers
+	block_size uint64
+	// max_block is the last block decrypt_128bytes_middle can start at
+	max_block               uint64
+	decrypt                 func(key, iv, ciphertext []byte, starting_block uint64) []byte
+	decrypt_128bytes_middle func(key, iv, ciphertext []byte, starting_block uint64, offset byte) []byte
+}
+
+func suite_aead(suite CipherSuite) (aead, error) {
+	switch suite {
+	case TLS_AES_128_GCM_SHA256:
+		return aead{
+			// the 128 bytes span 9 blocks, whose 32-bit counter is starting_block + 2 + i in aesgcm
+			name: "AES-GCM", key_length: 16, block_size: 16, max_block: math.MaxUint32 - 2 - 8,
+			decrypt: func(key, iv, ciphertext []byte, starting_block uint64) []byte {
+				return aesgcm.AES_GCM_decrypt(key, iv, ciphertext, uint32(starting_block))
+			},
+			decrypt_128bytes_middle: func(key, iv, ciphertext []byte, starting_block uint64, offset byte) []byte {
+				return aesgcm.AES_GCM_decrypt_128bytes_middle(key, iv, ciphertext, uint32(starting_block), offset)
+			},
+		}, nil
+	case TLS_CHACHA20_POLY1305_SHA256:
+		return aead{
+			// the 128 bytes span 3 blocks, the counter of the last being at most 2^32 - 1
+			name: "ChaCha20", key_length: chacha20poly1305.KeySize, block_size: chacha20poly1305.BlockSize, max_block: math.MaxUint32 - 3,
+			decrypt: func(key, iv, ciphertext []byte, starting_block uint64) []byte {
+				return chacha20poly1305.ChaCha20_decrypt(key, iv, ciphertext, uint32(starting_block))
+			},
+			decrypt_128bytes_middle: func(key, iv, ciphertext []byte, starting_block uint64, offset byte) []byte {
+				return chacha20poly1305.ChaCha20_decrypt_128bytes_middle(key, iv, ciphertext, uint32(starting_block), offset)
+			},
+		}, nil
+	case TLS_AES_128_CCM_SHA256, TLS_AES_128_CCM_8_SHA256:
+		// the two suites only differ by the length of the tag, which the key schedule does not check
+		return aead{
+			// the 128 bytes span 9 blocks, the counter of the last being at most 2^24 - 1 with a 12-byte nonce
+			name: "AES-CCM", key_length: 16, block_size: 16, max_block: 1<<24 - 10,
+			decrypt: aesgcm.AES_CCM_decrypt, decrypt_128bytes_middle: aesgcm.AES_CCM_decrypt_128bytes_middle,
+		}, nil
+	}
+	return aead{}, fmt.Errorf("cipher suite %#04x is not supported", uint16(suite))
+}
@@ -0,0 +1,194 @@
+package tls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"anonpao/aesgcm"
+	"anonpao/chacha20poly1305"
+	"anonpao/hkdf"
+	"anonpao/sha2"
+)
+
+// hello returns a ClientHello of n bytes of body followed by a ServerHello picking suite
+func hello(r *rand.Rand, n int, suite CipherSuite) []byte {
+	CH := append([]byte{1, 0, byte(n >> 8), byte(n)}, make([]byte, n)...)
+	r.Read(CH[4:])
+	// legacy_version, random, a 32-byte legacy_session_id_echo, the suite, no compression and no extensions
+	body := append([]byte{3, 3}, make([]byte, 32)...)
+	body = append(body, 32)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, byte(suite>>8), byte(suite), 0, 0, 0)
+	r.Read(body[2:34])
+	SH := append([]byte{2, 0, 0, byte(len(body))}, body...)
+	return append(CH, SH...)
+}
+
+func encrypt(suite CipherSuite, key, iv, plaintext []byte) []byte {
+	switch suite {
+	case TLS_CHACHA20_POLY1305_SHA256:
+		return chacha20poly1305.ChaCha20_encrypt(key, iv, plaintext, 0)
+	case TLS_AES_128_CCM_SHA256, TLS_AES_128_CCM_8_SHA256:
+		return aesgcm.AES_CCM_encrypt(key, iv, plaintext, 0)
+	}
+	// crypto/cipher, so that a counter aesgcm gets wrong is not also used to encrypt
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	sealed := gcm.Seal(nil, iv, plaintext, nil)
+	return sealed[:len(plaintext)]
+}
+
+// The HS shortcut on a transcript of the given cipher suite: the server extensions end with the Finished
+// message of the server, whose verify_data is derived from the handshake secret.
+// checkSuite returns the block of the suite the tail of the server extensions starts in, and the error of
+// Get1RTT_HS_new, whose values are checked when there is none.
+func checkSuite(t *testing.T, suite CipherSuite, keyLength, CH_len, ServExt_len int) (int, error) {
+	r := rand.New(rand.NewSource(int64(suite) + int64(ServExt_len)))
+	HS := make([]byte, 32)
+	r.Read(HS)
+	CH_SH := hello(r, CH_len, suite)
+	H2 := sha2.SHA2(CH_SH)
+
+	ServExt := make([]byte, ServExt_len)
+	r.Read(ServExt)
+	H7 := sha2.SHA2(append(append([]byte{}, CH_SH...), ServExt[:ServExt_len-36]...))
+	SHTS := hkdf.HKDF_expand_derive_secret(HS, "s hs traffic", H2)
+	SF := hkdf.HMAC(hkdf.HKDF_expand_derive_secret(SHTS, "finished", []byte{}), H7)
+	copy(ServExt[ServExt_len-36:], append([]byte{20, 0, 0, 32}, SF...))
+	ServExt_ct := encrypt(suite, hkdf.HKDF_expand_derive_tk(SHTS, keyLength), hkdf.HKDF_expand_derive_iv(SHTS, 12), ServExt)
+
+	TR3 := append(append([]byte{}, CH_SH...), ServExt...)
+	H3 := sha256.Sum256(TR3)
+	dHS := hkdf.HKDF_expand_derive_secret(HS, "derived", sha2.Hash_of_empty())
+	CATS := hkdf.HKDF_expand_derive_secret(hkdf.HKDF_extract(dHS, make([]byte, 32)), "c ap traffic", H3[:])
+	request := []byte("GET /dns-query?dns=AAABAAABAAAAAAAAA3d3dwdleGFtcGxlA2NvbQAAAQAB HTTP/1.1\r\nHost: dns.example\r\n\r\n")
+	appl_ct := encrypt(suite, hkdf.HKDF_expand_derive_tk(CATS, keyLength), hkdf.HKDF_expand_derive_iv(CATS, 12), request)
+
+	// the tail is the suffix of TR3 after the last whole block of TR7, as fwall computes it
+	TR7_len := len(TR3) - 36
+	tail_len := len(TR3) - TR7_len/64*64
+	tail := make([]byte, 128)
+	copy(tail, ServExt_ct[ServExt_len-tail_len:])
+
+	checkpoint, err := TR7_checkpoint(HS, H2, CH_SH, ServExt_ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block_size := 16
+	if suite == TLS_CHACHA20_POLY1305_SHA256 {
+		block_size = chacha20poly1305.BlockSize
+	}
+	tail_block := (ServExt_len - tail_len) / block_size
+	values, err := Get1RTT_HS_new(HS, H2, H7, uint64(len(CH_SH)), CH_SH, uint64(ServExt_len), ServExt_ct,
+		tail, uint8(tail_len), checkpoint.H[:], appl_ct)
+	if err != nil {
+		return tail_block, err
+	}
+	if !bytes.Equal(values[0], request) {
+		t.Errorf("decrypted %q", values[0])
+	}
+	if len(values[1]) != keyLength {
+		t.Errorf("server handshake key of %d bytes, want %d", len(values[1]), keyLength)
+	}
+	if !bytes.Equal(values[5], H3[:]) {
+		t.Errorf("H3 %x, want %x", values[5], H3)
+	}
+	if !bytes.Equal(values[6], SF) {
+		t.Errorf("SF %x, want %x", values[6], SF)
+	}
+	return tail_block, nil
+}
+
+func TestCipherSuites(t *testing.T) {
+	// the tail starts in the middle of a block, and past the 256 blocks a byte counter would count; 20000
+	// bytes of server extensions are those of a certificate chain
+	for _, c := range []struct {
+		suite                  CipherSuite
+		keyLength, ServExt_len int
+	}{
+		{TLS_AES_128_GCM_SHA256, 16, 1000},
+		{TLS_AES_128_GCM_SHA256, 16, 5000},
+		{TLS_AES_128_GCM_SHA256, 16, 20000},
+		{TLS_CHACHA20_POLY1305_SHA256, 32, 1000},
+		{TLS_CHACHA20_POLY1305_SHA256, 32, 5000},
+		{TLS_AES_128_CCM_SHA256, 16, 1000},
+		{TLS_AES_128_CCM_8_SHA256, 16, 5000},
+	} {
+		if _, err := checkSuite(t, c.suite, c.keyLength, 200, c.ServExt_len); err != nil {
+			t.Errorf("%#04x, %d bytes of server extensions: %v", uint16(c.suite), c.ServExt_len, err)
+		}
+	}
+}
+
+// The 128 bytes of the tail span 9 AES-GCM blocks: the tail decrypts in each of the blocks around 256, where
+// the counter of aesgcm used to be a byte that wrapped around
+func TestGCMCounterByte(t *testing.T) {
+	// the tail starts after the whole SHA-256 blocks of the transcript, so the length of the ClientHello
+	// moves it within the AES blocks
+	seen := map[int]bool{}
+	for CH_len := 200; CH_len < 264; CH_len += 3 {
+		for _, ServExt_len := range []int{3900, 3964, 4028, 4092} {
+			tail_block, err := checkSuite(t, TLS_AES_128_GCM_SHA256, 16, CH_len, ServExt_len)
+			seen[tail_block] = true
+			if err != nil {
+				t.Errorf("tail in block %d: %v", tail_block, err)
+			}
+		}
+	}
+	for block := 245; block <= 250; block++ {
+		if !seen[block] {
+			t.Fatalf("the lengths miss block %d", block)
+		}
+	}
+}
+
+// The 32-bit counter of the 9 blocks of the tail ends at 2^32 - 1 when it starts in block 2^32 - 11: a tail
+// in the next block is refused before anything is decrypted
+func TestGCMBlockLimit(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	CH_SH := hello(r, 200, TLS_AES_128_GCM_SHA256)
+	secret := make([]byte, 32)
+	for _, c := range []struct {
+		block   uint64
+		refused bool
+	}{
+		{math.MaxUint32 - 2 - 8, false},
+		{math.MaxUint32 - 2 - 7, true},
+		{math.MaxUint32, true},
+	} {
+		ServExt_len := c.block*16 + 5 + 100
+		_, err := Get1RTT_HS_new(secret, secret, secret, uint64(len(CH_SH)), CH_SH, ServExt_len, nil,
+			make([]byte, 128), 100, make([]uint32, 8), nil)
+		if refused := err != nil && strings.Contains(err.Error(), "past the"); refused != c.refused {
+			t.Errorf("tail in block %d: %v", c.block, err)
+		}
+	}
+}
+
+func TestNegotiatedSuite(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	CH_SH := hello(r, 100, TLS_CHACHA20_POLY1305_SHA256)
+	if suite, err := Negotiated_suite(CH_SH); err != nil || suite != TLS_CHACHA20_POLY1305_SHA256 {
+		t.Fatalf("got %#04x, %v", uint16(suite), err)
+	}
+	for n := 0; n < len(CH_SH)-3; n++ {
+		if _, err := Negotiated_suite(CH_SH[:n]); err == nil {
+			t.Fatalf("no error on the first %d bytes", n)
+		}
+	}
+	if _, err := suite_aead(0x1302); err == nil {
+		t.Fatal("TLS_AES_256_GCM_SHA384 is accepted")
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"anonpao/snark"
+
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/witness"
+)
+
+// aggregate proves many groth16 proofs of a circuit on bls12_377 with a single proof on bw6_761, see snark/aggregate.go:
+//
+//	zk aggregate setup  -circuit cubic -k 2                      -> cubic-agg2.r1cs, cubic-agg2.g16.pk, cubic-agg2.g16.vk
+//	zk aggregate prove  -circuit cubic a.proof:a.wtns b.proof:b.wtns -> cubic-agg2.g16.proof, cubic-agg2.public.wtns
+//	zk aggregate verify -circuit cubic a.wtns b.wtns             -> exits non-zero if the aggregate is invalid
+//
+// The inner artifacts are found as by the other commands (-r1cs, -vk), and the aggregation artifacts are named
+// after <circuit>-agg<k> unless -name is given. verify recomputes the public witness of the aggregate from
+// the public witnesses of the inner proofs, or reads cubic-agg2.public.wtns when none is given.
+
+var aggregateCommands = []command{
+	{"setup", "compile the circuit verifying k proofs and generate its keys", runAggregateSetup},
+	{"prove", "prove that all the proof:public witness pairs given as arguments are valid", runAggregateProve},
+	{"verify", "verify an aggregate proof against the public witnesses of the inner proofs", runAggregateVerify},
+}
+
+func runAggregate(args []string) error {
+	if len(args) > 0 {
+		for _, c := range aggregateCommands {
+			if c.name == args[0] {
+				return c.run(args[1:])
+			}
+		}
+	}
+	fmt.Fprintln(os.Stderr, "usage: zk aggregate <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	for _, c := range aggregateCommands {
+		fmt.Fprintf(os.Stderr, "  %-6s %s\n", c.name, c.usage)
+	}
+	if len(args) == 0 {
+		return errors.New("no aggregate command given")
+	}
+	return fmt.Errorf("unknown aggregate command %q", args[0])
+}
+
+// aggregateFlags registers the flags of the inner circuit, as artifactFlags, and -name on fs.
+// The returned function resolves the inner target and the paths of the aggregation of k proofs.
+func aggregateFlags(fs *flag.FlagSet) func(k int) (target, snark.Paths, error) {
+	resolve := artifactFlags(fs)
+	name := fs.String("name", "", "prefix of the aggregation artifacts (default <circuit>-agg<k>)")
+
+	return func(k int) (target, snark.Paths, error) {
+		t, err := resolve()
+		if err != nil {
+			return t, snark.Paths{}, err
+		}
+		if t.backend != backend.GROTH16 {
+			return t, snark.Paths{}, fmt.Errorf("only %s proofs can be aggregated, not %s", backend.GROTH16, t.backend)
+		}
+		if *name == "" {
+			*name = snark.AggregateName(t.def.Name, k)
+		}
+		return t, snark.DefaultPaths(*name, backend.GROTH16), nil
+	}
+}
+
+// readInnerKey reads the verifying key of the inner proofs
+func readInnerKey(t target) (snark.VerifyingKey, snark.Header, error) {
+	vk, h, err := snark.ReadVerifyingKey(t.paths.VerifyingKey, t.backend)
+	if err != nil {
+		return nil, h, err
+	}
+	if err := h.CheckDefinition(t.def); err != nil {
+		return nil, h, fmt.Errorf("%s: %w", t.paths.VerifyingKey, err)
+	}
+	return vk, h, nil
+}
+
+func runAggregateSetup(args []string) error {
+	fs := flag.NewFlagSet("aggregate setup", flag.ExitOnError)
+	resolve := aggregateFlags(fs)
+	k := fs.Int("k", 2, "number of proofs per aggregate")
+	fs.Parse(args)
+
+	t, paths, err := resolve(*k)
+	if err != nil {
+		return err
+	}
+	innerCCS, ccsHeader, err := snark.ReadConstraintSystem(t.paths.ConstraintSystem, t.backend)
+	if err != nil {
+		return err
+	}
+	innerVK, h, err := readInnerKey(t)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckSame(ccsHeader); err != nil {
+		return fmt.Errorf("%s and %s: %w", t.paths.ConstraintSystem, t.paths.VerifyingKey, err)
+	}
+
+	ccs, aggHeader, err := snark.CompileAggregation(innerCCS, innerVK, h, *k)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("aggregation of %d proofs of %s: %d constraints on %s\n", *k, t.def.Name, ccs.GetNbConstraints(), snark.AggregationCurve)
+	if err := snark.WriteConstraintSystem(paths.ConstraintSystem, aggHeader, ccs); err != nil {
+		return err
+	}
+
+	pk, vk, err := snark.Setup(backend.GROTH16, ccs, nil)
+	if err != nil {
+		return err
+	}
+	if aggHeader.KeyHash, err = snark.KeyHash(vk); err != nil {
+		return err
+	}
+	if err := snark.WriteVerifyingKey(paths.VerifyingKey, aggHeader, vk); err != nil {
+		return err
+	}
+	return snark.WriteProvingKey(paths.ProvingKey, aggHeader, pk)
+}
+
+func runAggregateProve(args []string) error {
+	fs := flag.NewFlagSet("aggregate prove", flag.ExitOnError)
+	resolve := aggregateFlags(fs)
+	fs.Parse(args)
+
+	pairs := fs.Args()
+	if len(pairs) == 0 {
+		return errors.New("aggregate prove needs proof:public witness pairs as arguments")
+	}
+	t, paths, err := resolve(len(pairs))
+	if err != nil {
+		return err
+	}
+	innerVK, h, err := readInnerKey(t)
+	if err != nil {
+		return err
+	}
+
+	ccs, aggHeader, err := snark.ReadConstraintSystem(paths.ConstraintSystem, backend.GROTH16)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(aggHeader.InnerKeyHash, h.KeyHash) {
+		return fmt.Errorf("%s does not aggregate proofs of the key in %s", paths.ConstraintSystem, t.paths.VerifyingKey)
+	}
+	pk, pkHeader, err := snark.ReadProvingKey(paths.ProvingKey, backend.GROTH16)
+	if err != nil {
+		return err
+	}
+	if err := aggHeader.CheckSame(pkHeader); err != nil {
+		return fmt.Errorf("%s and %s: %w", paths.ConstraintSystem, paths.ProvingKey, err)
+	}
+
+	proofs := make([]snark.Proof, len(pairs))
+	publicWitnesses := make([]witness.Witness, len(pairs))
+	for i, pair := range pairs {
+		proofPath, witnessPath, ok := strings.Cut(pair, ":")
+		if !ok {
+			return fmt.Errorf("%q is not a proof:public witness pair", pair)
+		}
+		proof, proofHeader, err := snark.ReadProof(proofPath, t.backend)
+		if err != nil {
+			return err
+		}
+		if err := h.CheckSame(proofHeader); err != nil {
+			return fmt.Errorf("%s and %s: %w", t.paths.VerifyingKey, proofPath, err)
+		}
+		proofs[i] = proof
+		if publicWitnesses[i], err = readInnerWitness(witnessPath, h); err != nil {
+			return err
+		}
+	}
+
+	// an invalid proof would only make the solver fail, so name it first
+	invalid, err := snark.BatchVerify(t.backend, innerVK, proofs, publicWitnesses)
+	if err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		for _, i := range invalid {
+			fmt.Println("invalid proof:", pairs[i])
+		}
+		return fmt.Errorf("%d of %d proofs are invalid", len(invalid), len(pairs))
+	}
+
+	fullWitness, err := snark.AggregateWitness(aggHeader, proofs, publicWitnesses)
+	if err != nil {
+		return err
+	}
+	publicWitness, err := fullWitness.Public()
+	if err != nil {
+		return err
+	}
+	if err := snark.WritePublicWitness(paths.PublicWitness, aggHeader, publicWitness); err != nil {
+		return err
+	}
+	proof, err := snark.Prove(backend.GROTH16, ccs, pk, fullWitness)
+	if err != nil {
+		return err
+	}
+	return snark.WriteProof(paths.Proof, pkHeader, proof)
+}
+
+func runAggregateVerify(args []string) error {
+	fs := flag.NewFlagSet("aggregate verify", flag.ExitOnError)
+	resolve := aggregateFlags(fs)
+	k := fs.Int("k", 0, "number of aggregated proofs, when no public witness is given (default the number of arguments)")
+	fs.Parse(args)
+
+	witnessPaths := fs.Args()
+	if *k == 0 {
+		*k = len(witnessPaths)
+	}
+	if *k == 0 {
+		return errors.New("aggregate verify needs the public witnesses of the inner proofs as arguments, or -k")
+	}
+	t, paths, err := resolve(*k)
+	if err != nil {
+		return err
+	}
+
+	vk, h, err := snark.ReadVerifyingKey(paths.VerifyingKey, backend.GROTH16)
+	if err != nil {
+		return err
+	}
+	proof, proofHeader, err := snark.ReadProof(paths.Proof, backend.GROTH16)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckSame(proofHeader); err != nil {
+		return fmt.Errorf("%s and %s: %w", paths.VerifyingKey, paths.Proof, err)
+	}
+
+	var publicWitness witness.Witness
+	if len(witnessPaths) == 0 {
+		var witnessHeader snark.Header
+		if publicWitness, witnessHeader, err = snark.ReadPublicWitness(paths.PublicWitness, backend.GROTH16); err != nil {
+			return err
+		}
+		if err := h.CheckSame(witnessHeader); err != nil {
+			return fmt.Errorf("%s and %s: %w", paths.VerifyingKey, paths.PublicWitness, err)
+		}
+	} else {
+		// the inner public witnesses must be of the circuit whose key the aggregation circuit embeds
+		_, innerHeader, err := readInnerKey(t)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(h.InnerKeyHash, innerHeader.KeyHash) {
+			return fmt.Errorf("%s does not aggregate proofs of the key in %s", paths.VerifyingKey, t.paths.VerifyingKey)
+		}
+		publicWitnesses := make([]witness.Witness, len(witnessPaths))
+		for i, path := range witnessPaths {
+			if publicWitnesses[i], err = readInnerWitness(path, innerHeader); err != nil {
+				return err
+			}
+		}
+		if publicWitness, err = snark.AggregatePublicWitness(h, publicWitnesses); err != nil {
+			return err
+		}
+	}
+
+	if err := snark.Verify(backend.GROTH16, proof, vk, publicWitness); err != nil {
+		return errors.New("invalid proof")
+	}
+	fmt.Println("true")
+	return nil
+}
+
+// readInnerWitness reads the public witness of an inner proof, which must be of the circuit of the inner key h
+func readInnerWitness(path string, h snark.Header) (witness.Witness, error) {
+	publicWitness, witnessHeader, err := snark.ReadPublicWitness(path, h.Backend)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.CheckSame(witnessHeader); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return publicWitness, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// Two proofs of the cubic circuit on bls12_377 are aggregated into one on bw6_761, which is only verified
+// against their own public witnesses
+func TestAggregate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the aggregation circuit takes minutes to set up and prove")
+	}
+	inTempDir(t)
+	for name, w := range map[string]string{"a": `{"x": 2, "Y": 15}`, "b": `{"x": 3, "Y": 35}`, "c": `{"x": 4, "Y": 73}`} {
+		if err := os.WriteFile(name+".json", []byte(w), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(t, runSetup, "-curve", "bls12_377")
+	for _, name := range []string{"a", "b", "c"} {
+		run(t, runProve, "-witness", name+".json", "-proof", name+".proof", "-public", name+".wtns")
+	}
+
+	run(t, runAggregate, "setup", "-k", "2")
+	run(t, runAggregate, "prove", "a.proof:a.wtns", "b.proof:b.wtns")
+	run(t, runAggregate, "verify", "a.wtns", "b.wtns")
+	run(t, runAggregate, "verify", "-k", "2")
+
+	for name, witnesses := range map[string][]string{
+		"another public witness":       {"a.wtns", "c.wtns"},
+		"the public witnesses swapped": {"b.wtns", "a.wtns"},
+	} {
+		if err := runAggregate(append([]string{"verify"}, witnesses...)); err == nil {
+			t.Errorf("the aggregate is verified with %s", name)
+		}
+	}
+
+	// the public witness of the aggregate with the last bit of the public input of b flipped
+	tamper(t, "cubic-agg2.public.wtns", "cubic-agg2.public.wtns")
+	if err := runAggregate([]string{"verify", "-k", "2"}); err == nil {
+		t.Error("the aggregate is verified with a tampered public witness")
+	}
+
+	// a proof that doesn't match its public witness is named and not aggregated
+	if err := runAggregate([]string{"prove", "a.proof:b.wtns", "b.proof:b.wtns"}); err == nil {
+		t.Error("an invalid inner proof is aggregated")
+	}
+}
+
+func TestAggregateUnknownCommand(t *testing.T) {
+	for _, args := range [][]string{nil, {"no-such-command"}} {
+		if err := runAggregate(args); err == nil {
+			t.Errorf("zk aggregate %v succeeds", args)
+		}
+	}
+}
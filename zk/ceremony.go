@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"anonpao/circuits"
+	"anonpao/snark"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/constraint"
+)
+
+// ceremony replaces the secrets of a groth16 setup with contributions from many parties, see snark/ceremony.go:
+//
+//	zk ceremony init1 -circuit cubic -out bn254.phase1.0                   (coordinator)
+//	zk ceremony contribute1 -in bn254.phase1.0 -out bn254.phase1.1         (each party in turn)
+//	zk ceremony init2 -circuit cubic -phase1 bn254.phase1.0,bn254.phase1.1 -out cubic.phase2.0
+//	zk ceremony contribute2 -in cubic.phase2.0 -out cubic.phase2.1         (each party in turn)
+//	zk ceremony verify -circuit cubic -phase1 ... -phase2 ...              (anyone)
+//	zk ceremony finalize -circuit cubic -phase1 ... -phase2 ...           -> cubic.r1cs, cubic.g16.pk, cubic.g16.vk
+//
+// Contributions print the hash they build on and their own, which the party checks against the printed transcript.
+
+var ceremonyCommands = []command{
+	{"init1", "write the initial state of phase 1, sized for a circuit", runInitPhase1},
+	{"contribute1", "add a contribution to phase 1", runContributePhase1},
+	{"init2", "verify phase 1 and write the initial state of phase 2 for a circuit", runInitPhase2},
+	{"contribute2", "add a contribution to phase 2", runContributePhase2},
+	{"verify", "verify every contribution of both phases", runVerifyCeremony},
+	{"finalize", "verify both phases and write the keys", runFinalize},
+}
+
+func runCeremony(args []string) error {
+	if len(args) > 0 {
+		for _, c := range ceremonyCommands {
+			if c.name == args[0] {
+				return c.run(args[1:])
+			}
+		}
+	}
+	fmt.Fprintln(os.Stderr, "usage: zk ceremony <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	for _, c := range ceremonyCommands {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", c.name, c.usage)
+	}
+	if len(args) == 0 {
+		return errors.New("no ceremony command given")
+	}
+	return fmt.Errorf("unknown ceremony command %q", args[0])
+}
+
+func runInitPhase1(args []string) error {
+	fs := flag.NewFlagSet("ceremony init1", flag.ExitOnError)
+	name := fs.String("circuit", "cubic", "name of the registered circuit phase 1 is sized for")
+	power := fs.Int("power", -1, "log2 of the number of constraints, instead of the size of -circuit")
+	curveName := fs.String("curve", snark.DefaultCurve.String(), "curve of the proof system: bn254, bls12_381, bls12_377 or bw6_761")
+	out := fs.String("out", "", "initial state to write (default <curve>.phase1.0)")
+	fs.Parse(args)
+
+	curve, err := snark.ParseCurve(*curveName)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = curve.String() + ".phase1.0"
+	}
+	if *power < 0 {
+		def, err := circuits.Lookup(*name)
+		if err != nil {
+			return err
+		}
+		ccs, err := snark.Compile(def, backend.GROTH16, curve)
+		if err != nil {
+			return err
+		}
+		*power = snark.CeremonyPower(ccs)
+	}
+
+	p, err := snark.InitPhase1(curve, *power)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("phase 1 for 2^%d constraints on %s, initial hash %x\n", *power, curve, p.ContributionHash())
+	return snark.WritePhase1(*out, curve, p)
+}
+
+func runContributePhase1(args []string) error {
+	fs := flag.NewFlagSet("ceremony contribute1", flag.ExitOnError)
+	in := fs.String("in", "", "last state of phase 1")
+	out := fs.String("out", "", "state to write, with the contribution")
+	fs.Parse(args)
+	if *in == "" || *out == "" {
+		return errors.New("-in and -out are required")
+	}
+
+	p, h, err := snark.ReadPhase1(*in)
+	if err != nil {
+		return err
+	}
+	prev := p.ContributionHash()
+	p.Contribute()
+	fmt.Printf("contributed on top of %x\nyour contribution hash %x\n", prev, p.ContributionHash())
+	return snark.WritePhase1(*out, h.Curve, p)
+}
+
+func runInitPhase2(args []string) error {
+	fs := flag.NewFlagSet("ceremony init2", flag.ExitOnError)
+	name := fs.String("circuit", "cubic", "name of the registered circuit")
+	phase1Paths := fs.String("phase1", "", "comma separated states of phase 1, from the initial one to the last")
+	out := fs.String("out", "", "initial state to write (default <circuit>.phase2.0)")
+	fs.Parse(args)
+
+	def, err := circuits.Lookup(*name)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = def.Name + ".phase2.0"
+	}
+	curve, phase1, err := readPhase1Chain(*phase1Paths)
+	if err != nil {
+		return err
+	}
+	if err := snark.VerifyPhase1(curve, phase1); err != nil {
+		return err
+	}
+	ccs, h, err := compileGroth16(def, curve)
+	if err != nil {
+		return err
+	}
+
+	p, err := snark.InitPhase2(ccs, phase1[len(phase1)-1])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("phase 2 of circuit %s on %s, initial hash %x\n", def.Name, curve, p.ContributionHash())
+	return snark.WritePhase2(*out, h, p)
+}
+
+func runContributePhase2(args []string) error {
+	fs := flag.NewFlagSet("ceremony contribute2", flag.ExitOnError)
+	in := fs.String("in", "", "last state of phase 2")
+	out := fs.String("out", "", "state to write, with the contribution")
+	fs.Parse(args)
+	if *in == "" || *out == "" {
+		return errors.New("-in and -out are required")
+	}
+
+	p, h, err := snark.ReadPhase2(*in)
+	if err != nil {
+		return err
+	}
+	prev := p.ContributionHash()
+	p.Contribute()
+	fmt.Printf("contributed on top of %x\nyour contribution hash %x\n", prev, p.ContributionHash())
+	return snark.WritePhase2(*out, h, p)
+}
+
+func runVerifyCeremony(args []string) error {
+	fs := flag.NewFlagSet("ceremony verify", flag.ExitOnError)
+	name := fs.String("circuit", "cubic", "name of the registered circuit")
+	phase1Paths := fs.String("phase1", "", "comma separated states of phase 1, from the initial one to the last")
+	phase2Paths := fs.String("phase2", "", "comma separated states of phase 2, from the initial one to the last")
+	fs.Parse(args)
+
+	def, err := circuits.Lookup(*name)
+	if err != nil {
+		return err
+	}
+	c, err := loadCeremony(def, *phase1Paths, *phase2Paths)
+	if err != nil {
+		return err
+	}
+	_, _, transcript, err := snark.Finalize(c.ccs, c.phase1, c.phase2)
+	if err != nil {
+		return err
+	}
+	c.print(transcript)
+	return nil
+}
+
+func runFinalize(args []string) error {
+	fs := flag.NewFlagSet("ceremony finalize", flag.ExitOnError)
+	resolve := artifactFlags(fs)
+	phase1Paths := fs.String("phase1", "", "comma separated states of phase 1, from the initial one to the last")
+	phase2Paths := fs.String("phase2", "", "comma separated states of phase 2, from the initial one to the last")
+	fs.Parse(args)
+
+	t, err := resolve()
+	if err != nil {
+		return err
+	}
+	if t.backend != backend.GROTH16 {
+		return fmt.Errorf("ceremonies produce %s keys, not %s", backend.GROTH16, t.backend)
+	}
+	c, err := loadCeremony(t.def, *phase1Paths, *phase2Paths)
+	if err != nil {
+		return err
+	}
+	pk, vk, transcript, err := snark.Finalize(c.ccs, c.phase1, c.phase2)
+	if err != nil {
+		return err
+	}
+	c.print(transcript)
+
+	h := c.header
+	if h.KeyHash, err = snark.KeyHash(vk); err != nil {
+		return err
+	}
+	h.Transcript = transcript
+	if err := snark.WriteConstraintSystem(t.paths.ConstraintSystem, h, c.ccs); err != nil {
+		return err
+	}
+	if err := snark.WriteVerifyingKey(t.paths.VerifyingKey, h, vk); err != nil {
+		return err
+	}
+	return snark.WriteProvingKey(t.paths.ProvingKey, h, pk)
+}
+
+// ceremony holds the states of both phases of a ceremony and the circuit of phase 2, compiled again
+type ceremony struct {
+	ccs    constraint.ConstraintSystem
+	header snark.Header
+	phase1 []snark.Phase1
+	phase2 []snark.Phase2
+}
+
+// loadCeremony reads the states of both phases, which must be of the curve of phase 1 and the circuit def
+func loadCeremony(def circuits.Definition, phase1Paths, phase2Paths string) (*ceremony, error) {
+	curve, phase1, err := readPhase1Chain(phase1Paths)
+	if err != nil {
+		return nil, err
+	}
+	ccs, h, err := compileGroth16(def, curve)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ceremony{ccs: ccs, header: h, phase1: phase1}
+	for _, path := range splitPaths(phase2Paths) {
+		p, ph, err := snark.ReadPhase2(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := ph.CheckDefinition(def); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := h.CheckSame(ph); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		c.phase2 = append(c.phase2, p)
+	}
+	return c, nil
+}
+
+// print lists the hashes of every state, for the parties to find theirs, and the transcript
+func (c *ceremony) print(transcript []byte) {
+	for i, p := range c.phase1 {
+		fmt.Printf("phase 1 state %d  %x\n", i, p.ContributionHash())
+	}
+	for i, p := range c.phase2 {
+		fmt.Printf("phase 2 state %d  %x\n", i, p.ContributionHash())
+	}
+	fmt.Println("transcript", hex.EncodeToString(transcript))
+}
+
+// readPhase1Chain reads the comma separated states of phase 1, which must all be on the same curve
+func readPhase1Chain(paths string) (ecc.ID, []snark.Phase1, error) {
+	var (
+		curve  ecc.ID
+		phase1 []snark.Phase1
+	)
+	for i, path := range splitPaths(paths) {
+		p, h, err := snark.ReadPhase1(path)
+		if err != nil {
+			return curve, nil, err
+		}
+		if i == 0 {
+			curve = h.Curve
+		} else if h.Curve != curve {
+			return curve, nil, fmt.Errorf("%s is on %s, phase 1 started on %s", path, h.Curve, curve)
+		}
+		phase1 = append(phase1, p)
+	}
+	if len(phase1) == 0 {
+		return curve, nil, errors.New("no state of phase 1 given, see -phase1")
+	}
+	return curve, phase1, nil
+}
+
+func splitPaths(paths string) []string {
+	if paths == "" {
+		return nil
+	}
+	return strings.Split(paths, ",")
+}
+
+// compileGroth16 compiles the circuit into a R1CS on curve, with the header of its artifacts
+func compileGroth16(def circuits.Definition, curve ecc.ID) (constraint.ConstraintSystem, snark.Header, error) {
+	ccs, err := snark.Compile(def, backend.GROTH16, curve)
+	if err != nil {
+		return nil, snark.Header{}, err
+	}
+	h, err := snark.NewHeader(def, backend.GROTH16, curve, ccs)
+	return ccs, h, err
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// A whole ceremony with two parties per phase, whose keys prove and verify the cubic circuit
+func TestCeremony(t *testing.T) {
+	witness := filepath.Join(inTempDir(t), "cubic.json")
+	run(t, runCeremony, "init1", "-circuit", "cubic")
+	run(t, runCeremony, "contribute1", "-in", "bn254.phase1.0", "-out", "bn254.phase1.1")
+	run(t, runCeremony, "contribute1", "-in", "bn254.phase1.1", "-out", "bn254.phase1.2")
+	phase1 := "bn254.phase1.0,bn254.phase1.1,bn254.phase1.2"
+	run(t, runCeremony, "init2", "-circuit", "cubic", "-phase1", phase1)
+	run(t, runCeremony, "contribute2", "-in", "cubic.phase2.0", "-out", "cubic.phase2.1")
+	run(t, runCeremony, "contribute2", "-in", "cubic.phase2.1", "-out", "cubic.phase2.2")
+	phase2 := "cubic.phase2.0,cubic.phase2.1,cubic.phase2.2"
+	run(t, runCeremony, "verify", "-circuit", "cubic", "-phase1", phase1, "-phase2", phase2)
+	run(t, runCeremony, "finalize", "-circuit", "cubic", "-phase1", phase1, "-phase2", phase2)
+
+	run(t, runProve, "-witness", witness)
+	run(t, runVerify)
+}
+
+// Contributions out of order, missing or tampered with are rejected
+func TestCeremonyRejectsTampering(t *testing.T) {
+	inTempDir(t)
+	run(t, runCeremony, "init1", "-circuit", "cubic")
+	run(t, runCeremony, "contribute1", "-in", "bn254.phase1.0", "-out", "bn254.phase1.1")
+	run(t, runCeremony, "contribute1", "-in", "bn254.phase1.1", "-out", "bn254.phase1.2")
+	phase1 := "bn254.phase1.0,bn254.phase1.1,bn254.phase1.2"
+	run(t, runCeremony, "init2", "-circuit", "cubic", "-phase1", phase1)
+	run(t, runCeremony, "contribute2", "-in", "cubic.phase2.0", "-out", "cubic.phase2.1")
+	// a second contribution on top of the initial state, instead of the last one
+	run(t, runCeremony, "contribute2", "-in", "cubic.phase2.0", "-out", "cubic.phase2.fork")
+
+	// the contribution of phase 2 with the last byte of its hash flipped, which ends the payload
+	tamper(t, "cubic.phase2.1", "cubic.phase2.tampered")
+
+	for name, chains := range map[string][2]string{
+		"phase 1 out of order":              {"bn254.phase1.0,bn254.phase1.2,bn254.phase1.1", "cubic.phase2.0"},
+		"phase 1 missing a state":           {"bn254.phase1.0,bn254.phase1.2", "cubic.phase2.0"},
+		"phase 2 forked":                    {phase1, "cubic.phase2.0,cubic.phase2.1,cubic.phase2.fork"},
+		"phase 2 tampered with":             {phase1, "cubic.phase2.0,cubic.phase2.tampered"},
+		"phase 2 on another phase 1":        {"bn254.phase1.0,bn254.phase1.1", "cubic.phase2.0,cubic.phase2.1"},
+		"phase 2 without its initial state": {phase1, "cubic.phase2.1"},
+	} {
+		args := []string{"verify", "-circuit", "cubic", "-phase1", chains[0], "-phase2", chains[1]}
+		if err := runCeremony(args); err == nil {
+			t.Errorf("%s: verified", name)
+		}
+	}
+	if err := runCeremony([]string{"no-such-command"}); err == nil {
+		t.Error("an unknown ceremony command succeeds")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"anonpao/snark"
+)
+
+// export writes the verifying key, proof and public witness of a circuit as JSON, next to the binary files:
+//
+//	zk export -circuit cubic -> cubic.g16.vk.json, cubic.g16.proof.json, cubic.public.wtns.json
+//
+// verify takes the JSON files as well as the binary ones, e.g. zk verify -proof cubic.g16.proof.json.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	resolve := artifactFlags(fs)
+	hex := fs.Bool("hex", false, "write field elements as 0x-prefixed hex instead of decimal")
+	fs.Parse(args)
+
+	t, err := resolve()
+	if err != nil {
+		return err
+	}
+	format := snark.Decimal
+	if *hex {
+		format = snark.Hex
+	}
+
+	vk, h, err := snark.ReadVerifyingKey(t.paths.VerifyingKey, t.backend)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckDefinition(t.def); err != nil {
+		return fmt.Errorf("%s: %w", t.paths.VerifyingKey, err)
+	}
+	if err := snark.WriteVerifyingKeyJSON(t.paths.VerifyingKey+".json", h, vk, format); err != nil {
+		return err
+	}
+
+	// like solidity, the key alone is useful right after setup
+	if _, err := os.Stat(t.paths.Proof); errors.Is(err, os.ErrNotExist) {
+		log.Println("no proof at", t.paths.Proof, "- only the verifying key was written")
+		return nil
+	}
+	proof, proofHeader, err := snark.ReadProof(t.paths.Proof, t.backend)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckSame(proofHeader); err != nil {
+		return fmt.Errorf("%s and %s: %w", t.paths.VerifyingKey, t.paths.Proof, err)
+	}
+	publicWitness, witnessHeader, err := snark.ReadPublicWitness(t.paths.PublicWitness, t.backend)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckSame(witnessHeader); err != nil {
+		return fmt.Errorf("%s and %s: %w", t.paths.VerifyingKey, t.paths.PublicWitness, err)
+	}
+	if err := snark.WriteProofJSON(t.paths.Proof+".json", proofHeader, proof, format); err != nil {
+		return err
+	}
+	return snark.WritePublicWitnessJSON(t.paths.PublicWitness+".json", witnessHeader, publicWitness, format)
+}
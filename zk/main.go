@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"anonpao/circuits"
+	"anonpao/snark"
+
+	"github.com/consensys/gnark/backend"
+)
+
+// zk runs the three steps of the Groth16 or PLONK workflow for any registered circuit:
+//
+//	zk setup  -circuit cubic                     -> cubic.r1cs, cubic.g16.pk, cubic.g16.vk
+//	zk prove  -circuit cubic -witness cubic.json -> cubic.g16.proof, cubic.public.wtns
+//	zk verify -circuit cubic                     -> exits non-zero if the proof is invalid
+//	zk verify -circuit cubic -batch a.proof:a.wtns b.proof:b.wtns ... -> lists the invalid proofs
+//	zk export   -circuit cubic                   -> cubic.g16.vk.json, cubic.g16.proof.json, cubic.public.wtns.json
+//	zk solidity -circuit cubic                   -> cubic.g16.sol, cubic.g16.calldata.json
+//	zk serve    -circuit cubic -socket zk.sock   -> proves the witnesses POSTed to /prove, see serve.go
+//	zk middlebox -circuit doh-firewall -prefix "GET /dns-query?dns=" -> forwards the proven requests, see middlebox.go
+//	zk profile  -circuit cubic                   -> prints the constraints by gadget, writes cubic.pprof
+//	zk aggregate <setup|prove|verify> -circuit cubic -> one proof for many groth16 proofs on bls12_377, see aggregate.go
+//	zk ceremony <init1|contribute1|init2|contribute2|verify|finalize> -> groth16 keys from many parties, see ceremony.go
+//
+// With -backend plonk, setup reuses the universal KZG SRS in bn254.kzg.srs, or creates it if it doesn't exist yet,
+// and the artifacts are named cubic.scs, cubic.plonk.pk and so on.
+// setup takes the curve with -curve (default bn254); prove and verify read it from the artifacts.
+// Every artifact path can be overridden with a flag, see zk <command> -h.
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"setup", "compile a circuit and generate its proving and verifying keys", runSetup},
+	{"prove", "prove a witness against a compiled circuit", runProve},
+	{"verify", "verify a proof against a verifying key and public witness", runVerify},
+	{"serve", "keep a circuit loaded and prove the witnesses sent to a Unix socket", runServe},
+	{"middlebox", "proxy TLS connections, forwarding the requests whose proofs verify", runMiddlebox},
+	{"export", "write the verifying key, proof and public witness as JSON, which verify also reads", runExport},
+	{"solidity", "export a Solidity verifier contract and the calldata of a groth16 proof on bn254", runSolidity},
+	{"aggregate", "prove many groth16 proofs on bls12_377 with one proof on bw6_761, and verify it", runAggregate},
+	{"ceremony", "run a multi-party groth16 setup, whose keys are sound if one party forgot its secrets", runCeremony},
+	{"profile", "compile a circuit and report its constraints by gadget, with a pprof profile", runProfile},
+	{"circuits", "list the registered circuits", runCircuits},
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			if err := c.run(os.Args[2:]); err != nil {
+				log.Fatal(c.name, " error: ", err)
+			}
+			return
+		}
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: zk <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", c.name, c.usage)
+	}
+}
+
+// target is what a command operates on: a registered circuit, a backend and the files of its artifacts
+type target struct {
+	def     circuits.Definition
+	backend backend.ID
+	paths   snark.Paths
+}
+
+// artifactFlags registers the -circuit and -backend flags and one flag per artifact path on fs.
+// The returned function resolves the target and fills in the default paths for it once fs is parsed.
+func artifactFlags(fs *flag.FlagSet) func() (target, error) {
+	var paths snark.Paths
+	name := fs.String("circuit", "cubic", "name of the registered circuit")
+	backendName := fs.String("backend", backend.GROTH16.String(), "proof system, groth16 or plonk")
+	fs.StringVar(&paths.ConstraintSystem, "r1cs", "", "constraint system file (default <circuit>.r1cs, or <circuit>.scs with plonk)")
+	fs.StringVar(&paths.ProvingKey, "pk", "", "proving key file (default <circuit>.g16.pk or <circuit>.plonk.pk)")
+	fs.StringVar(&paths.VerifyingKey, "vk", "", "verifying key file (default <circuit>.g16.vk or <circuit>.plonk.vk)")
+	fs.StringVar(&paths.Proof, "proof", "", "proof file (default <circuit>.g16.proof or <circuit>.plonk.proof)")
+	fs.StringVar(&paths.PublicWitness, "public", "", "public witness file (default <circuit>.public.wtns)")
+	fs.StringVar(&paths.SRS, "srs", "", "KZG SRS shared by all plonk circuits on a curve, read by setup (default <curve>.kzg.srs)")
+
+	return func() (target, error) {
+		def, err := circuits.Lookup(*name)
+		if err != nil {
+			return target{}, err
+		}
+		b, err := snark.ParseBackend(*backendName)
+		if err != nil {
+			return target{}, err
+		}
+		return target{def, b, paths.WithDefaults(def.Name, b)}, nil
+	}
+}
+
+func runCircuits(args []string) error {
+	fs := flag.NewFlagSet("circuits", flag.ExitOnError)
+	fs.Parse(args)
+
+	for _, name := range circuits.Names() {
+		def, _ := circuits.Lookup(name)
+		fmt.Printf("%-12s %s\n", def.Name, def.Description)
+	}
+	return nil
+}
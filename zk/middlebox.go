@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"anonpao/circuits"
+	"anonpao/middlebox"
+	"anonpao/snark"
+)
+
+// middlebox runs the proxy of the middlebox module with the verifying key of a circuit that derives its public
+// inputs from the traffic, e.g. doh-firewall:
+//
+//	zk middlebox -circuit doh-firewall -listen localhost:8853 -upstream cloudflare-dns.com:443 \
+//	             -proofs localhost:8854 -prefix "GET /dns-query?dns="
+//
+// Clients connect to -listen, and POST the proofs of their requests to -proofs, see middlebox.ProofHandler.
+// The policy root is given in hex with -policy, or for doh-firewall computed from the prefix with -prefix.
+
+func runMiddlebox(args []string) error {
+	fs := flag.NewFlagSet("middlebox", flag.ExitOnError)
+	resolve := artifactFlags(fs)
+	listen := fs.String("listen", "localhost:8853", "TCP address the clients connect to")
+	upstream := fs.String("upstream", "cloudflare-dns.com:443", "TCP address of the server the proven requests are forwarded to")
+	proofs := fs.String("proofs", "localhost:8854", "TCP address on which the clients POST their proofs")
+	policy := fs.String("policy", "", "policy root the requests must comply with, in hex")
+	prefix := fs.String("prefix", "", "prefix of the requests allowed by the doh-firewall policy, instead of -policy")
+	timeout := fs.Duration("timeout", middlebox.DefaultProofTimeout, "time a request is held waiting for its proof")
+	fs.Parse(args)
+
+	t, err := resolve()
+	if err != nil {
+		return err
+	}
+	root, err := policyRoot(*policy, *prefix)
+	if err != nil {
+		return err
+	}
+	p, err := newProxy(t, *upstream, root, *timeout)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	pl, err := net.Listen("tcp", *proofs)
+	if err != nil {
+		l.Close()
+		return err
+	}
+	srv := &http.Server{Handler: p.ProofHandler()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("shutting down")
+		l.Close()
+		srv.Close()
+	}()
+
+	log.Printf("proxying %s to %s for %s (%s), proofs on %s", l.Addr(), *upstream, t.def.Name, t.backend, pl.Addr())
+	return serveMiddlebox(p, l, srv, pl)
+}
+
+// policyRoot returns the policy root given in hex, or that of the DoH firewall allowing prefix
+func policyRoot(policy, prefix string) ([]byte, error) {
+	switch {
+	case policy != "" && prefix != "":
+		return nil, errors.New("-policy and -prefix are exclusive")
+	case prefix != "":
+		return circuits.DoHPolicyRoot([]byte(prefix))
+	case policy == "":
+		return nil, errors.New("-policy or -prefix is required")
+	}
+	root, err := hex.DecodeString(policy)
+	if err != nil {
+		return nil, fmt.Errorf("-policy: %w", err)
+	}
+	return root, nil
+}
+
+// newProxy returns a proxy to upstream that verifies the proofs of t with its verifying key
+func newProxy(t target, upstream string, policyRoot []byte, timeout time.Duration) (*middlebox.Proxy, error) {
+	vk, h, err := snark.ReadVerifyingKey(t.paths.VerifyingKey, t.backend)
+	if err != nil {
+		return nil, err
+	}
+	v, err := middlebox.NewSnarkVerifier(t.def, t.backend, vk, h)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.paths.VerifyingKey, err)
+	}
+	return &middlebox.Proxy{Upstream: upstream, Verifier: v, PolicyRoot: policyRoot, ProofTimeout: timeout}, nil
+}
+
+// serveMiddlebox relays the connections of l with p and serves the proofs on pl with srv, until either stops
+func serveMiddlebox(p *middlebox.Proxy, l net.Listener, srv *http.Server, pl net.Listener) error {
+	errs := make(chan error, 2)
+	go func() { errs <- srv.Serve(pl) }()
+	go func() { errs <- p.Serve(l) }()
+	err := <-errs
+	l.Close()
+	srv.Close()
+	<-errs
+	if errors.Is(err, net.ErrClosed) || errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"anonpao/circuits"
+	"anonpao/middlebox"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// statementCircuit stands in for the TLS circuits, whose proofs cannot be made for the connections of crypto/tls
+// without their handshake secret: its only public input is a digest of the request record, its sequence number
+// and the policy root, which the prover knows
+type statementCircuit struct {
+	Digest frontend.Variable `gnark:",public"`
+	Secret frontend.Variable
+}
+
+func (c *statementCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(c.Secret, c.Digest)
+	return nil
+}
+
+func (c *statementCircuit) AssignPublic(st middlebox.Statement) error {
+	c.Digest = statementDigest(st)
+	return nil
+}
+
+// statementDigest hashes the request record, its sequence number and the policy root, truncated to fit in the scalar field
+func statementDigest(st middlebox.Statement) *big.Int {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, uint32(len(st.Ciphertext)))
+	h.Write(st.Ciphertext)
+	binary.Write(h, binary.BigEndian, st.Sequence)
+	h.Write(st.PolicyRoot)
+	return new(big.Int).SetBytes(h.Sum(nil)[:31])
+}
+
+func init() {
+	circuits.Register(circuits.Definition{
+		Name:        "statement",
+		Description: "test circuit of the middlebox command",
+		New:         func() frontend.Circuit { return new(statementCircuit) },
+	})
+}
+
+// writingConn keeps a copy of the bytes the TLS client writes
+type writingConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *writingConn) Write(b []byte) (int, error) {
+	c.written.Write(b)
+	return c.Conn.Write(b)
+}
+
+// proveStatement proves the statement with zk prove and returns the proof
+func proveStatement(t *testing.T, st middlebox.Statement) []byte {
+	d := statementDigest(st).String()
+	witness := fmt.Sprintf(`{"Digest": %q, "Secret": %q}`, d, d)
+	if err := os.WriteFile("statement.json", []byte(witness), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, runProve, "-circuit", "statement")
+	proof, err := os.ReadFile("statement.g16.proof")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+// A request through the proxy of zk middlebox reaches the server once its proof, made by zk prove, is POSTed
+func TestMiddlebox(t *testing.T) {
+	inTempDir(t)
+	run(t, runSetup, "-circuit", "statement")
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok ", r.URL)
+	}))
+	server.StartTLS()
+	defer server.Close()
+
+	fs := flag.NewFlagSet("middlebox", flag.ContinueOnError)
+	resolve := artifactFlags(fs)
+	fs.Parse([]string{"-circuit", "statement"})
+	target, err := resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := policyRoot("", "GET /dns-query?dns=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := newProxy(target, server.Listener.Addr().String(), root, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: p.ProofHandler()}
+	done := make(chan error, 1)
+	go func() { done <- serveMiddlebox(p, l, srv, pl) }()
+
+	raw, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &writingConn{Conn: raw}
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	conn := tls.Client(rec, &tls.Config{RootCAs: roots, ServerName: "example.com", MinVersion: tls.VersionTLS13})
+	defer conn.Close()
+	if err := conn.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	// the random follows the record header, the handshake header and the version of the ClientHello
+	random := append([]byte{}, rec.written.Bytes()[11:43]...)
+	rec.written.Reset()
+	if _, err := conn.Write([]byte("GET /dns-query?dns=AAABAAABAAAAAAAAB2V4YW1wbGUDY29tAAABAAE HTTP/1.1\r\nHost: example.com\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	st := middlebox.Statement{Ciphertext: append([]byte{}, rec.written.Bytes()...), PolicyRoot: root}
+
+	submit := func(proof []byte) int {
+		body, _ := json.Marshal(middlebox.ProofRequest{ClientRandom: hex.EncodeToString(random), Proof: proof})
+		resp, err := http.Post("http://"+pl.Addr().String(), "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	// the proof of the second record of the connection does not forward the first
+	second := st
+	second.Sequence = 1
+	if status := submit(proveStatement(t, second)); status != http.StatusForbidden {
+		t.Fatalf("the proof of another record: got status %d, want %d", status, http.StatusForbidden)
+	}
+	if status := submit(proveStatement(t, st)); status != http.StatusOK {
+		t.Fatalf("the proof of the request: got status %d, want %d", status, http.StatusOK)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(answer), "ok /dns-query?dns=") {
+		t.Fatalf("got answer %q", answer)
+	}
+
+	l.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("the middlebox stopped with %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the middlebox does not stop with its listener")
+	}
+}
+
+// Only the circuits that derive their public inputs from the traffic are proxied, with a policy root
+func TestMiddleboxRejects(t *testing.T) {
+	inTempDir(t)
+	run(t, runSetup)
+	fs := flag.NewFlagSet("middlebox", flag.ContinueOnError)
+	resolve := artifactFlags(fs)
+	fs.Parse(nil)
+	target, err := resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newProxy(target, "localhost:443", []byte("policy"), time.Second); err == nil ||
+		!strings.Contains(err.Error(), "does not derive its public inputs from traffic") {
+		t.Errorf("a proxy of cubic: %v", err)
+	}
+
+	for _, c := range []struct{ policy, prefix string }{
+		{"", ""},
+		{"00", "GET /"},
+		{"not hex", ""},
+		{"", strings.Repeat("A", circuits.DoHPrefixSize+1)},
+	} {
+		if _, err := policyRoot(c.policy, c.prefix); err == nil {
+			t.Errorf("-policy %q -prefix %q is accepted", c.policy, c.prefix)
+		}
+	}
+	if root, err := policyRoot("00ff", ""); err != nil || !bytes.Equal(root, []byte{0, 0xff}) {
+		t.Errorf("-policy 00ff: %x, %v", root, err)
+	}
+}
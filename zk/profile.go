@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"anonpao/circuits"
+	"anonpao/gadgets"
+	"anonpao/snark"
+
+	"github.com/consensys/gnark/backend"
+)
+
+// profile compiles a circuit and prints its size, with the constraints of each gadget, e.g. for the TLS circuits:
+//
+//	zk profile -circuit aes128-gcm               -> prints the report, writes aes128-gcm.pprof
+//	go tool pprof -top aes128-gcm.pprof         -> constraints by function
+//	go tool pprof -list AESGCMSeal aes128-gcm.pprof -> constraints by line
+//
+// Nothing else is written, the constraint system is compiled again by setup.
+func runProfile(args []string) error {
+	fs := flag.NewFlagSet("profile", flag.ExitOnError)
+	name := fs.String("circuit", "cubic", "name of the registered circuit")
+	backendName := fs.String("backend", backend.GROTH16.String(), "proof system, groth16 or plonk")
+	curveName := fs.String("curve", snark.DefaultCurve.String(), "curve of the proof system: bn254, bls12_381, bls12_377 or bw6_761")
+	pprofPath := fs.String("pprof", "", "profile of the constraints, in the pprof format (default <circuit>.pprof)")
+	fs.Parse(args)
+
+	def, err := circuits.Lookup(*name)
+	if err != nil {
+		return err
+	}
+	b, err := snark.ParseBackend(*backendName)
+	if err != nil {
+		return err
+	}
+	curve, err := snark.ParseCurve(*curveName)
+	if err != nil {
+		return err
+	}
+	if *pprofPath == "" {
+		*pprofPath = def.Name + ".pprof"
+	}
+
+	_, r, err := snark.Profile(def, b, curve, *pprofPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("circuit        %s, %s on %s\n", def.Name, b, curve)
+	fmt.Printf("constraints    %d\n", r.Constraints)
+	fmt.Printf("public inputs  %d\n", r.Public)
+	fmt.Printf("secret inputs  %d\n\n", r.Secret)
+	fmt.Printf("%-22s %11s %7s\n", "gadget", "constraints", "share")
+	for i, g := range gadgets.Profiled {
+		fmt.Printf("%-22s %11d %7s\n", g.Name, r.Gadgets[i], share(r.Gadgets[i], r.Recorded))
+	}
+	fmt.Printf("%-22s %11d %7s\n", "other", r.Other, share(r.Other, r.Recorded))
+	fmt.Printf("\nnested gadgets count in both (SHA-256 compressions inside HMAC-SHA256);\nconstraints by function and line: go tool pprof -top %s\n", *pprofPath)
+	return nil
+}
+
+func share(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(n)/float64(total))
+}
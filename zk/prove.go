@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"anonpao/snark"
+
+	"github.com/consensys/gnark/constraint"
+)
+
+func runProve(args []string) error {
+	fs := flag.NewFlagSet("prove", flag.ExitOnError)
+	resolve := artifactFlags(fs)
+	witnessPath := fs.String("witness", "", "JSON assignment of the circuit inputs, see snark/witness.go (default <circuit>.json)")
+	fs.Parse(args)
+
+	t, err := resolve()
+	if err != nil {
+		return err
+	}
+	if *witnessPath == "" {
+		*witnessPath = t.def.Name + ".json"
+	}
+
+	p, err := loadProver(t)
+	if err != nil {
+		return err
+	}
+
+	witness, err := snark.ReadWitness(t.def, *witnessPath, p.header.Curve)
+	if err != nil {
+		return err
+	}
+
+	// extract the public part only
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return err
+	}
+
+	// the public witness is only written along with its proof, never next to a stale one
+	proof, err := snark.Prove(t.backend, p.cs, p.pk, witness)
+	if err != nil {
+		return err
+	}
+	if err := snark.WritePublicWitness(t.paths.PublicWitness, p.header, publicWitness); err != nil {
+		return err
+	}
+	return snark.WriteProof(t.paths.Proof, p.proofHeader, proof)
+}
+
+// prover holds what is needed to prove a circuit, as loaded from its artifacts
+type prover struct {
+	cs constraint.ConstraintSystem
+	pk snark.ProvingKey
+
+	// header describes the constraint system and proofHeader the setup of the proving key, which proofs belong to
+	header, proofHeader snark.Header
+}
+
+// loadProver reads the constraint system and proving key of t.
+// The objects are created for the curve recorded in the artifacts, which must all belong to the circuit of t.
+func loadProver(t target) (*prover, error) {
+	cs, h, err := snark.ReadConstraintSystem(t.paths.ConstraintSystem, t.backend)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.CheckDefinition(t.def); err != nil {
+		return nil, fmt.Errorf("%s: %w", t.paths.ConstraintSystem, err)
+	}
+	pk, pkHeader, err := snark.ReadProvingKey(t.paths.ProvingKey, t.backend)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.CheckSame(pkHeader); err != nil {
+		return nil, fmt.Errorf("%s and %s: %w", t.paths.ConstraintSystem, t.paths.ProvingKey, err)
+	}
+	return &prover{cs, pk, h, pkHeader}, nil
+}
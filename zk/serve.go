@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"anonpao/snark"
+
+	"github.com/consensys/gnark/backend/witness"
+)
+
+// serve keeps the constraint system and proving key of a circuit in memory and proves witnesses sent over HTTP
+// on a Unix socket, so that clients don't pay for loading the artifacts on every proof:
+//
+//	POST /prove   body: a JSON witness (see snark/witness.go)
+//	              200: {"proof": "<base64>", "public_witness": "<base64>"}, both in the format of the files written by prove
+//	              400: malformed witness, 422: the witness does not satisfy the circuit
+//	              503: the job queue is full, 504: the proof took longer than -timeout
+//	GET  /status  the circuit served and the number of queued and running jobs
+//
+// e.g. curl --unix-socket zk.sock --data @cubic.json http://zk/prove
+//
+// At most -workers proofs run at the same time and at most -queue more wait for a worker.
+// A job is dropped from the queue when its client disconnects or its timeout expires.
+// gnark cannot interrupt a proof once it started, so a job cancelled while running keeps its worker
+// until the proof is done, and the proof is then discarded.
+
+// maxWitnessSize bounds the request bodies, the witnesses of the TLS circuits are a few hundred kilobytes at most
+const maxWitnessSize = 16 << 20
+
+type server struct {
+	t       target
+	p       *prover
+	workers int
+	timeout time.Duration
+
+	queue           chan *job
+	queued, running atomic.Int32
+}
+
+type job struct {
+	ctx     context.Context
+	witness witness.Witness
+	done    chan jobResult // buffered, so that workers never wait for a client that went away
+}
+
+type jobResult struct {
+	proof snark.Proof
+	err   error
+}
+
+// proveResponse is the body of a successful /prove request
+type proveResponse struct {
+	Proof         []byte `json:"proof"`
+	PublicWitness []byte `json:"public_witness"`
+}
+
+// statusResponse is the body of a /status request
+type statusResponse struct {
+	Circuit string `json:"circuit"`
+	Backend string `json:"backend"`
+	Curve   string `json:"curve"`
+	Workers int    `json:"workers"`
+	Queue   int    `json:"queue"`
+	Queued  int    `json:"queued"`
+	Running int    `json:"running"`
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	resolve := artifactFlags(fs)
+	socket := fs.String("socket", "zk.sock", "Unix socket to listen on")
+	workers := fs.Int("workers", 1, "number of proofs computed at the same time")
+	queue := fs.Int("queue", 16, "number of jobs waiting for a worker before requests are refused")
+	timeout := fs.Duration("timeout", time.Minute, "time limit of a request, including the time spent in the queue")
+	fs.Parse(args)
+
+	t, err := resolve()
+	if err != nil {
+		return err
+	}
+	if *workers < 1 || *queue < 0 {
+		return errors.New("-workers must be positive and -queue must not be negative")
+	}
+
+	p, err := loadProver(t)
+	if err != nil {
+		return err
+	}
+	s := newServer(t, p, *workers, *queue, *timeout)
+	for i := 0; i < *workers; i++ {
+		go s.work()
+	}
+
+	l, err := listenUnix(*socket)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.handler()}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("shutting down")
+		shutdown, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	log.Printf("serving %s (%s on %s) on %s", t.def.Name, t.backend, p.header.Curve, *socket)
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newServer returns a server of the prover p with a queue of the given size, whose workers are left to start
+func newServer(t target, p *prover, workers, queue int, timeout time.Duration) *server {
+	return &server{t: t, p: p, workers: workers, timeout: timeout, queue: make(chan *job, queue)}
+}
+
+// handler routes /prove and /status
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/prove", s.handleProve)
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, statusResponse{
+			Circuit: s.t.def.Name,
+			Backend: s.t.backend.String(),
+			Curve:   s.p.header.Curve.String(),
+			Workers: s.workers,
+			Queue:   cap(s.queue),
+			Queued:  int(s.queued.Load()),
+			Running: int(s.running.Load()),
+		})
+	})
+	return mux
+}
+
+// listenUnix listens on the socket at path, replacing the socket left behind by a daemon that is no longer running
+func listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("%s is in use by another daemon", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// closing the listener removes the socket file
+	l.(*net.UnixListener).SetUnlinkOnClose(true)
+	return l, nil
+}
+
+// work proves the jobs of the queue one after the other, skipping those whose client is gone
+func (s *server) work() {
+	for j := range s.queue {
+		s.queued.Add(-1)
+		if j.ctx.Err() != nil {
+			continue
+		}
+
+		s.running.Add(1)
+		proof, err := snark.Prove(s.t.backend, s.p.cs, s.p.pk, j.witness)
+		s.running.Add(-1)
+		j.done <- jobResult{proof, err}
+	}
+}
+
+func (s *server) handleProve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST with a JSON witness", http.StatusMethodNotAllowed)
+		return
+	}
+	start := time.Now()
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWitnessSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	full, err := snark.ParseWitness(s.t.def, data, s.p.header.Curve)
+	if err != nil {
+		http.Error(w, "witness: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	public, err := full.Public()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+	j := &job{ctx: ctx, witness: full, done: make(chan jobResult, 1)}
+
+	s.queued.Add(1)
+	select {
+	case s.queue <- j:
+	default:
+		s.queued.Add(-1)
+		http.Error(w, "too many pending proofs, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	var res jobResult
+	select {
+	case res = <-j.done:
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			http.Error(w, fmt.Sprintf("no proof after %s", s.timeout), http.StatusGatewayTimeout)
+		} else {
+			log.Println("client went away after", time.Since(start))
+		}
+		return
+	}
+	if res.err != nil {
+		http.Error(w, res.err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	var proof, publicWitness bytes.Buffer
+	if err := snark.EncodeProof(&proof, s.p.proofHeader, res.proof); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := snark.EncodePublicWitness(&publicWitness, s.p.header, public); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, proveResponse{proof.Bytes(), publicWitness.Bytes()})
+	log.Println("proved in", time.Since(start))
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("writing response:", err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// servedCubic sets up the cubic circuit in a fresh directory and returns a server of it, with no worker
+// started, and the witness of the package
+func servedCubic(t *testing.T, queue int, timeout time.Duration) (*server, []byte) {
+	witness, err := os.ReadFile(filepath.Join(inTempDir(t), "cubic.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, runSetup)
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	resolve := artifactFlags(fs)
+	fs.Parse(nil)
+	target, err := resolve()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadProver(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newServer(target, p, 1, queue, timeout), witness
+}
+
+// post sends a witness to /prove and returns the status of the response
+func post(t *testing.T, client *http.Client, url string, witness []byte) (int, []byte) {
+	resp, err := client.Post(url+"/prove", "application/json", bytes.NewReader(witness))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body.Bytes()
+}
+
+// Witnesses sent over the Unix socket are proved, and the proofs verified by zk verify
+func TestServeUnixSocket(t *testing.T) {
+	s, witness := servedCubic(t, 1, time.Minute)
+	go s.work()
+	socket, err := filepath.Abs("zk.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := listenUnix(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: s.handler()}
+	go srv.Serve(l)
+	defer srv.Close()
+	if _, err := listenUnix(socket); err == nil {
+		t.Error("a second daemon listens on the socket of the first")
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	status, body := post(t, client, "http://zk", witness)
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	var res proveResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("served.proof", res.Proof, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("served.wtns", res.PublicWitness, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, runVerify, "-proof", "served.proof", "-public", "served.wtns")
+
+	for _, c := range []struct {
+		witness string
+		status  int
+	}{
+		{`{"x": 3`, http.StatusBadRequest},
+		{`{"x": 3, "Y": 15}`, http.StatusUnprocessableEntity},
+	} {
+		if status, body := post(t, client, "http://zk", []byte(c.witness)); status != c.status {
+			t.Errorf("%s: status %d, want %d: %s", c.witness, status, c.status, body)
+		}
+	}
+}
+
+// A request is refused with 503 while the queue is full, and the queued job is proved once a worker starts
+func TestServeQueueFull(t *testing.T) {
+	s, witness := servedCubic(t, 1, time.Minute)
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	first := make(chan int)
+	go func() {
+		resp, err := srv.Client().Post(srv.URL+"/prove", "application/json", bytes.NewReader(witness))
+		if err != nil {
+			first <- 0
+			return
+		}
+		resp.Body.Close()
+		first <- resp.StatusCode
+	}()
+	for len(s.queue) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if status, body := post(t, srv.Client(), srv.URL, witness); status != http.StatusServiceUnavailable {
+		t.Errorf("status %d with a full queue, want 503: %s", status, body)
+	}
+
+	go s.work()
+	if status := <-first; status != http.StatusOK {
+		t.Errorf("status %d for the queued job, want 200", status)
+	}
+}
+
+// A job that no worker picked up before the timeout gets a 504
+func TestServeTimeout(t *testing.T) {
+	s, witness := servedCubic(t, 1, 50*time.Millisecond)
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	if status, body := post(t, srv.Client(), srv.URL, witness); status != http.StatusGatewayTimeout {
+		t.Errorf("status %d after the timeout, want 504: %s", status, body)
+	}
+}
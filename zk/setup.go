@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+
+	"anonpao/snark"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/constraint"
+)
+
+func runSetup(args []string) error {
+	fs := flag.NewFlagSet("setup", flag.ExitOnError)
+	resolve := artifactFlags(fs)
+	curveName := fs.String("curve", snark.DefaultCurve.String(), "curve of the proof system: bn254, bls12_381, bls12_377 or bw6_761")
+	fs.Parse(args)
+
+	t, err := resolve()
+	if err != nil {
+		return err
+	}
+	curve, err := snark.ParseCurve(*curveName)
+	if err != nil {
+		return err
+	}
+
+	ccs, err := snark.Compile(t.def, t.backend, curve)
+	if err != nil {
+		return err
+	}
+
+	// every artifact records the backend, curve and circuit it belongs to, so later steps need not be told again
+	h, err := snark.NewHeader(t.def, t.backend, curve, ccs)
+	if err != nil {
+		return err
+	}
+
+	// save the constraint system to file
+	if err := snark.WriteConstraintSystem(t.paths.ConstraintSystem, h, ccs); err != nil {
+		return err
+	}
+
+	var srs kzg.SRS
+	if t.backend == backend.PLONK {
+		if srs, err = loadOrCreateSRS(t.paths.SRSPath(curve), ccs, curve); err != nil {
+			return err
+		}
+	}
+
+	pk, vk, err := snark.Setup(t.backend, ccs, srs)
+	if err != nil {
+		return err
+	}
+
+	// tie both keys to this setup, so that keys from another run are refused
+	if h.KeyHash, err = snark.KeyHash(vk); err != nil {
+		return err
+	}
+	if err := snark.WriteVerifyingKey(t.paths.VerifyingKey, h, vk); err != nil {
+		return err
+	}
+	return snark.WriteProvingKey(t.paths.ProvingKey, h, pk)
+}
+
+// loadOrCreateSRS reads the universal SRS at path, or generates one sized for ccs if there is none yet
+func loadOrCreateSRS(path string, ccs constraint.ConstraintSystem, curve ecc.ID) (kzg.SRS, error) {
+	srs, err := snark.ReadSRS(path, curve)
+	if err == nil {
+		return srs, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	log.Println("no SRS found at", path, "- generating one locally, do not use it outside of tests")
+	if srs, err = snark.NewSRS(ccs, curve); err != nil {
+		return nil, err
+	}
+	return srs, snark.WriteFile(path, srs)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"anonpao/snark"
+)
+
+func runSolidity(args []string) error {
+	fs := flag.NewFlagSet("solidity", flag.ExitOnError)
+	resolve := artifactFlags(fs)
+	contractPath := fs.String("contract", "", "Solidity verifier contract to write (default <circuit>.g16.sol)")
+	calldataPath := fs.String("calldata", "", "verifyProof arguments of the proof to write, as JSON (default <circuit>.g16.calldata.json)")
+	fs.Parse(args)
+
+	t, err := resolve()
+	if err != nil {
+		return err
+	}
+	if *contractPath == "" {
+		*contractPath = t.def.Name + ".g16.sol"
+	}
+	if *calldataPath == "" {
+		*calldataPath = t.def.Name + ".g16.calldata.json"
+	}
+
+	vk, h, err := snark.ReadVerifyingKey(t.paths.VerifyingKey, t.backend)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckDefinition(t.def); err != nil {
+		return fmt.Errorf("%s: %w", t.paths.VerifyingKey, err)
+	}
+	if err := writeContract(*contractPath, vk, h); err != nil {
+		return err
+	}
+
+	// the contract alone is useful right after setup, before there is any proof to submit
+	if _, err := os.Stat(t.paths.Proof); errors.Is(err, os.ErrNotExist) {
+		log.Println("no proof at", t.paths.Proof, "- only the contract was written")
+		return nil
+	}
+	return writeCalldata(*calldataPath, t, vk, h)
+}
+
+func writeContract(path string, vk snark.VerifyingKey, h snark.Header) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := snark.ExportSolidity(f, vk, h); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeCalldata formats the proof and public witness of t for the contract, once they are known to verify against vk
+func writeCalldata(path string, t target, vk snark.VerifyingKey, h snark.Header) error {
+	proof, proofHeader, err := snark.ReadProof(t.paths.Proof, t.backend)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckSame(proofHeader); err != nil {
+		return fmt.Errorf("%s and %s: %w", t.paths.VerifyingKey, t.paths.Proof, err)
+	}
+	publicWitness, witnessHeader, err := snark.ReadPublicWitness(t.paths.PublicWitness, t.backend)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckSame(witnessHeader); err != nil {
+		return fmt.Errorf("%s and %s: %w", t.paths.VerifyingKey, t.paths.PublicWitness, err)
+	}
+
+	// an invalid proof would only be rejected on-chain, after paying for the call
+	if err := snark.Verify(t.backend, proof, vk, publicWitness); err != nil {
+		return errors.New("invalid proof")
+	}
+
+	calldata, err := snark.NewCalldata(proof, publicWitness, h)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(calldata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
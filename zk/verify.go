@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"anonpao/snark"
+
+	"github.com/consensys/gnark/backend/witness"
+)
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	resolve := artifactFlags(fs)
+	batch := fs.Bool("batch", false, "verify the proof:public witness pairs given as arguments together, instead of -proof and -public")
+	fs.Parse(args)
+
+	t, err := resolve()
+	if err != nil {
+		return err
+	}
+
+	// all files must come from the backend we verify with, and from the same circuit
+	vk, h, err := snark.ReadVerifyingKey(t.paths.VerifyingKey, t.backend)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckDefinition(t.def); err != nil {
+		return fmt.Errorf("%s: %w", t.paths.VerifyingKey, err)
+	}
+	if *batch {
+		return verifyBatch(t, vk, h, fs.Args())
+	}
+
+	proof, proofHeader, err := snark.ReadProof(t.paths.Proof, t.backend)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckSame(proofHeader); err != nil {
+		return fmt.Errorf("%s and %s: %w", t.paths.VerifyingKey, t.paths.Proof, err)
+	}
+	publicWitness, witnessHeader, err := snark.ReadPublicWitness(t.paths.PublicWitness, t.backend)
+	if err != nil {
+		return err
+	}
+	if err := h.CheckSame(witnessHeader); err != nil {
+		return fmt.Errorf("%s and %s: %w", t.paths.VerifyingKey, t.paths.PublicWitness, err)
+	}
+
+	// verify the proof
+	if err := snark.Verify(t.backend, proof, vk, publicWitness); err != nil {
+		return errors.New("invalid proof")
+	}
+	fmt.Println("true")
+	return nil
+}
+
+// verifyBatch verifies many proofs against vk at once, each pair being given as "proof:public witness".
+// It prints true if they are all valid, and otherwise lists the invalid ones.
+func verifyBatch(t target, vk snark.VerifyingKey, h snark.Header, pairs []string) error {
+	if len(pairs) == 0 {
+		return errors.New("-batch needs proof:public witness pairs as arguments")
+	}
+
+	proofs := make([]snark.Proof, len(pairs))
+	publicWitnesses := make([]witness.Witness, len(pairs))
+	for i, pair := range pairs {
+		proofPath, witnessPath, ok := strings.Cut(pair, ":")
+		if !ok {
+			return fmt.Errorf("%q is not a proof:public witness pair", pair)
+		}
+
+		proof, proofHeader, err := snark.ReadProof(proofPath, t.backend)
+		if err != nil {
+			return err
+		}
+		if err := h.CheckSame(proofHeader); err != nil {
+			return fmt.Errorf("%s and %s: %w", t.paths.VerifyingKey, proofPath, err)
+		}
+		publicWitness, witnessHeader, err := snark.ReadPublicWitness(witnessPath, t.backend)
+		if err != nil {
+			return err
+		}
+		if err := h.CheckSame(witnessHeader); err != nil {
+			return fmt.Errorf("%s and %s: %w", t.paths.VerifyingKey, witnessPath, err)
+		}
+		proofs[i], publicWitnesses[i] = proof, publicWitness
+	}
+
+	invalid, err := snark.BatchVerify(t.backend, vk, proofs, publicWitnesses)
+	if err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		for _, i := range invalid {
+			fmt.Println("invalid proof:", pairs[i])
+		}
+		return fmt.Errorf("%d of %d proofs are invalid", len(invalid), len(pairs))
+	}
+	fmt.Println("true")
+	return nil
+}
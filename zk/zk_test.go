@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// inTempDir runs the rest of the test in a fresh directory, where the commands write their artifacts,
+// and returns the directory of the package
+func inTempDir(t *testing.T) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return wd
+}
+
+// run runs a command of zk and fails the test if it fails
+func run(t *testing.T, c func(args []string) error, args ...string) {
+	t.Helper()
+	if err := c(args); err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+}
+
+// tamper writes the artifact in path to tampered with the last bit of its payload flipped, and its checksum
+// updated to match: the payload is followed by its length (8 bytes) and the checksum, see snark/envelope.go
+func tamper(t *testing.T, path, tampered string) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)-8-sha256.Size-1] ^= 1
+	checksum := sha256.Sum256(b[:len(b)-sha256.Size])
+	copy(b[len(b)-sha256.Size:], checksum[:])
+	if err := os.WriteFile(tampered, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The artifacts of the cubic circuit are only verified as the cubic circuit
+func TestVerifyChecksCircuit(t *testing.T) {
+	witness := filepath.Join(inTempDir(t), "cubic.json")
+	run(t, runSetup)
+	run(t, runProve, "-witness", witness)
+	run(t, runVerify)
+
+	err := runVerify([]string{"-circuit", "sha256", "-vk", "cubic.g16.vk", "-proof", "cubic.g16.proof", "-public", "cubic.public.wtns"})
+	if err == nil {
+		t.Fatal("the artifacts of cubic are verified as sha256")
+	}
+}
+
+// A failed prove leaves no public witness behind
+func TestProveWritesNothingOnFailure(t *testing.T) {
+	inTempDir(t)
+	run(t, runSetup)
+	// 3**3 + 3 + 5 is not 15
+	if err := os.WriteFile("wrong.json", []byte(`{"x": 3, "Y": 15}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runProve([]string{"-witness", "wrong.json"}); err == nil {
+		t.Fatal("a wrong witness is proved")
+	}
+	for _, path := range []string{"cubic.public.wtns", "cubic.g16.proof"} {
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s: %v after a failed prove", path, err)
+		}
+	}
+}